@@ -0,0 +1,336 @@
+package gcache
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+var (
+	//ErrUnauthorized is returned for request without valid token when anonymous access is disabled
+	ErrUnauthorized = errors.New("Unauthorized")
+	//ErrForbidden is returned when principal is not allowed to run command or to use item name
+	ErrForbidden = errors.New("Forbidden")
+)
+
+//adminCommands are allowed only for principals with admin role
+var adminCommands = map[ItemMessage_Commands]bool{
+	ItemMessage_DEAD:  true,
+	ItemMessage_PURGE: true,
+	ItemMessage_KEYS:  true, //names of all namespaces
+}
+
+//unnamedCommands do not use item name, so prefixes are not checked
+var unnamedCommands = map[ItemMessage_Commands]bool{
+	ItemMessage_DEAD:  true,
+	ItemMessage_PURGE: true,
+	ItemMessage_KEYS:  true,
+	ItemMessage_STATS: true,
+	ItemMessage_AUTH:  true,
+	ItemMessage_PING:  true,
+}
+
+//Principal is a client with permissions, it is authenticated by token or by identity of client certificate
+type Principal struct {
+	Name       string   `json:"name"`
+	Admin      bool     `json:"admin,omitempty"`      //all commands including DEAD, PURGE and KEYS
+	Commands   []string `json:"commands,omitempty"`   //allowed commands like "GET", all not administrative commands if empty
+	Prefixes   []string `json:"prefixes,omitempty"`   //allowed prefixes of item names, all names if empty
+	Tokens     []string `json:"tokens,omitempty"`     //secret tokens of principal
+	Identities []string `json:"identities,omitempty"` //identities of client certificates, see TLSOptions
+}
+
+//Allow return nil if principal could run command with item name, nil principal is not authenticated
+func (p *Principal) Allow(cmd ItemMessage_Commands, name string) error {
+	if p == nil {
+		return ErrUnauthorized
+	}
+	if p.Admin {
+		return nil
+	}
+	if adminCommands[cmd] {
+		return ErrForbidden
+	}
+	if len(p.Commands) > 0 && !containsString(p.Commands, cmd.String()) {
+		return ErrForbidden
+	}
+	if len(p.Prefixes) == 0 || unnamedCommands[cmd] {
+		return nil
+	}
+	for _, prefix := range p.Prefixes {
+		if strings.HasPrefix(name, prefix) {
+			return nil
+		}
+	}
+	return ErrForbidden
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+//ACL is a list of principals, requests without token are made by Anonymous principal
+type ACL struct {
+	Anonymous  string       `json:"anonymous,omitempty"` //name of principal for clients without token, they are rejected if empty
+	Principals []*Principal `json:"principals"`
+}
+
+//LoadACL read ACL from json file
+func LoadACL(path string) (*ACL, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	acl := &ACL{}
+	if err = json.Unmarshal(data, acl); err != nil {
+		return nil, err
+	}
+	return acl, acl.Validate()
+}
+
+//Validate check names, commands and tokens of principals
+func (a *ACL) Validate() error {
+	names := make(map[string]bool)
+	tokens := make(map[string]bool)
+	for _, p := range a.Principals {
+		if p.Name == "" {
+			return errors.New("Principal without name")
+		}
+		if names[p.Name] {
+			return errors.New("Duplicate principal: " + p.Name)
+		}
+		names[p.Name] = true
+		for _, cmd := range p.Commands {
+			if _, ok := ItemMessage_Commands_value[cmd]; !ok {
+				return errors.New("Unknown command " + cmd + " of principal " + p.Name)
+			}
+		}
+		for _, token := range p.Tokens {
+			if token == "" || tokens[token] {
+				return errors.New("Empty or duplicate token of principal " + p.Name)
+			}
+			tokens[token] = true
+		}
+	}
+	if a.Anonymous != "" && !names[a.Anonymous] {
+		return errors.New("Unknown anonymous principal: " + a.Anonymous)
+	}
+	return nil
+}
+
+//Authenticate return principal of token
+func (a *ACL) Authenticate(token string) (*Principal, error) {
+	var found *Principal
+	for _, p := range a.Principals {
+		for _, t := range p.Tokens {
+			if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+				found = p
+			}
+		}
+	}
+	if found == nil {
+		return nil, ErrUnauthorized
+	}
+	return found, nil
+}
+
+//principal return principal with name or nil
+func (a *ACL) principal(name string) *Principal {
+	if name == "" {
+		return nil
+	}
+	for _, p := range a.Principals {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+//identify return principal of client certificate identity or anonymous principal
+func (a *ACL) identify(identity string) *Principal {
+	if identity != "" {
+		for _, p := range a.Principals {
+			if containsString(p.Identities, identity) {
+				return p
+			}
+		}
+	}
+	return a.principal(a.Anonymous)
+}
+
+//Security is an access control of server, nil Security or Security without ACL allow everything
+type Security struct {
+	ACL *ACL        //use SetACL to change it while server is running
+	TLS *TLSOptions //identity of client certificate is mapped to principal if set
+
+	l sync.RWMutex
+}
+
+//SetACL replace access control list, next requests of open connections use it too
+func (s *Security) SetACL(acl *ACL) {
+	s.l.Lock()
+	s.ACL = acl
+	s.l.Unlock()
+}
+
+func (s *Security) acl() *ACL {
+	if s == nil {
+		return nil
+	}
+	s.l.RLock()
+	defer s.l.RUnlock()
+	return s.ACL
+}
+
+//session return authentication state of connection, conn can be nil for udp
+func (s *Security) session(conn net.Conn) *session {
+	if s == nil {
+		return nil
+	}
+	identity := ""
+	if s.TLS != nil && conn != nil {
+		identity = s.TLS.ConnIdentity(conn)
+	}
+	return &session{sec: s, identity: identity}
+}
+
+//requestSession return session of http request, token is read from "Authorization: Bearer <token>" header
+func (s *Security) requestSession(r *http.Request) (*session, error) {
+	if s.acl() == nil {
+		return nil, nil
+	}
+	identity := ""
+	if s.TLS != nil {
+		identity = s.TLS.Identity(r.TLS)
+	}
+	return s.authorizedSession(identity, r.Header.Get("Authorization"))
+}
+
+//authorizedSession return session of client certificate identity, it is authenticated by authorization "Bearer <token>" if it is set
+func (s *Security) authorizedSession(identity, authorization string) (*session, error) {
+	sess := &session{sec: s, identity: identity}
+	if authorization == "" {
+		return sess, nil
+	}
+	if !strings.HasPrefix(authorization, bearerPrefix) {
+		return sess, ErrUnauthorized
+	}
+	return sess, sess.authenticate(strings.TrimPrefix(authorization, bearerPrefix))
+}
+
+const bearerPrefix = "Bearer "
+
+//session is an authentication state of one connection, nil session allow everything
+//Principal is found in current ACL on every request, so changes of ACL are applied to open connections
+type session struct {
+	sec      *Security
+	identity string //identity of client certificate
+	user     string //name of principal authenticated by token, principal of identity is used if empty
+}
+
+//principal return current principal of session or nil
+func (s *session) principal(acl *ACL) *Principal {
+	if s.user != "" {
+		return acl.principal(s.user)
+	}
+	return acl.identify(s.identity)
+}
+
+//controlled return false if access is not controlled
+func (s *session) controlled() bool {
+	return s != nil && s.sec.acl() != nil
+}
+
+//authenticate switch session to principal of token, principal is not changed if token is wrong
+func (s *session) authenticate(token string) error {
+	_, err := s.authenticateUser("", token)
+	return err
+}
+
+//authenticateUser is authenticate which also check principal name if user is not empty
+func (s *session) authenticateUser(user, token string) (*Principal, error) {
+	if !s.controlled() {
+		return nil, nil
+	}
+	p, err := s.sec.acl().Authenticate(token)
+	if err != nil {
+		return nil, err
+	}
+	if user != "" && user != p.Name {
+		return nil, ErrUnauthorized
+	}
+	s.user = p.Name
+	return p, nil
+}
+
+func (s *session) allow(cmd ItemMessage_Commands, name string) error {
+	if s == nil {
+		return nil
+	}
+	acl := s.sec.acl()
+	if acl == nil {
+		return nil
+	}
+	return s.principal(acl).Allow(cmd, name)
+}
+
+//name return name of current principal, empty if it is anonymous or access is not controlled
+func (s *session) name() string {
+	if s == nil {
+		return ""
+	}
+	acl := s.sec.acl()
+	if acl == nil {
+		return ""
+	}
+	if p := s.principal(acl); p != nil {
+		return p.Name
+	}
+	return ""
+}
+
+//handleMessage authenticate AUTH command or request with Token, check permission and apply request
+func (s *session) handleMessage(t *ItemMessage, cache Cacher) (*ItemMessage, error) {
+	if t.Command == ItemMessage_AUTH || t.GetToken() != "" {
+		if err := s.authenticate(t.GetToken()); err != nil {
+			return failedMessage(t, err), err
+		}
+		if t.Command == ItemMessage_AUTH {
+			return &ItemMessage{Command: ItemMessage_AUTH, Name: s.name(), RequestID: t.GetRequestID()}, nil
+		}
+	}
+	if err := s.allow(t.Command, t.GetName()); err != nil {
+		return failedMessage(t, err), err
+	}
+	return handleMessage(t, cache)
+}
+
+//handleRequest is handleMessage with responce appended to dst by codec, responce is released
+func (s *session) handleRequest(t *ItemMessage, cache Cacher, cd codec, dst []byte) ([]byte, error) {
+	resp, err := s.handleMessage(t, cache)
+	dst, _ = cd.appendMessage(dst, resp)
+	releaseMessage(resp)
+	return dst, err
+}
+
+//failedMessage is a responce for request which is not applied
+func failedMessage(t *ItemMessage, err error) *ItemMessage {
+	return &ItemMessage{
+		Command:   t.Command,
+		Name:      t.GetName(),
+		RequestID: t.GetRequestID(),
+		Status:    errorStatus(err),
+		Error:     err.Error(),
+	}
+}
@@ -0,0 +1,169 @@
+package gcache
+
+import (
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//testACL has admin, application restricted to "app:" names and read only guest for "public:" names
+func testACL() *ACL {
+	return &ACL{
+		Anonymous: "guest",
+		Principals: []*Principal{
+			{Name: "admin", Admin: true, Tokens: []string{"admin-secret"}},
+			{
+				Name:       "app",
+				Commands:   []string{"GET", "SET", "DELETE", "INCR", "DECR", "ADD", "REPLACE", "CAS", "STATS"},
+				Prefixes:   []string{"app:"},
+				Tokens:     []string{"app-secret"},
+				Identities: []string{"application"},
+			},
+			{Name: "guest", Commands: []string{"GET", "STATS"}, Prefixes: []string{"public:"}},
+		},
+	}
+}
+
+func TestPrincipal_Allow(t *testing.T) {
+	as := assert.New(t)
+	acl := testACL()
+	admin, app, guest := acl.Principals[0], acl.Principals[1], acl.Principals[2]
+	for _, tc := range []struct {
+		p    *Principal
+		cmd  ItemMessage_Commands
+		name string
+		err  error
+	}{
+		{admin, ItemMessage_DEAD, "", nil},
+		{admin, ItemMessage_SET, "any", nil},
+		{app, ItemMessage_SET, "app:1", nil},
+		{app, ItemMessage_SET, "other:1", ErrForbidden},
+		{app, ItemMessage_STATS, "", nil},
+		{app, ItemMessage_PURGE, "", ErrForbidden},
+		{app, ItemMessage_KEYS, "", ErrForbidden},
+		{app, ItemMessage_DEAD, "", ErrForbidden},
+		{guest, ItemMessage_GET, "public:1", nil},
+		{guest, ItemMessage_SET, "public:1", ErrForbidden},
+		{&Principal{Name: "all"}, ItemMessage_INCR, "any", nil},
+		{&Principal{Name: "all"}, ItemMessage_PURGE, "", ErrForbidden},
+		{nil, ItemMessage_GET, "public:1", ErrUnauthorized},
+	} {
+		as.Equal(tc.err, tc.p.Allow(tc.cmd, tc.name), "%v %s %s", tc.p, tc.cmd, tc.name)
+	}
+}
+
+func TestACL_Load(t *testing.T) {
+	as := assert.New(t)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "acl.json")
+	ioutil.WriteFile(path, []byte(`{
+		"anonymous": "guest",
+		"principals": [
+			{"name": "admin", "admin": true, "tokens": ["admin-secret"]},
+			{"name": "guest", "commands": ["GET"], "prefixes": ["public:"]}
+		]
+	}`), 0600)
+	acl, err := LoadACL(path)
+	if as.NoError(err) {
+		as.Len(acl.Principals, 2)
+		p, err := acl.Authenticate("admin-secret")
+		as.NoError(err)
+		as.Equal("admin", p.Name)
+		_, err = acl.Authenticate("wrong")
+		as.Equal(ErrUnauthorized, err)
+		as.Equal("guest", acl.identify("").Name)
+	}
+
+	for _, wrong := range []*ACL{
+		{Principals: []*Principal{{Name: ""}}},
+		{Principals: []*Principal{{Name: "a"}, {Name: "a"}}},
+		{Principals: []*Principal{{Name: "a", Commands: []string{"FLY"}}}},
+		{Principals: []*Principal{{Name: "a", Tokens: []string{"t"}}, {Name: "b", Tokens: []string{"t"}}}},
+		{Anonymous: "guest"},
+	} {
+		as.Error(wrong.Validate())
+	}
+}
+
+func TestSession_HandleMessage(t *testing.T) {
+	as := assert.New(t)
+	c := NewRwCache(&ConfigMessage{})
+	sec := &Security{ACL: testACL()}
+	sess := sec.session(nil)
+
+	resp, err := sess.handleMessage(&ItemMessage{Command: ItemMessage_SET, Name: "app:1", Object: []byte(`zaza`)}, c)
+	as.Equal(ErrForbidden, err, "guest could not write")
+	as.Equal(ItemMessage_UNAUTHORIZED, resp.Status)
+
+	resp, err = sess.handleMessage(&ItemMessage{Command: ItemMessage_AUTH, Token: "wrong", RequestID: 5}, c)
+	as.Equal(ErrUnauthorized, err)
+	as.Equal(uint64(5), resp.RequestID)
+	resp, err = sess.handleMessage(&ItemMessage{Command: ItemMessage_AUTH, Token: "app-secret"}, c)
+	as.NoError(err)
+	as.Equal("app", resp.Name)
+
+	_, err = sess.handleMessage(&ItemMessage{Command: ItemMessage_SET, Name: "app:1", Object: []byte(`zaza`)}, c)
+	as.NoError(err)
+	as.Equal([]byte(`zaza`), c.Get("app:1"))
+	_, err = sess.handleMessage(&ItemMessage{Command: ItemMessage_DEAD}, c)
+	as.Equal(ErrForbidden, err)
+
+	_, err = sec.session(nil).handleMessage(&ItemMessage{Command: ItemMessage_PURGE, Token: "admin-secret"}, c)
+	as.NoError(err, "token of single request")
+	as.Nil(c.Get("app:1"))
+
+	noACL := (*Security)(nil).session(nil)
+	resp, err = noACL.handleMessage(&ItemMessage{Command: ItemMessage_AUTH, Token: "any"}, c)
+	as.NoError(err, "AUTH is accepted without acl")
+	as.Equal(ItemMessage_OK, resp.Status)
+
+	c.Dead() //Cleanup
+}
+
+func TestHandleLongTCP_Auth(t *testing.T) {
+	as := assert.New(t)
+	inner := NewRwCache(&ConfigMessage{})
+	ln, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if !as.NoError(err) {
+		return
+	}
+	defer ln.Close()
+	go handleLongTCP(ln, inner, &Security{ACL: testACL()})
+
+	_, err = NewRemoteCache(ln.Addr().String(), RemoteOptions{Token: "wrong"})
+	as.Equal(ErrUnauthorized, err)
+
+	app, err := NewRemoteCache(ln.Addr().String(), RemoteOptions{Token: "app-secret", Connections: 2})
+	if !as.NoError(err) {
+		return
+	}
+	_, err = app.Add("app:1", []byte(`zaza`), NoExpiration)
+	as.NoError(err)
+	_, err = app.Add("other:1", []byte(`zaza`), NoExpiration)
+	as.Equal(ErrForbidden, err)
+	as.Nil(app.Keys(), "KEYS require admin")
+	app.Purge()
+	as.Equal([]byte(`zaza`), inner.Get("app:1"), "PURGE require admin")
+
+	guest, err := NewRemoteCache(ln.Addr().String(), RemoteOptions{})
+	if as.NoError(err) {
+		inner.SetOrUpdate("public:1", []byte(`azaz`), NoExpiration)
+		as.Equal([]byte(`azaz`), guest.Get("public:1"))
+		as.Nil(guest.Get("app:1"))
+		guest.Dead()
+	}
+
+	admin, err := NewRemoteCache(ln.Addr().String(), RemoteOptions{Token: "admin-secret"})
+	if as.NoError(err) {
+		as.Len(admin.Keys(), 2)
+		admin.Purge()
+		as.Nil(inner.Get("app:1"))
+		admin.Dead()
+	}
+
+	app.Dead()
+	inner.Dead() //Cleanup
+}
@@ -0,0 +1,560 @@
+package gcache
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DefaultVirtualNodes       = 160         //points of every server on hash ring of ClusterCache
+	DefaultClusterHealthCheck = time.Second //interval of health checks of ClusterCache servers
+)
+
+//ErrNodeDown is returned by ClusterCache when there is no healthy server for item
+var ErrNodeDown = errors.New("Cluster node is unhealthy")
+
+//ClusterOptions is a settings of ClusterCache
+type ClusterOptions struct {
+	Remote         RemoteOptions //options of every server, Timeout should be set to detect unresponsive server
+	Replicas       int           //number of servers which store every item, 1 if zero
+	Failover       bool          //items of unhealthy server are stored and read on the next server of ring
+	PurgeRecovered bool          //purge server when it become healthy, so items changed meanwhile are not stale. It is always set with Failover
+	VirtualNodes   int           //DefaultVirtualNodes if zero
+	HashFunc       string        //hash of ring, fnv by default, see ConfigMessage.HashFunc
+	HealthCheck    time.Duration //interval of PING of servers, DefaultClusterHealthCheck if zero
+}
+
+//ClusterCache spread items across gcache servers by consistent hash of server addresses, it implements Cacher.
+//Item is stored on Replicas servers which follow its name on hash ring, conditional requests and counters are applied
+// on the first of them and the result is copied to others.
+//Server which fails without responce is unhealthy until successful health check, requests are not sent to it
+// and its items are missing, or with Failover they are stored and read on the next healthy server.
+//Requests to all servers like Purge and Statistic are done by ShardCache of servers, items of replicas are counted on every server
+type ClusterCache struct {
+	*ShardCache
+	nodes []*clusterNode
+	ring  *hashRing
+	opts  ClusterOptions
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+//NewClusterCache create client of servers, server which is not available now is used after successful health check
+func NewClusterCache(addresses []string, opts ClusterOptions) (*ClusterCache, error) {
+	if len(addresses) == 0 {
+		return nil, errors.New("No cluster servers")
+	}
+	hashCalc, ok := hashFuncs[opts.HashFunc]
+	if !ok {
+		return nil, errors.New("Unknown hash function: " + opts.HashFunc)
+	}
+	if opts.Replicas <= 0 {
+		opts.Replicas = 1
+	}
+	if opts.Replicas > len(addresses) {
+		opts.Replicas = len(addresses)
+	}
+	if opts.VirtualNodes <= 0 {
+		opts.VirtualNodes = DefaultVirtualNodes
+	}
+	if opts.HealthCheck <= 0 {
+		opts.HealthCheck = DefaultClusterHealthCheck
+	}
+	if opts.Failover {
+		opts.PurgeRecovered = true //items of recovered server are written to the next one meanwhile
+	}
+	var (
+		nodes  = make([]*clusterNode, len(addresses))
+		shards = make([]Cacher, len(addresses))
+		seen   = make(map[string]bool)
+	)
+	for i, address := range addresses {
+		if seen[address] {
+			return nil, errors.New("Duplicate cluster server: " + address)
+		}
+		seen[address] = true
+		n := &clusterNode{address: address, opts: opts.Remote}
+		if remote, err := NewRemoteCache(address, opts.Remote); err == nil {
+			n.remote = remote
+		} else {
+			n.down = 1
+		}
+		nodes[i], shards[i] = n, n
+	}
+	c := &ClusterCache{
+		ShardCache: newShardCacheOf(shards, hashCalc),
+		nodes:      nodes,
+		ring:       newHashRing(addresses, opts.VirtualNodes, hashCalc),
+		opts:       opts,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	go c.checkHealth()
+	return c, nil
+}
+
+//candidates return indexes of servers of item in order of hash ring, other servers are added with Failover
+func (c *ClusterCache) candidates(name string) []int {
+	order := c.ring.lookup(name)
+	if !c.opts.Failover && len(order) > c.opts.Replicas {
+		order = order[:c.opts.Replicas]
+	}
+	return order
+}
+
+//write run f on Replicas healthy servers of item except skip, written is a number of servers which already have item
+func (c *ClusterCache) write(name string, written int, skip *clusterNode, f func(n *clusterNode)) {
+	for _, i := range c.candidates(name) {
+		if written >= c.opts.Replicas {
+			return
+		}
+		n := c.nodes[i]
+		if n == skip || !n.healthy() {
+			continue
+		}
+		f(n)
+		if n.healthy() { //failed server is replaced by next one
+			written++
+		}
+	}
+}
+
+//update apply f on the first healthy server of item and copy value returned by f to other replicas
+func (c *ClusterCache) update(name string, exp time.Duration, f func(n *clusterNode) ([]byte, error)) error {
+	for _, i := range c.candidates(name) {
+		n := c.nodes[i]
+		if !n.healthy() {
+			continue
+		}
+		value, err := f(n)
+		if !n.healthy() {
+			continue
+		}
+		if err == nil && c.opts.Replicas > 1 {
+			c.write(name, 1, n, func(replica *clusterNode) { replica.SetOrUpdate(name, value, exp) })
+		}
+		return err
+	}
+	return ErrNodeDown
+}
+
+//Get return value or nil if it is missing or its servers are unhealthy
+func (c *ClusterCache) Get(name string) []byte {
+	if itm := c.GetItem(name); itm != nil {
+		return itm.Object
+	}
+	return nil
+}
+
+//GetItem return item of the first healthy server of item
+func (c *ClusterCache) GetItem(name string) *Item {
+	for _, i := range c.candidates(name) {
+		n := c.nodes[i]
+		if !n.healthy() {
+			continue
+		}
+		if itm := n.GetItem(name); itm != nil || n.healthy() {
+			return itm
+		}
+	}
+	return nil
+}
+
+//GetMulti return values of found names, servers are requested concurrently and names of unhealthy servers are missing
+func (c *ClusterCache) GetMulti(names []string) map[string][]byte {
+	groups := make(map[int][]string)
+	for _, name := range names {
+		for _, i := range c.candidates(name) {
+			if c.nodes[i].healthy() {
+				groups[i] = append(groups[i], name)
+				break
+			}
+		}
+	}
+	var (
+		l      sync.Mutex
+		wg     sync.WaitGroup
+		values = make(map[string][]byte, len(names))
+	)
+	for i, group := range groups {
+		wg.Add(1)
+		go func(n *clusterNode, group []string) {
+			defer wg.Done()
+			for _, name := range group {
+				if value := n.Get(name); value != nil {
+					l.Lock()
+					values[name] = value
+					l.Unlock()
+				}
+			}
+		}(c.nodes[i], group)
+	}
+	wg.Wait()
+	return values
+}
+
+//SetOrUpdate store value on replicas
+func (c *ClusterCache) SetOrUpdate(name string, value []byte, exp time.Duration) {
+	c.write(name, 0, nil, func(n *clusterNode) { n.SetOrUpdate(name, value, exp) })
+}
+
+//Incr add delta on the first healthy server of item, result is copied to other replicas
+func (c *ClusterCache) Incr(name string, delta int64, exp time.Duration) (int64, error) {
+	return c.incr(name, exp, func(n *clusterNode) (int64, error) { return n.Incr(name, delta, exp) })
+}
+
+//Decr subtract delta on the first healthy server of item, result is copied to other replicas
+func (c *ClusterCache) Decr(name string, delta int64, exp time.Duration) (int64, error) {
+	return c.incr(name, exp, func(n *clusterNode) (int64, error) { return n.Decr(name, delta, exp) })
+}
+
+func (c *ClusterCache) incr(name string, exp time.Duration, f func(n *clusterNode) (int64, error)) (int64, error) {
+	var result int64
+	err := c.update(name, exp, func(n *clusterNode) (value []byte, err error) {
+		result, err = f(n)
+		return strconv.AppendInt(nil, result, 10), err
+	})
+	return result, err
+}
+
+//Add set item only if it is absent on the first healthy server of item
+func (c *ClusterCache) Add(name string, value []byte, exp time.Duration) (uint64, error) {
+	return c.versioned(name, value, exp, func(n *clusterNode) (uint64, error) { return n.Add(name, value, exp) })
+}
+
+//Replace set item only if it is present on the first healthy server of item
+func (c *ClusterCache) Replace(name string, value []byte, exp time.Duration) (uint64, error) {
+	return c.versioned(name, value, exp, func(n *clusterNode) (uint64, error) { return n.Replace(name, value, exp) })
+}
+
+//CAS set item only if its version is not changed on the first healthy server of item
+func (c *ClusterCache) CAS(name string, value []byte, version uint64, exp time.Duration) (uint64, error) {
+	return c.versioned(name, value, exp, func(n *clusterNode) (uint64, error) { return n.CAS(name, value, version, exp) })
+}
+
+func (c *ClusterCache) versioned(name string, value []byte, exp time.Duration, f func(n *clusterNode) (uint64, error)) (uint64, error) {
+	var version uint64
+	err := c.update(name, exp, func(n *clusterNode) (_ []byte, err error) {
+		version, err = f(n)
+		return value, err
+	})
+	return version, err
+}
+
+//StoreFlags write item by cmd on replicas, client flags of memcached protocol are not sent to servers
+func (c *ClusterCache) StoreFlags(cmd ItemMessage_Commands, name string, value []byte, flags uint32, version uint64, exp time.Duration) (uint64, error) {
+	return storeValue(c, cmd, name, value, version, exp)
+}
+
+//Delete remove item from replicas, false mean it is missing on all of them
+func (c *ClusterCache) Delete(name string) bool {
+	deleted := false
+	c.write(name, 0, nil, func(n *clusterNode) {
+		if n.Delete(name) {
+			deleted = true
+		}
+	})
+	return deleted
+}
+
+//Keys return names of items of healthy servers, item of replicas is reported once
+func (c *ClusterCache) Keys() []string {
+	keys := c.ShardCache.Keys()
+	seen := make(map[string]bool, len(keys))
+	unique := keys[:0]
+	for _, key := range keys {
+		if !seen[key] {
+			seen[key] = true
+			unique = append(unique, key)
+		}
+	}
+	return unique
+}
+
+//Dead stop health checks and close connections, servers are not stopped
+func (c *ClusterCache) Dead() {
+	close(c.stop)
+	<-c.done
+	c.ShardCache.Dead()
+}
+
+//checkHealth ping servers until Dead
+func (c *ClusterCache) checkHealth() {
+	defer close(c.done)
+	ticker := time.NewTicker(c.opts.HealthCheck)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+		}
+		var wg sync.WaitGroup
+		for _, n := range c.nodes {
+			wg.Add(1)
+			go func(n *clusterNode) {
+				defer wg.Done()
+				n.check(c.opts.HealthCheck, c.opts.PurgeRecovered)
+			}(n)
+		}
+		wg.Wait()
+	}
+}
+
+//hashRing is a consistent hash of server addresses, every server has many points on ring so items are spread evenly
+// and only items of removed server are moved
+type hashRing struct {
+	points []uint64 //sorted
+	owners []int    //server of point
+	nodes  int
+	hash   HashCalculator
+}
+
+func newHashRing(addresses []string, virtual int, hashCalc HashCalculator) *hashRing {
+	type point struct {
+		hash  uint64
+		owner int
+	}
+	points := make([]point, 0, len(addresses)*virtual)
+	for i, address := range addresses {
+		for v := 0; v < virtual; v++ {
+			points = append(points, point{mix64(hashCalc(address + "#" + strconv.Itoa(v))), i})
+		}
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].hash < points[j].hash })
+	r := &hashRing{
+		points: make([]uint64, len(points)),
+		owners: make([]int, len(points)),
+		nodes:  len(addresses),
+		hash:   hashCalc,
+	}
+	for i, p := range points {
+		r.points[i], r.owners[i] = p.hash, p.owner
+	}
+	return r
+}
+
+//lookup return all servers in order of ring starting from point of name
+func (r *hashRing) lookup(name string) []int {
+	var (
+		order = make([]int, 0, r.nodes)
+		seen  = make([]bool, r.nodes)
+		key   = mix64(r.hash(name))
+		start = sort.Search(len(r.points), func(i int) bool { return r.points[i] >= key })
+	)
+	for i := 0; i < len(r.points) && len(order) < r.nodes; i++ {
+		owner := r.owners[(start+i)%len(r.points)]
+		if !seen[owner] {
+			seen[owner] = true
+			order = append(order, owner)
+		}
+	}
+	return order
+}
+
+//mix64 is a finalizer of murmur3, it spread high bits of fast hashes of similar strings over ring
+func mix64(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+//clusterNode is a RemoteCache of one server of cluster, requests are not sent to unhealthy server and its items are missing.
+//Server become unhealthy when request fails without responce and healthy again after successful PING
+type clusterNode struct {
+	address string
+	opts    RemoteOptions
+	down    int32 //1 if server is unhealthy
+
+	l      sync.Mutex
+	remote *RemoteCache //nil until server is dialed
+}
+
+func (n *clusterNode) healthy() bool {
+	return atomic.LoadInt32(&n.down) == 0
+}
+
+//client return RemoteCache of healthy server or nil
+func (n *clusterNode) client() *RemoteCache {
+	if !n.healthy() {
+		return nil
+	}
+	n.l.Lock()
+	defer n.l.Unlock()
+	return n.remote
+}
+
+//observe mark server unhealthy if request failed without responce
+func (n *clusterNode) observe(err error) {
+	if unavailable(err) {
+		atomic.StoreInt32(&n.down, 1)
+	}
+}
+
+//check ping server and dial it if it was not available before, unhealthy server is purged before use if purge is set
+func (n *clusterNode) check(timeout time.Duration, purge bool) {
+	n.l.Lock()
+	if n.remote == nil {
+		remote, err := NewRemoteCache(n.address, n.opts)
+		if err != nil {
+			n.l.Unlock()
+			return
+		}
+		n.remote = remote
+	}
+	remote := n.remote
+	n.l.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	remote = remote.WithContext(ctx)
+	if err := remote.Ping(); unavailable(err) {
+		atomic.StoreInt32(&n.down, 1)
+		return
+	}
+	if n.healthy() {
+		return
+	}
+	if purge && unavailable(remote.purge()) {
+		return
+	}
+	atomic.StoreInt32(&n.down, 0)
+}
+
+//Get return value or nil if it is missing or server is unhealthy
+func (n *clusterNode) Get(name string) []byte {
+	if itm := n.GetItem(name); itm != nil {
+		return itm.Object
+	}
+	return nil
+}
+
+//GetItem return copy of item or nil
+func (n *clusterNode) GetItem(name string) *Item {
+	remote := n.client()
+	if remote == nil {
+		return nil
+	}
+	itm, err := remote.getItem(name)
+	n.observe(err)
+	return itm
+}
+
+//SetOrUpdate store value if server is healthy
+func (n *clusterNode) SetOrUpdate(name string, value []byte, exp time.Duration) {
+	if remote := n.client(); remote != nil {
+		n.observe(remote.setOrUpdate(name, value, exp))
+	}
+}
+
+//Incr atomically increase integer value on server
+func (n *clusterNode) Incr(name string, delta int64, exp time.Duration) (int64, error) {
+	remote := n.client()
+	if remote == nil {
+		return 0, ErrNodeDown
+	}
+	value, err := remote.Incr(name, delta, exp)
+	n.observe(err)
+	return value, err
+}
+
+//Decr atomically decrease integer value on server
+func (n *clusterNode) Decr(name string, delta int64, exp time.Duration) (int64, error) {
+	remote := n.client()
+	if remote == nil {
+		return 0, ErrNodeDown
+	}
+	value, err := remote.Decr(name, delta, exp)
+	n.observe(err)
+	return value, err
+}
+
+//Add store value only if name is missing
+func (n *clusterNode) Add(name string, value []byte, exp time.Duration) (uint64, error) {
+	remote := n.client()
+	if remote == nil {
+		return 0, ErrNodeDown
+	}
+	version, err := remote.Add(name, value, exp)
+	n.observe(err)
+	return version, err
+}
+
+//Replace store value only if name is present
+func (n *clusterNode) Replace(name string, value []byte, exp time.Duration) (uint64, error) {
+	remote := n.client()
+	if remote == nil {
+		return 0, ErrNodeDown
+	}
+	version, err := remote.Replace(name, value, exp)
+	n.observe(err)
+	return version, err
+}
+
+//CAS store value only if current version is equal to version
+func (n *clusterNode) CAS(name string, value []byte, version uint64, exp time.Duration) (uint64, error) {
+	remote := n.client()
+	if remote == nil {
+		return 0, ErrNodeDown
+	}
+	version, err := remote.CAS(name, value, version, exp)
+	n.observe(err)
+	return version, err
+}
+
+//Delete remove item, false mean item is missing or server is unhealthy
+func (n *clusterNode) Delete(name string) bool {
+	remote := n.client()
+	if remote == nil {
+		return false
+	}
+	err := remote.delete(name)
+	n.observe(err)
+	return err == nil
+}
+
+//Keys return names of items on server, nil if server is unhealthy
+func (n *clusterNode) Keys() []string {
+	remote := n.client()
+	if remote == nil {
+		return nil
+	}
+	keys, err := remote.keys()
+	n.observe(err)
+	return keys
+}
+
+//Purge remove all items on server if it is healthy
+func (n *clusterNode) Purge() {
+	if remote := n.client(); remote != nil {
+		n.observe(remote.purge())
+	}
+}
+
+//Dead close connections to server
+func (n *clusterNode) Dead() {
+	n.l.Lock()
+	defer n.l.Unlock()
+	if n.remote != nil {
+		n.remote.Dead()
+	}
+}
+
+//Statistic return statistic of server, zero Stats if server is unhealthy
+func (n *clusterNode) Statistic() Stats {
+	remote := n.client()
+	if remote == nil {
+		return Stats{}
+	}
+	stats, err := remote.statistic()
+	n.observe(err)
+	return stats
+}
@@ -0,0 +1,297 @@
+package gcache
+
+import (
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//startCluster start servers with own caches
+func startCluster(t *testing.T, count int) ([]string, []Cacher, []net.Listener) {
+	var (
+		addresses = make([]string, count)
+		caches    = make([]Cacher, count)
+		listeners = make([]net.Listener, count)
+	)
+	for i := range caches {
+		caches[i] = NewRwCache(defaultConfig())
+		ln := startLongTCP(t, caches[i])
+		addresses[i], listeners[i] = ln.Addr().String(), ln
+	}
+	return addresses, caches, listeners
+}
+
+func stopCluster(caches []Cacher, listeners []net.Listener) {
+	for i := range caches {
+		listeners[i].Close()
+		caches[i].Dead()
+	}
+}
+
+//waitHealthy wait until health of node is equal to healthy
+func waitHealthy(n *clusterNode, healthy bool) bool {
+	for i := 0; i < 500; i++ {
+		if n.healthy() == healthy {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
+
+func TestHashRing(t *testing.T) {
+	as := assert.New(t)
+	addresses := []string{"10.0.0.1:2000", "10.0.0.2:2000", "10.0.0.3:2000", "10.0.0.4:2000"}
+	ring := newHashRing(addresses, DefaultVirtualNodes, hashFuncs[""])
+	smaller := newHashRing(addresses[:3], DefaultVirtualNodes, hashFuncs[""])
+
+	const total = 20000
+	var (
+		counts = make([]int, len(addresses))
+		moved  = 0
+	)
+	for i := 0; i < total; i++ {
+		name := "key:" + strconv.Itoa(i)
+		order := ring.lookup(name)
+		as.Len(order, len(addresses), name)
+		counts[order[0]]++
+		if order[0] != smaller.lookup(name)[0] {
+			moved++
+			as.Equal(3, order[0], "only items of removed server are moved")
+		}
+	}
+	for i, count := range counts {
+		as.InDelta(total/len(addresses), count, total/10, "balance of %s", addresses[i])
+	}
+	as.Equal(counts[3], moved)
+}
+
+func TestClusterCache(t *testing.T) {
+	as := assert.New(t)
+	addresses, caches, listeners := startCluster(t, 3)
+	defer stopCluster(caches, listeners)
+
+	_, err := NewClusterCache(nil, ClusterOptions{})
+	as.Error(err)
+	_, err = NewClusterCache([]string{addresses[0], addresses[0]}, ClusterOptions{})
+	as.Error(err)
+	_, err = NewClusterCache(addresses, ClusterOptions{HashFunc: "zaza"})
+	as.Error(err)
+
+	for _, replicas := range []int{1, 2} {
+		for _, cache := range caches {
+			cache.Purge()
+		}
+		c, err := NewClusterCache(addresses, ClusterOptions{Replicas: replicas})
+		if !as.NoError(err) {
+			return
+		}
+		const total = 300
+		for i := 0; i < total; i++ {
+			c.SetOrUpdate("key:"+strconv.Itoa(i), []byte(strconv.Itoa(i)), NoExpiration)
+		}
+		stored := 0
+		for _, cache := range caches {
+			count := len(cache.Keys())
+			as.NotZero(count, "items are spread across servers")
+			stored += count
+		}
+		as.Equal(total*replicas, stored)
+		as.Equal(int64(total*replicas), c.Statistic().ItemsCount)
+		as.Len(c.Keys(), total, "replicas are reported once")
+		for i := 0; i < total; i++ {
+			as.Equal([]byte(strconv.Itoa(i)), c.Get("key:"+strconv.Itoa(i)))
+		}
+		as.Nil(c.Get("missing"))
+
+		value, err := c.Incr("counter", 5, NoExpiration)
+		as.NoError(err)
+		as.Equal(int64(5), value)
+		value, err = c.Decr("counter", 2, NoExpiration)
+		as.NoError(err)
+		as.Equal(int64(3), value)
+		copies := 0
+		for _, cache := range caches {
+			if v := cache.Get("counter"); v != nil {
+				as.Equal([]byte(`3`), v, "counter is copied to replicas")
+				copies++
+			}
+		}
+		as.Equal(replicas, copies)
+
+		version, err := c.Add("added", []byte(`zaza`), NoExpiration)
+		as.NoError(err)
+		_, err = c.Add("added", []byte(`other`), NoExpiration)
+		as.Equal(ErrExists, err)
+		_, err = c.CAS("added", []byte(`other`), version+1, NoExpiration)
+		as.Equal(ErrVersionMismatch, err)
+		_, err = c.CAS("added", []byte(`cas`), version, NoExpiration)
+		as.NoError(err)
+		as.Equal([]byte(`cas`), c.Get("added"))
+		_, err = c.Replace("missing", []byte(`zaza`), NoExpiration)
+		as.Equal(ErrNotFound, err)
+
+		as.True(c.Delete("added"))
+		as.False(c.Delete("added"))
+		for _, cache := range caches {
+			as.Nil(cache.Get("added"), "item is deleted from replicas")
+		}
+
+		c.Purge()
+		as.Empty(c.Keys())
+		c.Dead()
+	}
+}
+
+func TestClusterCache_Unhealthy(t *testing.T) {
+	as := assert.New(t)
+	addresses, caches, listeners := startCluster(t, 2)
+	defer stopCluster(caches, listeners)
+	hole := startBlackHole(t)
+	defer hole.Close()
+	addresses = append(addresses, hole.Addr().String())
+
+	opts := ClusterOptions{Remote: RemoteOptions{Timeout: 50 * time.Millisecond}, HealthCheck: time.Hour}
+	c, err := NewClusterCache(addresses, opts)
+	if !as.NoError(err) {
+		return
+	}
+
+	var (
+		names []string //names of unresponsive server
+		other string   //name of available server
+	)
+	for i := 0; len(names) < 5 || other == ""; i++ {
+		name := "key:" + strconv.Itoa(i)
+		if c.candidates(name)[0] == 2 {
+			names = append(names, name)
+		} else {
+			other = name
+		}
+	}
+	as.Nil(c.Get(names[0]), "responce timeout is a miss")
+	as.False(c.nodes[2].healthy())
+	start := time.Now()
+	for _, name := range names {
+		c.SetOrUpdate(name, []byte(`zaza`), NoExpiration)
+		as.Nil(c.Get(name))
+	}
+	as.True(time.Since(start) < 50*time.Millisecond, "unhealthy server is not requested")
+	_, err = c.Incr(names[0], 1, NoExpiration)
+	as.Equal(ErrNodeDown, err)
+	as.Empty(c.Keys())
+	as.Empty(c.GetMulti(names))
+
+	c.SetOrUpdate(other, []byte(`other`), NoExpiration)
+	as.Equal(map[string][]byte{other: []byte(`other`)}, c.GetMulti(append(names, other)), "other servers are available")
+	c.Dead()
+
+	opts.Failover = true
+	c, err = NewClusterCache(addresses, opts)
+	if !as.NoError(err) {
+		return
+	}
+	defer c.Dead()
+	as.Nil(c.Get(names[0]))
+	for _, name := range names {
+		c.SetOrUpdate(name, []byte(`zaza`), NoExpiration)
+		as.Equal([]byte(`zaza`), c.Get(name), "item is stored on the next server")
+	}
+	value, err := c.Incr("counter", 1, NoExpiration)
+	as.NoError(err)
+	as.Equal(int64(1), value)
+	as.Len(c.GetMulti(names), len(names))
+}
+
+func TestClusterCache_Recovery(t *testing.T) {
+	as := assert.New(t)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if !as.NoError(err) {
+		return
+	}
+	address := ln.Addr().String()
+	ln.Close() //server is not started yet
+
+	addresses, caches, listeners := startCluster(t, 1)
+	defer stopCluster(caches, listeners)
+	addresses = append(addresses, address)
+	c, err := NewClusterCache(addresses, ClusterOptions{
+		Remote:         RemoteOptions{Timeout: time.Second},
+		Replicas:       2,
+		Failover:       true,
+		PurgeRecovered: true,
+		HealthCheck:    20 * time.Millisecond,
+	})
+	if !as.NoError(err) {
+		return
+	}
+	defer c.Dead()
+	as.False(c.nodes[1].healthy(), "server is not available")
+	c.SetOrUpdate("zaza", []byte(`zaza`), NoExpiration)
+	as.Equal([]byte(`zaza`), c.Get("zaza"))
+
+	ln, err = net.Listen("tcp", address)
+	if err != nil {
+		t.Skip("address is reused: ", err)
+	}
+	defer ln.Close()
+	inner := NewRwCache(defaultConfig())
+	defer inner.Dead() //Cleanup
+	inner.SetOrUpdate("stale", []byte(`stale`), NoExpiration)
+	go handleLongTCP(ln.(*net.TCPListener), inner, nil)
+
+	if as.True(waitHealthy(c.nodes[1], true), "server is healthy after check") {
+		as.Nil(inner.Get("stale"), "recovered server is purged")
+		c.SetOrUpdate("zaza", []byte(`new`), NoExpiration)
+		as.Equal([]byte(`new`), inner.Get("zaza"), "recovered server is a replica")
+		as.Equal([]byte(`new`), caches[0].Get("zaza"))
+	}
+}
+
+func TestClusterCache_FailoverRecovery(t *testing.T) {
+	as := assert.New(t)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if !as.NoError(err) {
+		return
+	}
+	address := ln.Addr().String()
+	ln.Close() //server is not started yet
+
+	addresses, caches, listeners := startCluster(t, 1)
+	defer stopCluster(caches, listeners)
+	addresses = append(addresses, address)
+	c, err := NewClusterCache(addresses, ClusterOptions{
+		Remote:      RemoteOptions{Timeout: time.Second},
+		Failover:    true,
+		HealthCheck: 20 * time.Millisecond,
+	})
+	if !as.NoError(err) {
+		return
+	}
+	defer c.Dead()
+	as.True(c.opts.PurgeRecovered, "recovered server is purged with Failover")
+	name := "key:0"
+	for i := 1; c.candidates(name)[0] != 1; i++ {
+		name = "key:" + strconv.Itoa(i)
+	}
+	c.SetOrUpdate(name, []byte(`new`), NoExpiration)
+	as.Equal([]byte(`new`), caches[0].Get(name), "item is stored on the next server")
+
+	ln, err = net.Listen("tcp", address)
+	if err != nil {
+		t.Skip("address is reused: ", err)
+	}
+	defer ln.Close()
+	inner := NewRwCache(defaultConfig())
+	defer inner.Dead() //Cleanup
+	inner.SetOrUpdate(name, []byte(`stale`), NoExpiration)
+	go handleLongTCP(ln.(*net.TCPListener), inner, nil)
+
+	if as.True(waitHealthy(c.nodes[1], true), "server is healthy after check") {
+		as.NotEqual([]byte(`stale`), c.Get(name), "value from before outage is not read")
+		as.Nil(inner.Get(name))
+	}
+}
@@ -0,0 +1,424 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"math/rand"
+	"strconv"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Asuan/gcache"
+	"github.com/Asuan/gcache/internal/client"
+)
+
+const (
+	distUniform = "uniform"
+	distZipfian = "zipfian"
+	distHotspot = "hotspot"
+
+	subBuckets = 16 //precision of latency is about 6%
+)
+
+//workload describe requests of benchmark
+type workload struct {
+	Keys        int
+	Dist        string
+	ZipfS       float64 //skew of zipfian distribution, bigger than 1
+	HotKeys     float64 //share of keys which receive HotOps share of requests in hotspot distribution
+	HotOps      float64
+	ValueMin    int
+	ValueMax    int
+	Reads       float64 //share of gets, the rest are sets
+	Concurrency int
+	Duration    time.Duration
+	Preload     bool //set every key before measurement
+}
+
+func (w *workload) validate() error {
+	switch {
+	case w.Keys <= 0:
+		return errors.New("Number of keys should be positive")
+	case w.Dist != distUniform && w.Dist != distZipfian && w.Dist != distHotspot:
+		return errors.New("Unknown distribution: " + w.Dist)
+	case w.Dist == distZipfian && w.ZipfS <= 1:
+		return errors.New("Zipfian skew should be bigger than 1")
+	case w.HotKeys <= 0 || w.HotKeys > 1 || w.HotOps < 0 || w.HotOps > 1:
+		return errors.New("Hotspot shares should be in (0, 1]")
+	case w.ValueMin < 0 || w.ValueMax < w.ValueMin:
+		return errors.New("Wrong value size")
+	case w.Reads < 0 || w.Reads > 1:
+		return errors.New("Share of reads should be in [0, 1]")
+	case w.Concurrency <= 0 || w.Duration <= 0:
+		return errors.New("Concurrency and duration should be positive")
+	}
+	return nil
+}
+
+//keyGen return generator of key indexes, every worker has own generator
+func (w *workload) keyGen(r *rand.Rand) func() int {
+	switch w.Dist {
+	case distZipfian:
+		zipf := rand.NewZipf(r, w.ZipfS, 1, uint64(w.Keys-1))
+		return func() int { return int(zipf.Uint64()) }
+	case distHotspot:
+		hot := int(w.HotKeys * float64(w.Keys))
+		if hot < 1 {
+			hot = 1
+		}
+		return func() int {
+			if hot == w.Keys || r.Float64() < w.HotOps {
+				return r.Intn(hot)
+			}
+			return hot + r.Intn(w.Keys-hot)
+		}
+	}
+	return func() int { return r.Intn(w.Keys) }
+}
+
+//target is a cache under load, it is shared by workers
+type target interface {
+	get(name string) (bool, error)
+	set(name string, value []byte) error
+	close()
+}
+
+//cacheTarget is in-process cache or grpc client, errors of remote cache are counted as misses
+type cacheTarget struct {
+	cache gcache.Cacher
+}
+
+func (t *cacheTarget) get(name string) (bool, error) {
+	return t.cache.Get(name) != nil, nil
+}
+
+func (t *cacheTarget) set(name string, value []byte) error {
+	t.cache.SetOrUpdate(name, value, gcache.DefaultExpirationMarker)
+	return nil
+}
+
+func (t *cacheTarget) close() {
+	t.cache.Dead()
+}
+
+//clientTarget has a client per worker, so tcp_long worker has own connection
+type clientTarget struct {
+	clients chan client.Client
+}
+
+func newClientTarget(opts client.Options, concurrency int) (*clientTarget, error) {
+	t := &clientTarget{clients: make(chan client.Client, concurrency)}
+	for i := 0; i < concurrency; i++ {
+		c, err := client.New(opts)
+		if err != nil {
+			t.close()
+			return nil, err
+		}
+		t.clients <- c
+	}
+	return t, nil
+}
+
+func (t *clientTarget) get(name string) (bool, error) {
+	c := <-t.clients
+	defer func() { t.clients <- c }()
+	_, err := c.Get(name)
+	if err == gcache.ErrNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (t *clientTarget) set(name string, value []byte) error {
+	c := <-t.clients
+	defer func() { t.clients <- c }()
+	return c.Set(name, value, gcache.DefaultExpirationMarker)
+}
+
+func (t *clientTarget) close() {
+	for {
+		select {
+		case c := <-t.clients:
+			c.Close()
+		default:
+			return
+		}
+	}
+}
+
+//histogram count latencies in log-linear buckets
+type histogram struct {
+	counts [64 * subBuckets]int64
+	total  int64
+	max    time.Duration
+}
+
+func bucket(d time.Duration) int {
+	v := uint64(d)
+	if v < 2*subBuckets {
+		return int(v)
+	}
+	shift := bits.Len64(v) - 5 //leading bit and 4 bits of mantissa are kept
+	return shift*subBuckets + int(v>>uint(shift))
+}
+
+//bucketValue return the lowest duration of bucket
+func bucketValue(i int) time.Duration {
+	if i < 2*subBuckets {
+		return time.Duration(i)
+	}
+	shift := i/subBuckets - 1
+	return time.Duration(uint64(i%subBuckets+subBuckets) << uint(shift))
+}
+
+func (h *histogram) record(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	h.counts[bucket(d)]++
+	h.total++
+	if d > h.max {
+		h.max = d
+	}
+}
+
+func (h *histogram) merge(o *histogram) {
+	for i, n := range o.counts {
+		h.counts[i] += n
+	}
+	h.total += o.total
+	if o.max > h.max {
+		h.max = o.max
+	}
+}
+
+//percentile return latency which is not exceeded by share p of requests
+func (h *histogram) percentile(p float64) time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	rank := int64(p*float64(h.total) + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+	var seen int64
+	for i, n := range h.counts {
+		if seen += n; seen >= rank {
+			if v := bucketValue(i); v < h.max {
+				return v
+			}
+			return h.max
+		}
+	}
+	return h.max
+}
+
+//counters is a result of one worker
+type counters struct {
+	gets, hits, sets, errors int64
+	err                      error //first error
+	get, set                 histogram
+}
+
+func (c *counters) fail(err error) {
+	c.errors++
+	if c.err == nil {
+		c.err = err
+	}
+}
+
+func (c *counters) merge(o *counters) {
+	c.gets += o.gets
+	c.hits += o.hits
+	c.sets += o.sets
+	c.errors += o.errors
+	if c.err == nil {
+		c.err = o.err
+	}
+	c.get.merge(&o.get)
+	c.set.merge(&o.set)
+}
+
+//latency is a summary of histogram
+type latency struct {
+	P50  time.Duration `json:"p50_ns"`
+	P90  time.Duration `json:"p90_ns"`
+	P99  time.Duration `json:"p99_ns"`
+	P999 time.Duration `json:"p999_ns"`
+	Max  time.Duration `json:"max_ns"`
+}
+
+func summary(h *histogram) latency {
+	return latency{
+		P50:  h.percentile(0.5),
+		P90:  h.percentile(0.9),
+		P99:  h.percentile(0.99),
+		P999: h.percentile(0.999),
+		Max:  h.max,
+	}
+}
+
+//report is a result of benchmark
+type report struct {
+	Target     string  `json:"target"`
+	Workload   string  `json:"workload"`
+	Ops        int64   `json:"ops"`
+	Seconds    float64 `json:"seconds"`
+	Throughput float64 `json:"ops_per_second"`
+	Gets       int64   `json:"gets"`
+	Hits       int64   `json:"hits"`
+	HitRatio   float64 `json:"hit_ratio"`
+	Sets       int64   `json:"sets"`
+	Errors     int64   `json:"errors"`
+	Error      string  `json:"error,omitempty"` //first error
+	Get        latency `json:"get_latency"`
+	Set        latency `json:"set_latency"`
+}
+
+func (w *workload) String() string {
+	values := strconv.Itoa(w.ValueMin)
+	if w.ValueMax > w.ValueMin {
+		values += "-" + strconv.Itoa(w.ValueMax)
+	}
+	return fmt.Sprintf("%s keys=%d reads=%.2f values=%s concurrency=%d", w.Dist, w.Keys, w.Reads, values, w.Concurrency)
+}
+
+//keyNames return names of all keys
+func keyNames(n int) []string {
+	names := make([]string, n)
+	for i := range names {
+		names[i] = "key:" + strconv.Itoa(i)
+	}
+	return names
+}
+
+//runBench preload keys if it is needed and put load on target during w.Duration
+func runBench(t target, w workload) (*report, error) {
+	if err := w.validate(); err != nil {
+		return nil, err
+	}
+	names := keyNames(w.Keys)
+	values := make([]byte, w.ValueMax)
+	rand.New(rand.NewSource(1)).Read(values)
+	if w.Preload {
+		if err := preload(t, w, names, values); err != nil {
+			return nil, err
+		}
+	}
+
+	var (
+		results = make([]counters, w.Concurrency)
+		wg      sync.WaitGroup
+		start   = time.Now()
+		end     = start.Add(w.Duration)
+	)
+	wg.Add(w.Concurrency)
+	for i := range results {
+		go func(c *counters, seed int64) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(seed))
+			next := w.keyGen(r)
+			for now := time.Now(); now.Before(end); {
+				name := names[next()]
+				if r.Float64() < w.Reads {
+					hit, err := t.get(name)
+					done := time.Now()
+					c.get.record(done.Sub(now))
+					c.gets++
+					if err != nil {
+						c.fail(err)
+					} else if hit {
+						c.hits++
+					}
+					now = done
+					continue
+				}
+				size := w.ValueMin
+				if w.ValueMax > w.ValueMin {
+					size += r.Intn(w.ValueMax - w.ValueMin + 1)
+				}
+				err := t.set(name, values[:size])
+				done := time.Now()
+				c.set.record(done.Sub(now))
+				c.sets++
+				if err != nil {
+					c.fail(err)
+				}
+				now = done
+			}
+		}(&results[i], int64(i)+1)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	total := &counters{}
+	for i := range results {
+		total.merge(&results[i])
+	}
+	rep := &report{
+		Workload: w.String(),
+		Ops:      total.gets + total.sets,
+		Seconds:  elapsed.Seconds(),
+		Gets:     total.gets,
+		Hits:     total.hits,
+		Sets:     total.sets,
+		Errors:   total.errors,
+		Get:      summary(&total.get),
+		Set:      summary(&total.set),
+	}
+	rep.Throughput = float64(rep.Ops) / rep.Seconds
+	if total.gets > 0 {
+		rep.HitRatio = float64(total.hits) / float64(total.gets)
+	}
+	if total.err != nil {
+		rep.Error = total.err.Error()
+	}
+	return rep, nil
+}
+
+//preload set every key by workers, the first error stop preload
+func preload(t target, w workload, names []string, values []byte) error {
+	var (
+		wg   sync.WaitGroup
+		errs = make(chan error, w.Concurrency)
+	)
+	wg.Add(w.Concurrency)
+	for i := 0; i < w.Concurrency; i++ {
+		go func(i int) {
+			defer wg.Done()
+			for j := i; j < len(names); j += w.Concurrency {
+				if err := t.set(names[j], values[:w.ValueMin]); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	if err := <-errs; err != nil {
+		return errors.New("Could not preload keys: " + err.Error())
+	}
+	return nil
+}
+
+//writeText write report as table
+func (r *report) writeText(out io.Writer) error {
+	tw := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "target\t%s\n", r.Target)
+	fmt.Fprintf(tw, "workload\t%s\n", r.Workload)
+	fmt.Fprintf(tw, "ops\t%d in %.1fs, %.0f ops/s\n", r.Ops, r.Seconds, r.Throughput)
+	fmt.Fprintf(tw, "hit ratio\t%.4f (%d of %d gets)\n", r.HitRatio, r.Hits, r.Gets)
+	fmt.Fprintf(tw, "errors\t%d %s\n", r.Errors, r.Error)
+	fmt.Fprintf(tw, "latency\tp50\tp90\tp99\tp99.9\tmax\n")
+	for _, l := range []struct {
+		name string
+		latency
+	}{{"get", r.Get}, {"set", r.Set}} {
+		fmt.Fprintf(tw, "%s\t%v\t%v\t%v\t%v\t%v\n", l.name, l.P50, l.P90, l.P99, l.P999, l.Max)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,186 @@
+//gcache-bench put load on in-process cache or gcache server and report throughput, hit ratio and latency percentiles,
+//for example:
+//
+//	gcache-bench -cache rwl -shards 16 -dist zipfian -reads 0.9 -concurrency 32 -duration 30s
+//	gcache-bench -cache singlegorutine -dist hotspot -hot-keys 0.1 -hot-ops 0.9
+//	gcache-bench -mode tcp_long -addr localhost:7000 -value-size 64-4096 -o json
+//
+//Modes are http, tcp_long, tcp_short, udp, unix and grpc, every worker has own client. Errors of grpc client are
+//counted as misses because GRPCCache implements Cacher. Keys are set before measurement unless -preload=false.
+package main
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Asuan/gcache"
+	"github.com/Asuan/gcache/internal/client"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+const modeGRPC = "grpc"
+
+type config struct {
+	workload  workload
+	cache     gcache.ConfigMessage
+	cacheType string
+	client    client.Options
+	output    string
+}
+
+func main() {
+	c, err := parseFlags(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+	if err = run(c, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+//run benchmark and write report
+func run(c *config, out io.Writer) error {
+	t, name, err := newTarget(c)
+	if err != nil {
+		return err
+	}
+	rep, err := runBench(t, c.workload)
+	t.close()
+	if err != nil {
+		return err
+	}
+	rep.Target = name
+	if c.output == "json" {
+		return json.NewEncoder(out).Encode(rep)
+	}
+	return rep.writeText(out)
+}
+
+//newTarget create in-process cache if mode is empty or clients of server, name describe target in report
+func newTarget(c *config) (target, string, error) {
+	switch c.client.Mode {
+	case "":
+		cache, err := gcache.NewCache(&c.cache)
+		if err != nil {
+			return nil, "", err
+		}
+		name := c.cacheType
+		if c.cache.ShardCount > 1 {
+			name += " shards=" + strconv.FormatInt(c.cache.ShardCount, 10)
+		}
+		return &cacheTarget{cache: cache}, name, nil
+	case modeGRPC:
+		opts := []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+		if c.client.TLS != nil {
+			opts[0] = grpc.WithTransportCredentials(credentials.NewTLS(c.client.TLS))
+		}
+		if c.client.Token != "" {
+			opts = append(opts, grpc.WithPerRPCCredentials(gcache.TokenCredentials(c.client.Token)))
+		}
+		conn, err := grpc.Dial(c.client.Address, opts...)
+		if err != nil {
+			return nil, "", err
+		}
+		return &cacheTarget{cache: gcache.NewGRPCCache(conn, c.client.Timeout)}, modeGRPC + " " + c.client.Address, nil
+	}
+	t, err := newClientTarget(c.client, c.workload.Concurrency)
+	if err != nil {
+		return nil, "", err
+	}
+	return t, c.client.Mode + " " + c.client.Address, nil
+}
+
+func parseFlags(fs *flag.FlagSet, args []string) (*config, error) {
+	c := &config{}
+	var (
+		valueSize                 string
+		useTLS                    bool
+		caFile, certFile, keyFile string
+	)
+	w := &c.workload
+	fs.IntVar(&w.Keys, "keys", 10000, "number of distinct keys")
+	fs.StringVar(&w.Dist, "dist", distUniform, "distribution of keys: uniform zipfian or hotspot")
+	fs.Float64Var(&w.ZipfS, "zipf-s", 1.1, "skew of zipfian distribution, bigger than 1")
+	fs.Float64Var(&w.HotKeys, "hot-keys", 0.2, "share of hot keys in hotspot distribution")
+	fs.Float64Var(&w.HotOps, "hot-ops", 0.8, "share of requests to hot keys in hotspot distribution")
+	fs.StringVar(&valueSize, "value-size", "100", "size of values in bytes, <min>-<max> for random size")
+	fs.Float64Var(&w.Reads, "reads", 0.9, "share of gets, the rest are sets")
+	fs.IntVar(&w.Concurrency, "concurrency", 16, "number of workers")
+	fs.DurationVar(&w.Duration, "duration", 10*time.Second, "duration of measurement")
+	fs.BoolVar(&w.Preload, "preload", true, "set every key before measurement")
+
+	fs.StringVar(&c.cacheType, "cache", "rwl", "type of in-process cache: rwl singlegorutine or offheap")
+	fs.Int64Var(&c.cache.ShardCount, "shards", 0, "number of shards of in-process cache")
+	fs.StringVar(&c.cache.HashFunc, "hash", "", "hash of shards: fnv crc djb33 or sum")
+	fs.Int64Var(&c.cache.SizeLimit, "size-limit", 1<<20, "maximum number of items of in-process cache")
+	fs.Int64Var(&c.cache.MemoryLimit, "memory-limit", 0, "memory of in-process offheap cache in bytes")
+
+	fs.StringVar(&c.client.Mode, "mode", "", "mode of server: http tcp_long tcp_short udp unix or grpc, in-process cache is used if empty")
+	fs.StringVar(&c.client.Address, "addr", "localhost:8080", "address of server")
+	fs.StringVar(&c.client.Token, "token", os.Getenv("GCACHE_TOKEN"), "access token, GCACHE_TOKEN environment variable by default")
+	fs.DurationVar(&c.client.Timeout, "timeout", 5*time.Second, "timeout of one request")
+	fs.BoolVar(&useTLS, "tls", false, "connect via tls, it is implied by other tls flags")
+	fs.StringVar(&caFile, "tls-ca", "", "optional PEM CA certificates to verify server instead of system roots")
+	fs.StringVar(&certFile, "tls-cert", "", "optional PEM client certificate for mutual tls")
+	fs.StringVar(&keyFile, "tls-key", "", "PEM private key of client certificate")
+	fs.StringVar(&c.output, "o", "text", "output format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() != 0 {
+		return nil, errors.New("Unexpected arguments: " + strings.Join(fs.Args(), " "))
+	}
+
+	var err error
+	if w.ValueMin, w.ValueMax, err = parseSize(valueSize); err != nil {
+		return nil, err
+	}
+	if err = w.validate(); err != nil {
+		return nil, err
+	}
+	if c.output != "text" && c.output != "json" {
+		return nil, errors.New("Unknown output: " + c.output)
+	}
+	cacheType, ok := gcache.ConfigMessage_CacheTypes_value[strings.ToUpper(c.cacheType)]
+	if !ok {
+		return nil, errors.New("Unknown cache: " + c.cacheType)
+	}
+	c.cache.CacheType = gcache.ConfigMessage_CacheTypes(cacheType)
+	if useTLS || caFile != "" || certFile != "" {
+		var tlsConfig *tls.Config
+		if tlsConfig, err = gcache.ClientTLSConfig(caFile, certFile, keyFile); err != nil {
+			return nil, err
+		}
+		c.client.TLS = tlsConfig
+	}
+	return c, nil
+}
+
+//parseSize parse "100" or "64-4096"
+func parseSize(value string) (int, int, error) {
+	parts := strings.SplitN(value, "-", 2)
+	min, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, errors.New("Wrong value size: " + value)
+	}
+	max := min
+	if len(parts) == 2 {
+		if max, err = strconv.Atoi(parts[1]); err != nil {
+			return 0, 0, errors.New("Wrong value size: " + value)
+		}
+	}
+	return min, max, nil
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/Asuan/gcache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistogram(t *testing.T) {
+	as := assert.New(t)
+	h := &histogram{}
+	for i := 1; i <= 1000; i++ {
+		h.record(time.Duration(i) * time.Microsecond)
+	}
+	as.InEpsilon(float64(500*time.Microsecond), float64(h.percentile(0.5)), 0.07)
+	as.InEpsilon(float64(990*time.Microsecond), float64(h.percentile(0.99)), 0.07)
+	as.InEpsilon(float64(time.Millisecond), float64(h.percentile(1)), 0.07)
+	as.Equal(time.Millisecond, h.max)
+
+	for _, d := range []time.Duration{0, 31, 32, 47, 1000, time.Hour} {
+		as.True(bucketValue(bucket(d)) <= d, "%v", d)
+		as.True(bucketValue(bucket(d)+1) > d, "%v", d)
+	}
+	as.Zero((&histogram{}).percentile(0.5))
+}
+
+func TestWorkload_KeyGen(t *testing.T) {
+	as := assert.New(t)
+	w := workload{Keys: 100, Dist: distHotspot, HotKeys: 0.1, HotOps: 0.9}
+	next := w.keyGen(rand.New(rand.NewSource(1)))
+	hot := 0
+	for i := 0; i < 10000; i++ {
+		if next() < 10 {
+			hot++
+		}
+	}
+	as.InDelta(9000, hot, 300)
+
+	w = workload{Keys: 100, Dist: distZipfian, ZipfS: 1.1}
+	next = w.keyGen(rand.New(rand.NewSource(1)))
+	counts := make([]int, w.Keys)
+	for i := 0; i < 10000; i++ {
+		counts[next()]++
+	}
+	as.True(counts[0] > counts[1] && counts[1] > counts[50], "first keys are popular")
+}
+
+func benchArgs(extra ...string) []string {
+	return append([]string{"-keys", "100", "-duration", "50ms", "-concurrency", "4", "-o", "json"}, extra...)
+}
+
+func runArgs(t *testing.T, args ...string) *report {
+	c, err := parseFlags(flag.NewFlagSet("gcache-bench", flag.ContinueOnError), args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := &bytes.Buffer{}
+	if err = run(c, out); err != nil {
+		t.Fatal(err)
+	}
+	rep := &report{}
+	if err = json.Unmarshal(out.Bytes(), rep); err != nil {
+		t.Fatal(err)
+	}
+	return rep
+}
+
+func TestRun_InProcess(t *testing.T) {
+	as := assert.New(t)
+	rep := runArgs(t, benchArgs("-reads", "1")...)
+	as.Equal("rwl", rep.Target)
+	as.True(rep.Ops > 0)
+	as.Equal(rep.Ops, rep.Gets)
+	as.Equal(1.0, rep.HitRatio, "keys are preloaded")
+	as.True(rep.Get.P50 <= rep.Get.P99 && rep.Get.P99 <= rep.Get.Max)
+
+	for _, args := range [][]string{
+		{"-cache", "singlegorutine", "-dist", "zipfian"},
+		{"-cache", "offheap", "-dist", "hotspot", "-value-size", "10-1000"},
+		{"-shards", "4", "-hash", "crc", "-preload=false"},
+	} {
+		rep = runArgs(t, benchArgs(args...)...)
+		as.True(rep.Gets > 0 && rep.Sets > 0, "%v", args)
+		as.Zero(rep.Errors, "%v", args)
+	}
+	as.Equal("rwl shards=4", rep.Target)
+
+	out := &bytes.Buffer{}
+	c, _ := parseFlags(flag.NewFlagSet("gcache-bench", flag.ContinueOnError), []string{"-keys", "10", "-duration", "10ms"})
+	if as.NoError(run(c, out)) {
+		as.Contains(out.String(), "hit ratio")
+		as.Contains(out.String(), "p99.9")
+	}
+}
+
+func TestRun_Server(t *testing.T) {
+	as := assert.New(t)
+	modes := []string{"http", "tcp_long", "tcp_short", "udp", "grpc"}
+	opts := gcache.ServerOptions{}
+	for _, mode := range modes {
+		opts.Endpoints = append(opts.Endpoints, gcache.Endpoint{Mode: mode, Address: "127.0.0.1:0"})
+	}
+	s, err := gcache.NewServer(gcache.NewRwCache(&gcache.ConfigMessage{}), opts)
+	if !as.NoError(err) {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Serve(ctx)
+	for _, mode := range modes {
+		for s.Addr(mode) == nil {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	for _, mode := range modes {
+		rep := runArgs(t, benchArgs("-mode", mode, "-addr", s.Addr(mode).String())...)
+		as.Equal(mode+" "+s.Addr(mode).String(), rep.Target)
+		as.True(rep.Ops > 0, mode)
+		as.Zero(rep.Errors, mode)
+		as.Equal(1.0, rep.HitRatio, mode)
+	}
+
+	c, _ := parseFlags(flag.NewFlagSet("gcache-bench", flag.ContinueOnError), benchArgs("-mode", "tcp_long", "-addr", "127.0.0.1:1"))
+	as.Error(run(c, &bytes.Buffer{}), "server is unavailable")
+}
+
+func TestParseFlags(t *testing.T) {
+	as := assert.New(t)
+	c, err := parseFlags(flag.NewFlagSet("gcache-bench", flag.ContinueOnError), []string{"-value-size", "64-4096", "-cache", "offheap"})
+	if as.NoError(err) {
+		as.Equal(64, c.workload.ValueMin)
+		as.Equal(4096, c.workload.ValueMax)
+		as.Equal(gcache.ConfigMessage_OFFHEAP, c.cache.CacheType)
+	}
+	for _, args := range [][]string{
+		{"-dist", "normal"},
+		{"-dist", "zipfian", "-zipf-s", "1"},
+		{"-value-size", "100-10"},
+		{"-value-size", "big"},
+		{"-reads", "2"},
+		{"-concurrency", "0"},
+		{"-cache", "remote2"},
+		{"-o", "xml"},
+		{"extra"},
+	} {
+		_, err = parseFlags(flag.NewFlagSet("gcache-bench", flag.ContinueOnError), args)
+		as.Error(err, "%v", args)
+	}
+}
@@ -0,0 +1,322 @@
+//gcachectl run one command against gcache server of http, tcp_long, tcp_short, udp or unix mode, for example:
+//
+//	gcachectl -mode tcp_long -addr localhost:7000 set -ttl 10m user:1 '{"name":"zaza"}'
+//	echo -n zaza | gcachectl set user:2
+//	gcachectl -o json get user:1 | jq .
+//	gcachectl dump > snapshot && gcachectl -addr other:8080 load < snapshot
+//
+//In text output get write value as is, json output is one object per command and value is base64 there.
+//Dump and load use snapshot format of gcached -snapshot file.
+//Exit code is 1 when item is not found, 2 on other errors.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Asuan/gcache"
+	"github.com/Asuan/gcache/internal/client"
+)
+
+const (
+	exitNotFound = 1
+	exitError    = 2
+
+	outputText = "text"
+	outputJSON = "json"
+)
+
+const usage = `Usage: gcachectl [flags] <command> [args]
+
+Commands:
+  get <name>                    print value
+  set [-ttl ttl] <name> [value]
+                                store value, it is read from stdin if omitted,
+                                ttl is duration like 10m or number of seconds,
+                                negative ttl mean no expiration
+  delete <name>                 remove item
+  purge                         remove all items
+  stats                         print statistic
+  dump [file]                   write snapshot of all items to file or stdout
+  load [file]                   restore snapshot from file or stdin
+
+Flags:
+`
+
+var errUsage = errors.New("Wrong arguments")
+
+//command is a parsed command line
+type command struct {
+	name   string
+	args   []string
+	output string
+	opts   client.Options
+}
+
+//itemOutput is json output of get
+type itemOutput struct {
+	Name       string     `json:"name"`
+	Value      []byte     `json:"value"`
+	Expiration *time.Time `json:"expiration,omitempty"`
+	Version    uint64     `json:"version,omitempty"`
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+//run execute command line and return exit code
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("gcachectl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		fs.PrintDefaults()
+	}
+	cmd, err := parseFlags(fs, args)
+	if err != nil {
+		if err != flag.ErrHelp {
+			fmt.Fprintf(stderr, "Error: %v\n", err)
+			fs.Usage()
+		}
+		return exitError
+	}
+	c, err := client.New(cmd.opts)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return exitError
+	}
+	defer c.Close()
+	if err = cmd.run(c, stdin, stdout); err == nil {
+		return 0
+	}
+	fmt.Fprintf(stderr, "Error: %v\n", err)
+	switch err {
+	case gcache.ErrNotFound:
+		return exitNotFound
+	case errUsage:
+		fs.Usage()
+	}
+	return exitError
+}
+
+func parseFlags(fs *flag.FlagSet, args []string) (*command, error) {
+	cmd := &command{}
+	var (
+		useTLS                    bool
+		caFile, certFile, keyFile string
+	)
+	fs.StringVar(&cmd.opts.Mode, "mode", client.ModeHTTP, "mode of server: http tcp_long tcp_short udp or unix")
+	fs.StringVar(&cmd.opts.Address, "addr", "localhost:8080", "address of server")
+	fs.StringVar(&cmd.opts.Token, "token", os.Getenv("GCACHE_TOKEN"), "access token, GCACHE_TOKEN environment variable by default")
+	fs.DurationVar(&cmd.opts.Timeout, "timeout", 5*time.Second, "timeout of one request")
+	fs.IntVar(&cmd.opts.Retries, "retries", 2, "number of udp resends when responce is lost")
+	fs.StringVar(&cmd.opts.Fallback, "fallback-addr", "", "optional tcp_long address for udp requests and responces which do not fit datagram")
+	fs.StringVar(&cmd.output, "o", outputText, "output format: text or json")
+	fs.BoolVar(&useTLS, "tls", false, "connect via tls, it is implied by other tls flags")
+	fs.StringVar(&caFile, "tls-ca", "", "optional PEM CA certificates to verify server instead of system roots")
+	fs.StringVar(&certFile, "tls-cert", "", "optional PEM client certificate for mutual tls")
+	fs.StringVar(&keyFile, "tls-key", "", "PEM private key of client certificate")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() == 0 {
+		return nil, errors.New("Command is missing")
+	}
+	cmd.name, cmd.args = fs.Arg(0), fs.Args()[1:]
+	if cmd.output != outputText && cmd.output != outputJSON {
+		return nil, errors.New("Unknown output: " + cmd.output)
+	}
+	if useTLS || caFile != "" || certFile != "" {
+		tlsConfig, err := gcache.ClientTLSConfig(caFile, certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		cmd.opts.TLS = tlsConfig
+	}
+	return cmd, nil
+}
+
+//run execute command via client
+func (cmd *command) run(c client.Client, stdin io.Reader, stdout io.Writer) error {
+	switch cmd.name {
+	case "get":
+		if len(cmd.args) != 1 {
+			return errUsage
+		}
+		itm, err := c.Get(cmd.args[0])
+		if err != nil {
+			return err
+		}
+		if cmd.output == outputText {
+			_, err = stdout.Write(itm.Object)
+			return err
+		}
+		out := itemOutput{Name: cmd.args[0], Value: itm.Object, Version: itm.Version}
+		if itm.Expiration != 0 {
+			exp := time.Unix(0, itm.Expiration).UTC()
+			out.Expiration = &exp
+		}
+		return json.NewEncoder(stdout).Encode(out)
+	case "set":
+		fs := flag.NewFlagSet("set", flag.ContinueOnError)
+		fs.SetOutput(ioutil.Discard)
+		var ttl ttlFlag
+		fs.Var(&ttl, "ttl", "expiration")
+		if err := fs.Parse(cmd.args); err != nil || fs.NArg() < 1 || fs.NArg() > 2 {
+			return errUsage
+		}
+		var value []byte
+		if fs.NArg() == 2 {
+			value = []byte(fs.Arg(1))
+		} else {
+			var err error
+			if value, err = ioutil.ReadAll(stdin); err != nil {
+				return err
+			}
+		}
+		return c.Set(fs.Arg(0), value, time.Duration(ttl))
+	case "delete":
+		if len(cmd.args) != 1 {
+			return errUsage
+		}
+		return c.Delete(cmd.args[0])
+	case "purge":
+		if len(cmd.args) != 0 {
+			return errUsage
+		}
+		return c.Purge()
+	case "stats":
+		if len(cmd.args) != 0 {
+			return errUsage
+		}
+		stats, err := c.Stats()
+		if err != nil {
+			return err
+		}
+		if cmd.output == outputJSON {
+			return json.NewEncoder(stdout).Encode(stats)
+		}
+		for _, line := range []struct {
+			name  string
+			value int64
+		}{
+			{"ItemsCount", stats.ItemsCount},
+			{"GetSuccessNumber", stats.GetSuccessNumber},
+			{"GetErrorNumber", stats.GetErrorNumber},
+			{"SetOrReplaceCount", stats.SetOrReplaceCount},
+			{"DeleteCount", stats.DeleteCount},
+			{"DeleteExpired", stats.DeleteExpired},
+			{"SizeLimit", stats.SizeLimit},
+			{"RawBytes", stats.RawBytes},
+			{"CompressedBytes", stats.CompressedBytes},
+		} {
+			fmt.Fprintf(stdout, "%s %d\n", line.name, line.value)
+		}
+		return nil
+	case "dump":
+		if len(cmd.args) > 1 {
+			return errUsage
+		}
+		sc := &snapshotCache{client: c}
+		if len(cmd.args) == 0 {
+			if err := gcache.WriteSnapshot(stdout, sc); err != nil {
+				return err
+			}
+			return sc.err
+		}
+		if err := gcache.SaveSnapshot(cmd.args[0], sc); err != nil {
+			return err
+		}
+		return sc.err
+	case "load":
+		if len(cmd.args) > 1 {
+			return errUsage
+		}
+		var (
+			sc    = &snapshotCache{client: c}
+			count int
+			err   error
+		)
+		if len(cmd.args) == 0 {
+			count, err = gcache.ReadSnapshot(stdin, sc)
+		} else {
+			count, err = gcache.LoadSnapshot(cmd.args[0], sc)
+		}
+		if err == nil {
+			err = sc.err
+		}
+		if err != nil {
+			return err
+		}
+		if cmd.output == outputJSON {
+			return json.NewEncoder(stdout).Encode(map[string]int{"loaded": count})
+		}
+		_, err = fmt.Fprintln(stdout, count)
+		return err
+	}
+	return errUsage
+}
+
+//ttlFlag is an expiration given as duration or number of seconds, negative value mean no expiration
+type ttlFlag time.Duration
+
+func (t *ttlFlag) String() string {
+	return time.Duration(*t).String()
+}
+
+func (t *ttlFlag) Set(value string) error {
+	ttl, err := time.ParseDuration(value)
+	if seconds, secondsErr := strconv.ParseInt(value, 10, 64); secondsErr == nil {
+		ttl, err = time.Duration(seconds)*time.Second, nil
+	}
+	if err != nil {
+		return err
+	}
+	if ttl < 0 {
+		ttl = gcache.NoExpiration
+	}
+	*t = ttlFlag(ttl)
+	return nil
+}
+
+//snapshotCache adapt client to gcache.WriteSnapshot and gcache.ReadSnapshot, the first error is kept and
+// next requests are skipped
+type snapshotCache struct {
+	gcache.Cacher
+	client client.Client
+	err    error
+}
+
+func (c *snapshotCache) Keys() []string {
+	if c.err != nil {
+		return nil
+	}
+	keys, err := c.client.Keys()
+	c.err = err
+	return keys
+}
+
+func (c *snapshotCache) GetItem(name string) *gcache.Item {
+	if c.err != nil {
+		return nil
+	}
+	itm, err := c.client.Get(name)
+	if err != nil && err != gcache.ErrNotFound { //deleted meanwhile
+		c.err = err
+	}
+	return itm
+}
+
+func (c *snapshotCache) SetOrUpdate(name string, value []byte, exp time.Duration) {
+	if c.err == nil {
+		c.err = c.client.Set(name, value, exp)
+	}
+}
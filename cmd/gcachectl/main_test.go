@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Asuan/gcache"
+	"github.com/Asuan/gcache/internal/client"
+	"github.com/stretchr/testify/assert"
+)
+
+//ctl run command line and return exit code and stdout
+func ctl(stdin string, args ...string) (int, string) {
+	stdout := &bytes.Buffer{}
+	code := run(args, strings.NewReader(stdin), stdout, &bytes.Buffer{})
+	return code, stdout.String()
+}
+
+func TestRun(t *testing.T) {
+	as := assert.New(t)
+	modes := []string{client.ModeHTTP, client.ModeTCPLong, client.ModeTCPShort, client.ModeUDP}
+	opts := gcache.ServerOptions{}
+	for _, mode := range modes {
+		opts.Endpoints = append(opts.Endpoints, gcache.Endpoint{Mode: mode, Address: "127.0.0.1:0"})
+	}
+	s, err := gcache.NewServer(gcache.NewRwCache(&gcache.ConfigMessage{}), opts)
+	if !as.NoError(err) {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Serve(ctx)
+	for _, mode := range modes {
+		for s.Addr(mode) == nil {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	for _, mode := range modes {
+		args := []string{"-mode", mode, "-addr", s.Addr(mode).String()}
+		cmd := func(extra ...string) []string { return append(append([]string{}, args...), extra...) }
+
+		code, _ := ctl("", cmd("purge")...)
+		as.Equal(0, code, mode)
+		code, _ = ctl("", cmd("set", "-ttl", "3600", "first", "zaza")...)
+		as.Equal(0, code, mode)
+		code, _ = ctl("azaz", cmd("set", "-ttl", "-1s", "second")...)
+		as.Equal(0, code, mode)
+
+		code, out := ctl("", cmd("get", "first")...)
+		as.Equal(0, code, mode)
+		as.Equal("zaza", out, mode)
+		_, out = ctl("", cmd("-o", "json", "get", "first")...)
+		var itm itemOutput
+		if as.NoError(json.Unmarshal([]byte(out), &itm), mode) && as.NotNil(itm.Expiration, mode) {
+			as.Equal([]byte(`zaza`), itm.Value, mode)
+			as.WithinDuration(time.Now().Add(time.Hour), *itm.Expiration, time.Minute, mode)
+		}
+		_, out = ctl("", cmd("-o", "json", "get", "second")...)
+		as.NotContains(out, "expiration", mode)
+
+		_, out = ctl("", cmd("stats")...)
+		as.Contains(out, "ItemsCount 2\n", mode)
+		_, out = ctl("", cmd("-o", "json", "stats")...)
+		var stats gcache.Stats
+		if as.NoError(json.Unmarshal([]byte(out), &stats), mode) {
+			as.Equal(int64(2), stats.ItemsCount, mode)
+		}
+
+		code, snapshot := ctl("", cmd("dump")...)
+		as.Equal(0, code, mode)
+		code, _ = ctl("", cmd("delete", "first")...)
+		as.Equal(0, code, mode)
+		code, _ = ctl("", cmd("delete", "first")...)
+		as.Equal(exitNotFound, code, mode)
+		code, _ = ctl("", cmd("get", "first")...)
+		as.Equal(exitNotFound, code, mode)
+
+		ctl("", cmd("purge")...)
+		code, out = ctl(snapshot, cmd("load")...)
+		as.Equal(0, code, mode)
+		as.Equal("2\n", out, mode)
+		itmFirst := s.Cache().GetItem("first")
+		if as.NotNil(itmFirst, mode) {
+			as.InDelta(time.Now().Add(time.Hour).UnixNano(), itmFirst.Expiration, float64(time.Minute), "expiration is kept")
+		}
+		as.Equal([]byte(`azaz`), s.Cache().Get("second"), mode)
+	}
+
+	for _, args := range [][]string{
+		{},
+		{"fly"},
+		{"get"},
+		{"-o", "xml", "stats"},
+		{"-mode", "memcache", "stats"},
+		{"-mode", "udp", "-tls", "stats"},
+		{"set", "-ttl", "zaza", "first", "zaza"},
+	} {
+		code, _ := ctl("", args...)
+		as.Equal(exitError, code, "%v", args)
+	}
+	code, _ := ctl("", "-addr", "127.0.0.1:1", "stats")
+	as.Equal(exitError, code, "server is unavailable")
+}
@@ -0,0 +1,161 @@
+//gcached serve one cache on many endpoints, for example:
+//
+//	gcached -listen tcp_long=:7000 -listen http=:8080 -snapshot /var/lib/gcache/snapshot
+//
+//With -config settings are read from json file, see gcache.ServerConfig. The file is reloaded on SIGHUP
+//or when it is modified, expiration, size limit, acl and shutdown timeout are applied without restart.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Asuan/gcache"
+)
+
+const defaultEndpoint = "http=:8080"
+
+//endpoints is a repeated -listen flag
+type endpoints []gcache.Endpoint
+
+func (e *endpoints) String() string {
+	list := make([]string, len(*e))
+	for i, ep := range *e {
+		list[i] = ep.Mode + "=" + ep.Address
+	}
+	return strings.Join(list, ",")
+}
+
+func (e *endpoints) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 {
+		return errors.New("Expected <mode>=<address>")
+	}
+	*e = append(*e, gcache.Endpoint{Mode: parts[0], Address: parts[1]})
+	return nil
+}
+
+type config struct {
+	file           string
+	opts           gcache.ServerOptions
+	cache          gcache.ConfigMessage
+	expiration     time.Duration
+	cacheType      string
+	identitiesFile string
+	aclFile        string
+	socketPerm     string
+}
+
+func main() {
+	c, err := parseFlags(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+	srv, err := newServer(c)
+	if err != nil {
+		log.Fatalln("Could not create server: " + err.Error())
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if c.file != "" {
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		watcher := &gcache.ConfigWatcher{
+			Server:  srv,
+			Path:    c.file,
+			Signals: hup,
+			OnReload: func(err error) {
+				if err != nil {
+					log.Println("Config is not reloaded: " + err.Error())
+				} else {
+					log.Println("Config is reloaded")
+				}
+			},
+		}
+		go watcher.Run(ctx)
+	}
+	if err = srv.Serve(ctx); err != nil && err != gcache.ErrServerClosed {
+		log.Fatalln("Could not serve: " + err.Error())
+	}
+}
+
+func newServer(c *config) (*gcache.Server, error) {
+	if c.file == "" {
+		return gcache.NewConfigServer(&c.cache, c.opts)
+	}
+	file, err := gcache.LoadServerConfig(c.file)
+	if err != nil {
+		return nil, err
+	}
+	return file.NewServer()
+}
+
+func parseFlags(fs *flag.FlagSet, args []string) (*config, error) {
+	c := &config{}
+	var listen endpoints
+	fs.StringVar(&c.file, "config", "", "optional json config file, other flags are ignored if it is set")
+	fs.Var(&listen, "listen", "repeated <mode>=<address>, mode can be http tcp_long tcp_short udp unix memcache resp or grpc, address of unix is socket path or @name in abstract namespace (default "+defaultEndpoint+")")
+	fs.DurationVar(&c.expiration, "expiration", 200*time.Second, "default expiration of items")
+	fs.Int64Var(&c.cache.SizeLimit, "size-limit", 0, "maximum number of items, 0 mean unlimited, offheap cache is limited by memory-limit only")
+	fs.Int64Var(&c.cache.ShardCount, "shards", 0, "number of shards")
+	fs.Int64Var(&c.cache.MemoryLimit, "memory-limit", 0, "memory of offheap cache in bytes")
+	fs.BoolVar(&c.cache.IsKeepUsefull, "keep-usefull", false, "prolong expiration of read items")
+	fs.StringVar(&c.cacheType, "cache", "rwl", "type of cache: rwl singlegorutine or offheap")
+	fs.StringVar(&c.opts.TLS.CertFile, "tls-cert", "", "optional PEM certificate of server, enable tls for all modes except udp")
+	fs.StringVar(&c.opts.TLS.KeyFile, "tls-key", "", "PEM private key of server certificate")
+	fs.StringVar(&c.opts.TLS.ClientCAFile, "tls-client-ca", "", "optional PEM CA certificates, client certificate signed by them is required (mutual tls)")
+	fs.StringVar(&c.identitiesFile, "tls-identities", "", "optional file with lines \"<identity> <certificate subject>\", other client certificates are rejected")
+	fs.StringVar(&c.aclFile, "acl", "", "optional json file with principals, their tokens and permissions")
+	fs.StringVar(&c.opts.SnapshotPath, "snapshot", "", "optional file where items are saved on shutdown and loaded on start")
+	fs.DurationVar(&c.opts.ShutdownTimeout, "shutdown-timeout", gcache.DefaultShutdownTimeout, "time to finish in-flight requests on shutdown")
+	fs.StringVar(&c.socketPerm, "socket-perm", "", "optional octal permissions of unix socket files like 0660")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if c.file != "" {
+		return c, nil
+	}
+
+	if len(listen) == 0 {
+		listen.Set(defaultEndpoint)
+	}
+	c.opts.Endpoints = listen
+	c.cache.DefaultExpiration = int64(c.expiration)
+	cacheType, ok := gcache.ConfigMessage_CacheTypes_value[strings.ToUpper(c.cacheType)]
+	if !ok {
+		return nil, errors.New("Unknown cache: " + c.cacheType)
+	}
+	c.cache.CacheType = gcache.ConfigMessage_CacheTypes(cacheType)
+	if c.socketPerm != "" {
+		perm, err := gcache.ParseSocketPerm(c.socketPerm)
+		if err != nil {
+			return nil, err
+		}
+		c.opts.SocketPerm = perm
+	}
+	if c.identitiesFile != "" {
+		identities, err := gcache.LoadIdentities(c.identitiesFile)
+		if err != nil {
+			return nil, err
+		}
+		c.opts.TLS.Identities = identities
+	}
+	if c.aclFile != "" {
+		acl, err := gcache.LoadACL(c.aclFile)
+		if err != nil {
+			return nil, err
+		}
+		c.opts.ACL = acl
+	}
+	return c, c.opts.Validate()
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Asuan/gcache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFlags(t *testing.T) {
+	as := assert.New(t)
+	c, err := parseFlags(flag.NewFlagSet("gcached", flag.ContinueOnError), []string{
+		"-listen", "tcp_short=:7000", "-listen", "http=127.0.0.1:8080", "-cache", "offheap", "-expiration", "1m",
+		"-socket-perm", "0660",
+	})
+	if as.NoError(err) {
+		as.Equal([]gcache.Endpoint{{Mode: "tcp_short", Address: ":7000"}, {Mode: "http", Address: "127.0.0.1:8080"}}, c.opts.Endpoints)
+		as.Equal(gcache.ConfigMessage_OFFHEAP, c.cache.CacheType)
+		as.Equal(int64(time.Minute), c.cache.DefaultExpiration)
+		as.Equal(os.FileMode(0660), c.opts.SocketPerm)
+	}
+
+	c, err = parseFlags(flag.NewFlagSet("gcached", flag.ContinueOnError), nil)
+	if as.NoError(err) {
+		as.Equal([]gcache.Endpoint{{Mode: "http", Address: ":8080"}}, c.opts.Endpoints)
+	}
+
+	for _, args := range [][]string{
+		{"-listen", "tcp_long"},
+		{"-listen", "fly=:7000"},
+		{"-cache", "remote2"},
+		{"-listen", "udp=:7000", "-tls-cert", "cert.pem", "-tls-key", "key.pem"},
+		{"-listen", "unix=/run/gcache.sock", "-socket-perm", "rw"},
+	} {
+		_, err = parseFlags(flag.NewFlagSet("gcached", flag.ContinueOnError), args)
+		as.Error(err, "%v", args)
+	}
+}
+
+func TestNewServer_DefaultSizeLimit(t *testing.T) {
+	as := assert.New(t)
+	for _, cache := range []string{"rwl", "singlegorutine", "offheap"} {
+		c, err := parseFlags(flag.NewFlagSet("gcached", flag.ContinueOnError), []string{"-cache", cache, "-memory-limit", "1048576"})
+		if !as.NoError(err, cache) {
+			continue
+		}
+		as.Zero(c.cache.SizeLimit)
+		srv, err := newServer(c)
+		if !as.NoError(err, cache) {
+			continue
+		}
+		_, err = srv.Cache().Add("zaza", []byte(`zaza`), gcache.NoExpiration)
+		as.NoError(err, "default size limit is unlimited for %s", cache)
+		as.Equal([]byte(`zaza`), srv.Cache().Get("zaza"), cache)
+		srv.Cache().Dead() //Cleanup
+	}
+}
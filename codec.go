@@ -0,0 +1,374 @@
+package gcache
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+)
+
+//Codec versions, client choose codec by hello: amulet byte followed by version.
+//Stream client send hello once at connection start and wait the same hello with version chosen by server,
+// tcp_short and udp clients prefix every request with hello and responce is prefixed too.
+//Protobuf message never starts with amulet (wire type 6) or with byte below 8 (field number 0),
+// so clients without hello keep working with protobuf
+const (
+	CodecProto  = byte(0) //protobuf, used without hello
+	CodecBinary = byte(1) //hand-written binary encoding, see appendBinary
+)
+
+//ErrCodecNotSupported is returned by client when server does not support requested codec
+var ErrCodecNotSupported = errors.New("Codec is not supported by server")
+
+const maxPooledBuffer = 64 << 10 //bigger buffers are left to GC
+
+//codec marshal messages of one connection
+type codec interface {
+	unmarshal(data []byte, m *ItemMessage) error
+	appendMessage(dst []byte, m *ItemMessage) ([]byte, error)
+}
+
+//codecs is indexed by version
+var codecs = []codec{protoCodec{}, binaryCodec{}}
+
+//codecOf return codec of version or nil if version is unknown
+func codecOf(version byte) codec {
+	if int(version) >= len(codecs) {
+		return nil
+	}
+	return codecs[version]
+}
+
+type protoCodec struct{}
+
+func (protoCodec) unmarshal(data []byte, m *ItemMessage) error {
+	return proto.Unmarshal(data, m)
+}
+
+func (protoCodec) appendMessage(dst []byte, m *ItemMessage) ([]byte, error) {
+	data, err := proto.Marshal(m)
+	return append(dst, data...), err
+}
+
+type binaryCodec struct{}
+
+func (binaryCodec) unmarshal(data []byte, m *ItemMessage) error {
+	return unmarshalBinary(data, m)
+}
+
+func (binaryCodec) appendMessage(dst []byte, m *ItemMessage) ([]byte, error) {
+	return appendBinary(dst, m), nil
+}
+
+//isHello return true if data starts with hello of codec
+func isHello(data []byte) bool {
+	return len(data) >= 2 && data[0] == amulet && data[1] < 8
+}
+
+//appendHello append hello of codec version to dst
+func appendHello(dst []byte, version byte) []byte {
+	return append(dst, amulet, version)
+}
+
+//acceptCodec read hello of stream client and reply with version used by connection,
+// the highest supported version is chosen if client ask newer one. Protobuf is used for clients without hello
+func acceptCodec(r *bufio.Reader, w io.Writer) (codec, error) {
+	if first, err := r.Peek(1); err != nil || first[0] != amulet {
+		return protoCodec{}, nil //read errors are reported by frame reader
+	}
+	hello, err := r.Peek(2) //protobuf frame of 30 bytes is waiting too
+	if err != nil || !isHello(hello) {
+		return protoCodec{}, nil
+	}
+	version := hello[1]
+	if int(version) >= len(codecs) {
+		version = byte(len(codecs) - 1)
+	}
+	r.Discard(2)
+	if _, err = w.Write(appendHello(nil, version)); err != nil {
+		return nil, err
+	}
+	return codecs[version], nil
+}
+
+//requestCodec send hello of version via stream and return codec chosen by server
+func requestCodec(rw io.ReadWriter, version byte) (codec, error) {
+	if version == CodecProto {
+		return protoCodec{}, nil
+	}
+	if _, err := rw.Write(appendHello(nil, version)); err != nil {
+		return nil, err
+	}
+	var hello [2]byte
+	if _, err := io.ReadFull(rw, hello[:]); err != nil {
+		return nil, ErrCodecNotSupported //old server wait the rest of protobuf frame
+	}
+	if !isHello(hello[:]) || hello[1] != version || codecOf(version) == nil {
+		return nil, ErrCodecNotSupported
+	}
+	return codecOf(version), nil
+}
+
+//splitHello return codec of datagram or tcp_short request and message without hello, nil codec mean unknown version
+func splitHello(data []byte) (codec, byte, []byte) {
+	if !isHello(data) {
+		return protoCodec{}, CodecProto, data
+	}
+	return codecOf(data[1]), data[1], data[2:]
+}
+
+var (
+	messagePool = sync.Pool{New: func() interface{} { return &ItemMessage{} }}
+	bufferPool  = sync.Pool{New: func() interface{} { return new([]byte) }}
+)
+
+//acquireMessage return empty message, it should be returned by releaseMessage when nobody use it
+func acquireMessage() *ItemMessage {
+	return messagePool.Get().(*ItemMessage)
+}
+
+//releaseMessage reset message and return it to pool, slices of message are not reused
+func releaseMessage(m *ItemMessage) {
+	m.Reset()
+	messagePool.Put(m)
+}
+
+//acquireBuffer return empty buffer for marshaled message
+func acquireBuffer() *[]byte {
+	return bufferPool.Get().(*[]byte)
+}
+
+//releaseBuffer return buffer to pool
+func releaseBuffer(b *[]byte) {
+	if cap(*b) > maxPooledBuffer {
+		return
+	}
+	*b = (*b)[:0]
+	bufferPool.Put(b)
+}
+
+//Fields of binary codec, bit of field is (field number in item.proto - 1)
+const (
+	fieldCommand = 1 << iota
+	fieldName
+	fieldExpiration
+	fieldObject
+	fieldCompressed
+	fieldDelta
+	fieldVersion
+	fieldStats
+	fieldRequestID
+	fieldStatus
+	fieldError
+	fieldKeys
+	fieldToken
+	fieldUnknown
+)
+
+//appendBinary append message encoded by binary codec to dst.
+//Message is uvarint mask of present fields followed by these fields in order of field numbers:
+// unsigned numbers and enums are uvarints, signed are varints, strings and bytes are prefixed by uvarint length,
+// Compressed has no payload, Stats is 9 varints in order of StatsMessage fields, Keys is uvarint count of strings.
+//Unlike protobuf empty Object is present, so it is not decoded as nil
+func appendBinary(dst []byte, m *ItemMessage) []byte {
+	var mask uint64
+	if m.Command != 0 {
+		mask |= fieldCommand
+	}
+	if m.Name != "" {
+		mask |= fieldName
+	}
+	if m.Expiration != 0 {
+		mask |= fieldExpiration
+	}
+	if m.Object != nil {
+		mask |= fieldObject
+	}
+	if m.Compressed {
+		mask |= fieldCompressed
+	}
+	if m.Delta != 0 {
+		mask |= fieldDelta
+	}
+	if m.Version != 0 {
+		mask |= fieldVersion
+	}
+	if m.Stats != nil {
+		mask |= fieldStats
+	}
+	if m.RequestID != 0 {
+		mask |= fieldRequestID
+	}
+	if m.Status != 0 {
+		mask |= fieldStatus
+	}
+	if m.Error != "" {
+		mask |= fieldError
+	}
+	if m.Keys != nil {
+		mask |= fieldKeys
+	}
+	if m.Token != "" {
+		mask |= fieldToken
+	}
+
+	dst = binary.AppendUvarint(dst, mask)
+	if mask&fieldCommand != 0 {
+		dst = binary.AppendUvarint(dst, uint64(m.Command))
+	}
+	if mask&fieldName != 0 {
+		dst = appendString(dst, m.Name)
+	}
+	if mask&fieldExpiration != 0 {
+		dst = binary.AppendVarint(dst, m.Expiration)
+	}
+	if mask&fieldObject != 0 {
+		dst = binary.AppendUvarint(dst, uint64(len(m.Object)))
+		dst = append(dst, m.Object...)
+	}
+	if mask&fieldDelta != 0 {
+		dst = binary.AppendVarint(dst, m.Delta)
+	}
+	if mask&fieldVersion != 0 {
+		dst = binary.AppendUvarint(dst, m.Version)
+	}
+	if mask&fieldStats != 0 {
+		s := m.Stats
+		for _, v := range [...]int64{s.ItemsCount, s.GetSuccessNumber, s.GetErrorNumber, s.SetOrReplaceCount,
+			s.DeleteCount, s.DeleteExpired, s.SizeLimit, s.RawBytes, s.CompressedBytes} {
+			dst = binary.AppendVarint(dst, v)
+		}
+	}
+	if mask&fieldRequestID != 0 {
+		dst = binary.AppendUvarint(dst, m.RequestID)
+	}
+	if mask&fieldStatus != 0 {
+		dst = binary.AppendUvarint(dst, uint64(m.Status))
+	}
+	if mask&fieldError != 0 {
+		dst = appendString(dst, m.Error)
+	}
+	if mask&fieldKeys != 0 {
+		dst = binary.AppendUvarint(dst, uint64(len(m.Keys)))
+		for _, key := range m.Keys {
+			dst = appendString(dst, key)
+		}
+	}
+	if mask&fieldToken != 0 {
+		dst = appendString(dst, m.Token)
+	}
+	return dst
+}
+
+func appendString(dst []byte, s string) []byte {
+	dst = binary.AppendUvarint(dst, uint64(len(s)))
+	return append(dst, s...)
+}
+
+//unmarshalBinary decode message of binary codec into m, data is copied so it could be reused
+func unmarshalBinary(data []byte, m *ItemMessage) error {
+	d := binaryDecoder{data: data}
+	mask := d.uvarint()
+	if mask >= fieldUnknown {
+		return errBadMessage
+	}
+	if mask&fieldCommand != 0 {
+		m.Command = ItemMessage_Commands(d.uvarint())
+	}
+	if mask&fieldName != 0 {
+		m.Name = string(d.bytes())
+	}
+	if mask&fieldExpiration != 0 {
+		m.Expiration = d.varint()
+	}
+	if mask&fieldObject != 0 {
+		m.Object = append([]byte{}, d.bytes()...)
+	}
+	m.Compressed = mask&fieldCompressed != 0
+	if mask&fieldDelta != 0 {
+		m.Delta = d.varint()
+	}
+	if mask&fieldVersion != 0 {
+		m.Version = d.uvarint()
+	}
+	if mask&fieldStats != 0 {
+		m.Stats = &StatsMessage{
+			ItemsCount:        d.varint(),
+			GetSuccessNumber:  d.varint(),
+			GetErrorNumber:    d.varint(),
+			SetOrReplaceCount: d.varint(),
+			DeleteCount:       d.varint(),
+			DeleteExpired:     d.varint(),
+			SizeLimit:         d.varint(),
+			RawBytes:          d.varint(),
+			CompressedBytes:   d.varint(),
+		}
+	}
+	if mask&fieldRequestID != 0 {
+		m.RequestID = d.uvarint()
+	}
+	if mask&fieldStatus != 0 {
+		m.Status = ItemMessage_Statuses(d.uvarint())
+	}
+	if mask&fieldError != 0 {
+		m.Error = string(d.bytes())
+	}
+	if mask&fieldKeys != 0 {
+		n := d.uvarint()
+		if n > uint64(len(d.data)) { //every key takes one byte at least
+			return errBadMessage
+		}
+		m.Keys = make([]string, n)
+		for i := range m.Keys {
+			m.Keys[i] = string(d.bytes())
+		}
+	}
+	if mask&fieldToken != 0 {
+		m.Token = string(d.bytes())
+	}
+	if d.err || len(d.data) != 0 {
+		return errBadMessage
+	}
+	return nil
+}
+
+//binaryDecoder read fields of binary codec, err is set when data is too short
+type binaryDecoder struct {
+	data []byte
+	err  bool
+}
+
+func (d *binaryDecoder) uvarint() uint64 {
+	v, n := binary.Uvarint(d.data)
+	if n <= 0 {
+		d.err, d.data = true, nil
+		return 0
+	}
+	d.data = d.data[n:]
+	return v
+}
+
+func (d *binaryDecoder) varint() int64 {
+	v, n := binary.Varint(d.data)
+	if n <= 0 {
+		d.err, d.data = true, nil
+		return 0
+	}
+	d.data = d.data[n:]
+	return v
+}
+
+//bytes return next length prefixed field, it points into data
+func (d *binaryDecoder) bytes() []byte {
+	size := d.uvarint()
+	if size > uint64(len(d.data)) {
+		d.err, d.data = true, nil
+		return nil
+	}
+	b := d.data[:size]
+	d.data = d.data[size:]
+	return b
+}
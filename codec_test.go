@@ -0,0 +1,231 @@
+package gcache
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBinaryCodec_RoundTrip(t *testing.T) {
+	as := assert.New(t)
+	full := &ItemMessage{
+		Command:    ItemMessage_CAS,
+		Name:       "zaza",
+		Expiration: -1,
+		Object:     []byte(`value`),
+		Compressed: true,
+		Delta:      -42,
+		Version:    1 << 40,
+		Stats:      &StatsMessage{ItemsCount: 3, GetErrorNumber: -1, CompressedBytes: 1 << 33},
+		RequestID:  77,
+		Status:     ItemMessage_TRUNCATED,
+		Error:      "failed",
+		Keys:       []string{"a", "", "b"},
+		Token:      "secret",
+	}
+	for _, m := range []*ItemMessage{full, {}, {Object: []byte{}}, {Keys: []string{}}} {
+		data := appendBinary(nil, m)
+		decoded := &ItemMessage{}
+		if as.NoError(unmarshalBinary(data, decoded)) {
+			as.Equal(m, decoded)
+		}
+	}
+
+	data := appendBinary(nil, full)
+	source := append([]byte{}, data...)
+	decoded := &ItemMessage{}
+	as.NoError(unmarshalBinary(source, decoded))
+	for i := range source {
+		source[i] = 0
+	}
+	as.Equal(full, decoded, "message does not point to data")
+
+	for i := 0; i < len(data); i++ {
+		as.Equal(errBadMessage, unmarshalBinary(data[:i], &ItemMessage{}), i)
+	}
+	as.Equal(errBadMessage, unmarshalBinary(append(data, 0), &ItemMessage{}), "trailing data")
+	as.Equal(errBadMessage, unmarshalBinary(appendBinary(nil, &ItemMessage{})[:0], &ItemMessage{}))
+	as.Equal(errBadMessage, unmarshalBinary([]byte{0x80, 0x40}, &ItemMessage{}), "unknown field")
+	as.Equal(errBadMessage, unmarshalBinary([]byte{0x80, 0x10, 0x7f}, &ItemMessage{}), "too many keys")
+}
+
+func TestCodec_Hello(t *testing.T) {
+	as := assert.New(t)
+	for _, m := range []*ItemMessage{{}, {Command: ItemMessage_GET, Name: "zaza"}, {Name: string(bytes.Repeat([]byte(`z`), 28))}} {
+		data, _ := proto.Marshal(m)
+		as.False(isHello(AppendFrame(nil, data)), "protobuf frame is not hello")
+		as.False(isHello(data), "protobuf datagram is not hello")
+	}
+
+	var out bytes.Buffer
+	r := bufio.NewReader(bytes.NewReader([]byte{amulet, 7, 0}))
+	cd, err := acceptCodec(r, &out)
+	as.NoError(err)
+	as.Equal(binaryCodec{}, cd)
+	as.Equal([]byte{amulet, CodecBinary}, out.Bytes(), "the highest supported version")
+	rest, _ := ioutil.ReadAll(r)
+	as.Equal([]byte{0}, rest)
+
+	out.Reset()
+	cd, err = acceptCodec(bufio.NewReader(bytes.NewReader([]byte{0})), &out)
+	as.NoError(err)
+	as.Equal(protoCodec{}, cd)
+	as.Zero(out.Len(), "client without hello")
+
+	cd, version, data := splitHello([]byte{amulet, 5, 1})
+	as.Nil(cd)
+	as.Equal(byte(5), version)
+	as.Equal([]byte{1}, data)
+}
+
+func TestServer_Codec(t *testing.T) {
+	as := assert.New(t)
+	s, cancel, served := startServer(t, NewRwCache(&ConfigMessage{}), ServerOptions{}, modeTCPLong, modeTCPShort, modeUDP)
+	defer func() {
+		cancel()
+		<-served
+	}()
+
+	for _, version := range []byte{CodecProto, CodecBinary} {
+		remote, err := NewRemoteCache(s.Addr(modeTCPLong).String(), RemoteOptions{Codec: version, Timeout: time.Second})
+		if !as.NoError(err) {
+			return
+		}
+		remote.SetOrUpdate("zaza", []byte(`zaza`), NoExpiration)
+		as.Equal([]byte(`zaza`), remote.Get("zaza"), version)
+		remote.SetOrUpdate("empty", []byte{}, NoExpiration)
+		as.Equal([]byte{}, remote.Get("empty"), version)
+		value, err := remote.Incr("counter", 2, NoExpiration)
+		as.NoError(err)
+		as.Equal(int64(2*(version+1)), value, version)
+		_, err = remote.Add("zaza", []byte(`other`), NoExpiration)
+		as.Equal(ErrExists, err, version)
+		as.Len(remote.Keys(), 3, version)
+		as.Equal(int64(3), remote.Statistic().ItemsCount, version)
+		remote.Dead()
+	}
+
+	for _, mode := range []string{modeTCPShort, modeUDP} {
+		network := "tcp"
+		if mode == modeUDP {
+			network = "udp"
+		}
+		conn, err := net.Dial(network, s.Addr(mode).String())
+		if !as.NoError(err) {
+			return
+		}
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		conn.Write(appendBinary(appendHello(nil, CodecBinary), &ItemMessage{Command: ItemMessage_GET, Name: "zaza", RequestID: 3}))
+		if tcp, ok := conn.(*net.TCPConn); ok {
+			tcp.CloseWrite()
+		}
+		buf := make([]byte, maxDatagramSize)
+		n, err := io.ReadAtLeast(conn, buf, 2)
+		if as.NoError(err, mode) && as.True(isHello(buf[:n]), mode) {
+			resp := &ItemMessage{}
+			as.NoError(unmarshalBinary(buf[2:n], resp), mode)
+			as.Equal([]byte(`zaza`), resp.GetObject(), mode)
+			as.Equal(uint64(3), resp.GetRequestID(), mode)
+		}
+		conn.Close()
+	}
+
+	conn, err := net.Dial("udp", s.Addr(modeUDP).String())
+	if !as.NoError(err) {
+		return
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	conn.Write([]byte{amulet, 5, 0})
+	buf := make([]byte, maxDatagramSize)
+	n, err := conn.Read(buf)
+	if as.NoError(err) {
+		resp := &ItemMessage{}
+		as.NoError(proto.Unmarshal(buf[:n], resp), "unknown codec is reported by protobuf")
+		as.Equal(ItemMessage_INVALID, resp.GetStatus())
+	}
+}
+
+func TestRemoteCache_CodecNotSupported(t *testing.T) {
+	as := assert.New(t)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if !as.NoError(err) {
+		return
+	}
+	defer ln.Close()
+	go func() { //server without codec wait the rest of protobuf frame
+		conn, err := ln.Accept()
+		if err == nil {
+			io.Copy(ioutil.Discard, conn)
+		}
+	}()
+	_, err = NewRemoteCache(ln.Addr().String(), RemoteOptions{Codec: CodecBinary, DialTimeout: 100 * time.Millisecond})
+	as.Equal(ErrCodecNotSupported, err)
+}
+
+//benchmarkCodec measure marshal and unmarshal of GET responce
+func benchmarkCodec(cd codec, b *testing.B) {
+	m := &ItemMessage{Command: ItemMessage_SET, Name: "key:000042", Object: bytes.Repeat([]byte(`z`), 100), Version: 42, RequestID: 1 << 20}
+	data, _ := cd.appendMessage(nil, m)
+	b.Run("marshal", func(b *testing.B) {
+		b.ReportAllocs()
+		buf := make([]byte, 0, len(data))
+		for i := 0; i < b.N; i++ {
+			buf, _ = cd.appendMessage(buf[:0], m)
+		}
+	})
+	b.Run("unmarshal", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			t := acquireMessage()
+			cd.unmarshal(data, t)
+			releaseMessage(t)
+		}
+	})
+}
+
+//Benchmarks of codecs, binary codec allocate only Name and Object of decoded message
+func BenchmarkCodec_Proto(b *testing.B)  { benchmarkCodec(protoCodec{}, b) }
+func BenchmarkCodec_Binary(b *testing.B) { benchmarkCodec(binaryCodec{}, b) }
+
+//benchmarkRemote measure allocations of client and server per request via tcp_long
+func benchmarkRemote(version byte, b *testing.B) {
+	c := NewRwCache(&ConfigMessage{})
+	defer c.Dead() //Cleanup
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		b.Fatal(err)
+	}
+	go serveListener(modeTCPLong, ln, c, nil)
+	defer ln.Close()
+	remote, err := NewRemoteCache(ln.Addr().String(), RemoteOptions{Codec: version})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer remote.Dead()
+	value := bytes.Repeat([]byte(`z`), 100)
+	remote.SetOrUpdate("zaza", value, NoExpiration)
+	b.Run("get", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			remote.Get("zaza")
+		}
+	})
+	b.Run("set", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			remote.SetOrUpdate("zaza", value, NoExpiration)
+		}
+	})
+}
+
+//Benchmarks of requests via tcp_long, allocations of client and server are counted together
+func BenchmarkRemote_Proto(b *testing.B)  { benchmarkRemote(CodecProto, b) }
+func BenchmarkRemote_Binary(b *testing.B) { benchmarkRemote(CodecBinary, b) }
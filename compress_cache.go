@@ -0,0 +1,260 @@
+package gcache
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"io"
+	"io/ioutil"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	//DefaultCompressThreshold values smaller than it are stored without compression
+	DefaultCompressThreshold = 1024
+
+	compressFlagRaw   = byte(0)
+	compressFlagFlate = byte(1)
+)
+
+//ErrCompressFlag is returned for value with unknown compression flag byte
+var ErrCompressFlag = errors.New("Unknown compression flag")
+
+var flateWriters = sync.Pool{
+	New: func() interface{} {
+		w, _ := flate.NewWriter(nil, flate.BestSpeed)
+		return w
+	},
+}
+
+//CompressCache is a wrapper over any Cacher which compress values bigger than threshold.
+//Each value is prefixed with flag byte so raw and compressed values can be mixed.
+//Raw and compressed bytes of stored items are reported by Statistic, they are updated on writes and deletes.
+//Values of items expired in the wrapped cache are counted until the cache is purged
+type CompressCache struct {
+	Cacher
+	threshold int
+
+	locks           [compressLocks]sync.Mutex //writes of one name are serialized to subtract replaced value
+	rawBytes        int64
+	compressedBytes int64
+}
+
+//compressLocks is a number of locks of names in CompressCache
+const compressLocks = 64
+
+//NewCompressCache create compression layer over cache
+func NewCompressCache(cache Cacher, threshold int) *CompressCache {
+	if threshold <= 0 {
+		threshold = DefaultCompressThreshold
+	}
+	return &CompressCache{
+		Cacher:    cache,
+		threshold: threshold,
+	}
+}
+
+//Get return decompressed item by name or nil
+func (c *CompressCache) Get(name string) []byte {
+	data := c.Cacher.Get(name)
+	if data == nil {
+		return nil
+	}
+	value, err := DecompressValue(data)
+	if err != nil {
+		return nil
+	}
+	return value
+}
+
+//GetItem return copy of item with decompressed value or nil
+func (c *CompressCache) GetItem(name string) *Item {
+	itm := c.Cacher.GetItem(name)
+	if itm == nil {
+		return nil
+	}
+	value, err := DecompressValue(itm.Object)
+	if err != nil {
+		return nil
+	}
+	itm.Object = value
+	return itm
+}
+
+//SetOrUpdate compress value and set or update it in cache
+func (c *CompressCache) SetOrUpdate(name string, value []byte, exp time.Duration) {
+	c.write(name, value, func(data []byte) error {
+		c.Cacher.SetOrUpdate(name, data, exp)
+		return nil
+	})
+}
+
+//Add compress value and set it only if it is absent
+func (c *CompressCache) Add(name string, value []byte, exp time.Duration) (version uint64, err error) {
+	err = c.write(name, value, func(data []byte) error {
+		version, err = c.Cacher.Add(name, data, exp)
+		return err
+	})
+	return version, err
+}
+
+//Replace compress value and set it only if it is present
+func (c *CompressCache) Replace(name string, value []byte, exp time.Duration) (version uint64, err error) {
+	err = c.write(name, value, func(data []byte) error {
+		version, err = c.Cacher.Replace(name, data, exp)
+		return err
+	})
+	return version, err
+}
+
+//CAS compress value and set it only if version is not changed
+func (c *CompressCache) CAS(name string, value []byte, version uint64, exp time.Duration) (newVersion uint64, err error) {
+	err = c.write(name, value, func(data []byte) error {
+		newVersion, err = c.Cacher.CAS(name, data, version, exp)
+		return err
+	})
+	return newVersion, err
+}
+
+//Delete remove item from cache
+func (c *CompressCache) Delete(name string) bool {
+	l := c.lock(name)
+	l.Lock()
+	defer l.Unlock()
+	old := c.Cacher.Get(name)
+	deleted := c.Cacher.Delete(name)
+	if deleted {
+		c.count(old, -1)
+	}
+	return deleted
+}
+
+//Purge remove all items from cache
+func (c *CompressCache) Purge() {
+	for i := range c.locks {
+		c.locks[i].Lock()
+	}
+	c.Cacher.Purge()
+	atomic.StoreInt64(&c.rawBytes, 0)
+	atomic.StoreInt64(&c.compressedBytes, 0)
+	for i := range c.locks {
+		c.locks[i].Unlock()
+	}
+}
+
+//write compress value and store it by fn, size of replaced value is subtracted if fn succeeds
+func (c *CompressCache) write(name string, value []byte, fn func(data []byte) error) error {
+	data := CompressValue(value, c.threshold)
+	l := c.lock(name)
+	l.Lock()
+	defer l.Unlock()
+	old := c.Cacher.Get(name)
+	if err := fn(data); err != nil {
+		return err
+	}
+	c.count(old, -1)
+	atomic.AddInt64(&c.rawBytes, int64(len(value)))
+	atomic.AddInt64(&c.compressedBytes, int64(len(data)))
+	return nil
+}
+
+//count add sizes of stored data multiplied by sign to counters
+func (c *CompressCache) count(data []byte, sign int64) {
+	if data == nil {
+		return
+	}
+	atomic.AddInt64(&c.rawBytes, sign*rawSize(data))
+	atomic.AddInt64(&c.compressedBytes, sign*int64(len(data)))
+}
+
+//lock return lock of name
+func (c *CompressCache) lock(name string) *sync.Mutex {
+	return &c.locks[calcFNVInline(name)%compressLocks]
+}
+
+//Incr is not supported for compressed values
+func (c *CompressCache) Incr(name string, delta int64, exp time.Duration) (int64, error) {
+	return 0, ErrNotSupported
+}
+
+//Decr is not supported for compressed values
+func (c *CompressCache) Decr(name string, delta int64, exp time.Duration) (int64, error) {
+	return 0, ErrNotSupported
+}
+
+//Statistic return statistic of cache with raw and compressed bytes of stored items
+func (c *CompressCache) Statistic() Stats {
+	s := c.Cacher.Statistic()
+	s.RawBytes += atomic.LoadInt64(&c.rawBytes)
+	s.CompressedBytes += atomic.LoadInt64(&c.compressedBytes)
+	return s
+}
+
+//rawSize return size of value produced by CompressValue without decompressed copy, broken value is 0
+func rawSize(data []byte) int64 {
+	if len(data) == 0 {
+		return 0
+	}
+	switch data[0] {
+	case compressFlagRaw:
+		return int64(len(data) - 1)
+	case compressFlagFlate:
+		r := flate.NewReader(bytes.NewReader(data[1:]))
+		defer r.Close()
+		if n, err := io.Copy(ioutil.Discard, r); err == nil {
+			return n
+		}
+	}
+	return 0
+}
+
+//CompressValue prefix value with flag byte and compress it if value is not smaller than threshold
+//and compression makes it shorter
+func CompressValue(value []byte, threshold int) []byte {
+	if len(value) >= threshold {
+		var buf bytes.Buffer
+		buf.Grow(len(value)/2 + 1)
+		buf.WriteByte(compressFlagFlate)
+		w := flateWriters.Get().(*flate.Writer)
+		w.Reset(&buf)
+		_, err := w.Write(value)
+		if err == nil {
+			err = w.Close()
+		}
+		flateWriters.Put(w)
+		if err == nil && buf.Len() < len(value)+1 {
+			return buf.Bytes()
+		}
+	}
+	data := make([]byte, len(value)+1)
+	data[0] = compressFlagRaw
+	copy(data[1:], value)
+	return data
+}
+
+//DecompressValue decode value produced by CompressValue, value bigger than DefaultMaxFrameSize is ErrFrameTooLarge
+func DecompressValue(data []byte) ([]byte, error) {
+	return decompressValue(data, DefaultMaxFrameSize)
+}
+
+//decompressValue is DecompressValue with limit of decompressed size
+func decompressValue(data []byte, limit int) ([]byte, error) {
+	if len(data) == 0 {
+		return data, nil
+	}
+	switch data[0] {
+	case compressFlagRaw:
+		return data[1:], nil
+	case compressFlagFlate:
+		r := flate.NewReader(bytes.NewReader(data[1:]))
+		value, err := ioutil.ReadAll(io.LimitReader(r, int64(limit)+1))
+		r.Close()
+		if err == nil && len(value) > limit {
+			return nil, ErrFrameTooLarge
+		}
+		return value, err
+	}
+	return nil, ErrCompressFlag
+}
@@ -0,0 +1,155 @@
+package gcache
+
+import (
+	"bytes"
+	"compress/flate"
+	"sync"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompressCache_SetOrUpdate(t *testing.T) {
+	as := assert.New(t)
+	inner := NewRwCache(defaultConfig())
+	c := NewCompressCache(inner, 100)
+	big := bytes.Repeat([]byte(`{"zaza":"azaz"}`), 100)
+
+	c.SetOrUpdate("small", []byte(`zaza`), DefaultExpirationMarker)
+	c.SetOrUpdate("big", big, DefaultExpirationMarker)
+
+	as.Equal([]byte{compressFlagRaw, 'z', 'a', 'z', 'a'}, inner.Get("small"))
+	as.Equal(compressFlagFlate, inner.Get("big")[0])
+	as.True(len(inner.Get("big")) < len(big)/5)
+
+	as.Equal([]byte(`zaza`), c.Get("small"))
+	as.Equal(big, c.Get("big"))
+	as.Nil(c.Get("irst"))
+
+	s := c.Statistic()
+	as.Equal(int64(len(big)+4), s.RawBytes)
+	as.Equal(int64(len(inner.Get("big"))+5), s.CompressedBytes)
+	as.Equal(int64(2), s.ItemsCount)
+
+	c.SetOrUpdate("big", []byte(`zazazaza`), DefaultExpirationMarker)
+	s = c.Statistic()
+	as.Equal(int64(12), s.RawBytes, "overwritten value is subtracted")
+	as.Equal(int64(14), s.CompressedBytes)
+	c.Delete("small")
+	c.Delete("small")
+	_, err := c.Add("big", []byte(`zaza`), DefaultExpirationMarker)
+	as.Equal(ErrExists, err)
+	s = c.Statistic()
+	as.Equal(int64(8), s.RawBytes, "deleted value is subtracted once, failed write is not counted")
+	as.Equal(int64(9), s.CompressedBytes)
+
+	var wg sync.WaitGroup
+	for i := 1; i <= 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			c.SetOrUpdate("big", bytes.Repeat(big, i), DefaultExpirationMarker)
+		}(i)
+	}
+	wg.Wait()
+	s = c.Statistic()
+	as.Equal(int64(len(c.Get("big"))), s.RawBytes, "concurrent writers replace each other")
+	as.Equal(int64(len(inner.Get("big"))), s.CompressedBytes)
+	c.Purge()
+	s = c.Statistic()
+	as.Zero(s.RawBytes)
+	as.Zero(s.CompressedBytes)
+
+	c.Dead() //Cleanup
+}
+
+func TestCompressCache_Mixed(t *testing.T) {
+	as := assert.New(t)
+	inner := NewRwCache(defaultConfig())
+	c := NewCompressCache(inner, 1)
+
+	random := []byte{0x8f, 0x13, 0xa1, 0x07}
+	c.SetOrUpdate("incompressible", random, DefaultExpirationMarker)
+	as.Equal(compressFlagRaw, inner.Get("incompressible")[0])
+	as.Equal(random, c.Get("incompressible"))
+
+	inner.SetOrUpdate("unknown", []byte{42, 1}, DefaultExpirationMarker)
+	as.Nil(c.Get("unknown"))
+
+	c.Dead() //Cleanup
+}
+
+func TestCompressCache_Wire(t *testing.T) {
+	as := assert.New(t)
+	c := NewRwCache(defaultConfig())
+	big := bytes.Repeat([]byte(`zaza`), 1000)
+
+	_, err := handleRequest(&ItemMessage{
+		Command:    ItemMessage_SET,
+		Name:       "big",
+		Object:     CompressValue(big, 1),
+		Compressed: true,
+	}, c)
+	as.NoError(err)
+	as.Equal(big, c.Get("big"))
+
+	data, err := handleRequest(&ItemMessage{Command: ItemMessage_GET, Name: "big", Compressed: true}, c)
+	as.NoError(err)
+	resp := &ItemMessage{}
+	as.NoError(proto.Unmarshal(data, resp))
+	as.True(resp.GetCompressed())
+	as.True(len(resp.GetObject()) < len(big))
+	value, err := DecompressValue(resp.GetObject())
+	as.NoError(err)
+	as.Equal(big, value)
+
+	data, err = handleRequest(&ItemMessage{Command: ItemMessage_GET, Name: "big"}, c)
+	as.NoError(err)
+	resp = &ItemMessage{}
+	as.NoError(proto.Unmarshal(data, resp))
+	as.False(resp.GetCompressed())
+	as.Equal(big, resp.GetObject())
+
+	c.Dead() //Cleanup
+}
+
+//zeroBomb return compressed value of size zero bytes, one flushed block of 1Mb zeros is repeated
+func zeroBomb(size int) []byte {
+	var block bytes.Buffer
+	w, _ := flate.NewWriter(&block, flate.BestSpeed)
+	w.Write(make([]byte, 1<<20))
+	w.Flush()
+	data := []byte{compressFlagFlate}
+	for i := 0; i < size>>20; i++ {
+		data = append(data, block.Bytes()...)
+	}
+	w.Reset(&block)
+	block.Reset()
+	w.Write(make([]byte, size&(1<<20-1)))
+	w.Close()
+	return append(data, block.Bytes()...)
+}
+
+func TestDecompressValue_Limit(t *testing.T) {
+	as := assert.New(t)
+	value, err := decompressValue(zeroBomb(1<<20), 1<<20)
+	as.NoError(err)
+	as.Len(value, 1<<20)
+	_, err = decompressValue(zeroBomb(1<<20+1), 1<<20)
+	as.Equal(ErrFrameTooLarge, err)
+
+	bomb := zeroBomb(DefaultMaxFrameSize + 1)
+	as.True(len(bomb) < 1<<20)
+	c := NewRwCache(defaultConfig())
+	_, err = handleRequest(&ItemMessage{Command: ItemMessage_SET, Name: "bomb", Object: bomb, Compressed: true}, c)
+	as.Equal(ErrFrameTooLarge, err)
+	as.Nil(c.Get("bomb"))
+	c.Dead() //Cleanup
+}
+
+func TestCompressCache_CAS(t *testing.T) {
+	c := NewCompressCache(NewRwCache(defaultConfig()), 1)
+	checkVersionedCacher(t, c)
+	c.Dead() //Cleanup
+}
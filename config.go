@@ -1,5 +1,7 @@
 package gcache
 
+import "errors"
+
 //ConfigCacheInterface interface for Default caches like rwCache or sgCache of syncCache
 type ConfigCacheInterface interface {
 	GetDefaultExpiration() int64
@@ -13,3 +15,37 @@ type ConfigShardCacheInterface interface {
 	GetShardCount() int64
 	GetCacheType() ConfigMessage_CacheTypes
 }
+
+//ConfigOffHeapCacheInterface extended interface for off-heap cache
+type ConfigOffHeapCacheInterface interface {
+	ConfigShardCacheInterface
+	GetMemoryLimit() int64
+}
+
+//Reconfigurable is a cache which apply DefaultExpiration and SizeLimit of config without restart
+type Reconfigurable interface {
+	Reconfigure(config ConfigCacheInterface)
+}
+
+//NewCache create cache described by config, RWL and SINGLEGORUTINE caches are sharded if ShardCount is bigger than one
+func NewCache(config *ConfigMessage) (Cacher, error) {
+	var generator ShardGenerator
+	switch config.GetCacheType() {
+	case ConfigMessage_RWL:
+		generator = func(c ConfigCacheInterface) Cacher { return NewRwCache(c) }
+	case ConfigMessage_SINGLEGORUTINE:
+		generator = func(c ConfigCacheInterface) Cacher { return NewGorCache(c) }
+	case ConfigMessage_OFFHEAP:
+		return NewOffHeapCache(config), nil
+	default:
+		return nil, errors.New("Cache type is not supported: " + config.GetCacheType().String())
+	}
+	hashCalc, ok := hashFuncs[config.GetHashFunc()]
+	if !ok {
+		return nil, errors.New("Unknown hash function: " + config.GetHashFunc())
+	}
+	if config.GetShardCount() > 1 {
+		return NewShardCache(config, generator, hashCalc), nil
+	}
+	return generator(config), nil
+}
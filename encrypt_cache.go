@@ -0,0 +1,247 @@
+package gcache
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	sealVersion    = byte(1)
+	sealHeaderSize = 5 //version(1) key id(4)
+)
+
+var (
+	//ErrDecrypt is returned for tampered value or value sealed by another key
+	ErrDecrypt = errors.New("Could not decrypt value")
+	//ErrUnknownKey is returned by KeyProvider when key id is not known
+	ErrUnknownKey = errors.New("Unknown encryption key")
+)
+
+//KeyProvider supplies AES keys (16, 24 or 32 bytes) for EncryptCache, key of an id should never change
+type KeyProvider interface {
+	//CurrentKey return key and its id for sealing new values
+	CurrentKey() (uint32, []byte, error)
+	//Key return key by id for opening stored values
+	Key(id uint32) ([]byte, error)
+}
+
+//StaticKeyProvider keeps all keys in memory
+type StaticKeyProvider struct {
+	l       sync.RWMutex
+	current uint32
+	keys    map[uint32][]byte
+}
+
+//NewStaticKeyProvider create key provider with one current key
+func NewStaticKeyProvider(id uint32, key []byte) *StaticKeyProvider {
+	return &StaticKeyProvider{
+		current: id,
+		keys:    map[uint32][]byte{id: key},
+	}
+}
+
+//Rotate add new key and use it for sealing, old keys are still used for opening
+func (p *StaticKeyProvider) Rotate(id uint32, key []byte) {
+	p.l.Lock()
+	p.keys[id] = key
+	p.current = id
+	p.l.Unlock()
+}
+
+//CurrentKey return current key
+func (p *StaticKeyProvider) CurrentKey() (uint32, []byte, error) {
+	p.l.RLock()
+	defer p.l.RUnlock()
+	return p.current, p.keys[p.current], nil
+}
+
+//Key return key by id
+func (p *StaticKeyProvider) Key(id uint32) ([]byte, error) {
+	p.l.RLock()
+	defer p.l.RUnlock()
+	if key, ok := p.keys[id]; ok {
+		return key, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+//EncryptCache is a wrapper over any Cacher which seals values with AES-GCM.
+//Key id is stored with value so keys can be rotated, name of item is authenticated too.
+type EncryptCache struct {
+	Cacher
+	keys    KeyProvider
+	aeads   sync.Map //key id -> cipher.AEAD
+	dropped int64    //writes of SetOrUpdate which are not stored
+}
+
+//NewEncryptCache create encryption layer over cache
+func NewEncryptCache(cache Cacher, keys KeyProvider) *EncryptCache {
+	return &EncryptCache{
+		Cacher: cache,
+		keys:   keys,
+	}
+}
+
+//Get return decrypted item by name or nil, tampered values are treated as missed
+func (c *EncryptCache) Get(name string) []byte {
+	value, _ := c.Lookup(name)
+	return value
+}
+
+//Lookup return decrypted item by name, nil without error if item is not found
+func (c *EncryptCache) Lookup(name string) ([]byte, error) {
+	data := c.Cacher.Get(name)
+	if data == nil {
+		return nil, nil
+	}
+	return c.open(name, data)
+}
+
+//GetItem return copy of item with decrypted value or nil
+func (c *EncryptCache) GetItem(name string) *Item {
+	itm := c.Cacher.GetItem(name)
+	if itm == nil {
+		return nil
+	}
+	value, err := c.open(name, itm.Object)
+	if err != nil {
+		return nil
+	}
+	itm.Object = value
+	return itm
+}
+
+//SetOrUpdate encrypt value and set or update it in cache
+//Value is not stored if key provider fails, such writes are counted by Dropped
+func (c *EncryptCache) SetOrUpdate(name string, value []byte, exp time.Duration) {
+	data, err := c.seal(name, value)
+	if err != nil {
+		atomic.AddInt64(&c.dropped, 1)
+		return
+	}
+	c.Cacher.SetOrUpdate(name, data, exp)
+}
+
+//Dropped return number of SetOrUpdate writes which are not stored because value could not be encrypted
+func (c *EncryptCache) Dropped() int64 {
+	return atomic.LoadInt64(&c.dropped)
+}
+
+//Sealed return inner cache with encrypted values, snapshot of EncryptCache is written from it
+func (c *EncryptCache) Sealed() Cacher {
+	return c.Cacher
+}
+
+//Add encrypt value and set it only if it is absent
+func (c *EncryptCache) Add(name string, value []byte, exp time.Duration) (uint64, error) {
+	data, err := c.seal(name, value)
+	if err != nil {
+		return 0, err
+	}
+	return c.Cacher.Add(name, data, exp)
+}
+
+//Replace encrypt value and set it only if it is present
+func (c *EncryptCache) Replace(name string, value []byte, exp time.Duration) (uint64, error) {
+	data, err := c.seal(name, value)
+	if err != nil {
+		return 0, err
+	}
+	return c.Cacher.Replace(name, data, exp)
+}
+
+//CAS encrypt value and set it only if version is not changed
+func (c *EncryptCache) CAS(name string, value []byte, version uint64, exp time.Duration) (uint64, error) {
+	data, err := c.seal(name, value)
+	if err != nil {
+		return 0, err
+	}
+	return c.Cacher.CAS(name, data, version, exp)
+}
+
+//Incr is not supported for encrypted values
+func (c *EncryptCache) Incr(name string, delta int64, exp time.Duration) (int64, error) {
+	return 0, ErrNotSupported
+}
+
+//Decr is not supported for encrypted values
+func (c *EncryptCache) Decr(name string, delta int64, exp time.Duration) (int64, error) {
+	return 0, ErrNotSupported
+}
+
+func (c *EncryptCache) aead(id uint32, key []byte) (cipher.AEAD, error) {
+	if aead, ok := c.aeads.Load(id); ok {
+		return aead.(cipher.AEAD), nil
+	}
+	if key == nil {
+		var err error
+		if key, err = c.keys.Key(id); err != nil {
+			return nil, err
+		}
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	c.aeads.Store(id, aead)
+	return aead, nil
+}
+
+//seal produce version(1) | key id(4) | nonce | ciphertext
+func (c *EncryptCache) seal(name string, value []byte) ([]byte, error) {
+	id, key, err := c.keys.CurrentKey()
+	if err != nil {
+		return nil, err
+	}
+	aead, err := c.aead(id, key)
+	if err != nil {
+		return nil, err
+	}
+	nonceSize := aead.NonceSize()
+	data := make([]byte, sealHeaderSize+nonceSize, sealHeaderSize+nonceSize+len(value)+aead.Overhead())
+	data[0] = sealVersion
+	binary.BigEndian.PutUint32(data[1:sealHeaderSize], id)
+	nonce := data[sealHeaderSize:]
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(data, nonce, value, sealAdditional(data[:sealHeaderSize], name)), nil
+}
+
+func (c *EncryptCache) open(name string, data []byte) ([]byte, error) {
+	if len(data) < sealHeaderSize || data[0] != sealVersion {
+		return nil, ErrDecrypt
+	}
+	aead, err := c.aead(binary.BigEndian.Uint32(data[1:sealHeaderSize]), nil)
+	if err != nil {
+		return nil, err
+	}
+	nonceSize := aead.NonceSize()
+	if len(data) < sealHeaderSize+nonceSize+aead.Overhead() {
+		return nil, ErrDecrypt
+	}
+	nonce := data[sealHeaderSize : sealHeaderSize+nonceSize]
+	value, err := aead.Open(nil, nonce, data[sealHeaderSize+nonceSize:], sealAdditional(data[:sealHeaderSize], name))
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return value, nil
+}
+
+//sealAdditional bind header and item name to ciphertext
+func sealAdditional(header []byte, name string) []byte {
+	ad := make([]byte, 0, len(header)+len(name))
+	ad = append(ad, header...)
+	return append(ad, name...)
+}
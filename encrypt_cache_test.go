@@ -0,0 +1,139 @@
+package gcache
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	testKey1 = bytes.Repeat([]byte{1}, 32)
+	testKey2 = bytes.Repeat([]byte{2}, 16)
+)
+
+func TestEncryptCache_SetOrUpdate(t *testing.T) {
+	as := assert.New(t)
+	inner := NewRwCache(defaultConfig())
+	c := NewEncryptCache(inner, NewStaticKeyProvider(1, testKey1))
+
+	c.SetOrUpdate("first", []byte(`zaza`), DefaultExpirationMarker)
+	c.SetOrUpdate("second", []byte(`zaza`), DefaultExpirationMarker)
+
+	as.Equal([]byte(`zaza`), c.Get("first"))
+	as.Equal([]byte(`zaza`), c.Get("second"))
+	as.Nil(c.Get("irst"))
+	as.False(bytes.Contains(inner.Get("first"), []byte(`zaza`)))
+	as.NotEqual(inner.Get("first"), inner.Get("second"), "nonce should be random")
+
+	c.Dead() //Cleanup
+}
+
+//failedKeys is a key provider which is not available
+type failedKeys struct{}
+
+func (failedKeys) CurrentKey() (uint32, []byte, error) { return 0, nil, ErrUnknownKey }
+func (failedKeys) Key(id uint32) ([]byte, error)       { return nil, ErrUnknownKey }
+
+func TestEncryptCache_Dropped(t *testing.T) {
+	as := assert.New(t)
+	inner := NewRwCache(defaultConfig())
+	c := NewEncryptCache(inner, failedKeys{})
+	c.SetOrUpdate("first", []byte(`zaza`), DefaultExpirationMarker)
+	as.Nil(inner.Get("first"))
+	as.Equal(int64(1), c.Dropped())
+	_, err := c.Add("first", []byte(`zaza`), DefaultExpirationMarker)
+	as.Equal(ErrUnknownKey, err)
+
+	c.Dead() //Cleanup
+}
+
+func TestEncryptCache_Snapshot(t *testing.T) {
+	as := assert.New(t)
+	keys := NewStaticKeyProvider(1, testKey1)
+	c := NewEncryptCache(NewRwCache(defaultConfig()), keys)
+	c.SetOrUpdate("first", []byte(`secret value`), DefaultExpirationMarker)
+
+	buf := &bytes.Buffer{}
+	as.NoError(WriteSnapshot(buf, c))
+	as.False(bytes.Contains(buf.Bytes(), []byte(`secret value`)), "snapshot keeps value encrypted")
+	restored := NewEncryptCache(NewRwCache(defaultConfig()), keys)
+	n, err := ReadSnapshot(buf, restored)
+	as.NoError(err)
+	as.Equal(1, n)
+	as.Equal([]byte(`secret value`), restored.Get("first"))
+	restored.Dead() //Cleanup
+
+	path := filepath.Join(t.TempDir(), "snapshot")
+	s, cancel, served := startServer(t, c, ServerOptions{SnapshotPath: path}, modeTCPLong)
+	s.Cache().SetOrUpdate("second", []byte(`other secret`), DefaultExpirationMarker)
+	cancel()
+	as.Equal(ErrServerClosed, <-served)
+	data, err := ioutil.ReadFile(path)
+	if as.NoError(err) {
+		as.False(bytes.Contains(data, []byte(`secret value`)), "snapshot of server keeps value encrypted")
+		as.False(bytes.Contains(data, []byte(`other secret`)))
+	}
+	restored = NewEncryptCache(NewRwCache(defaultConfig()), keys)
+	n, err = LoadSnapshot(path, restored)
+	as.NoError(err)
+	as.Equal(2, n)
+	as.Equal([]byte(`other secret`), restored.Get("second"))
+	restored.Dead() //Cleanup
+}
+
+func TestEncryptCache_Tampered(t *testing.T) {
+	as := assert.New(t)
+	inner := NewRwCache(defaultConfig())
+	c := NewEncryptCache(inner, NewStaticKeyProvider(1, testKey1))
+	c.SetOrUpdate("first", []byte(`zaza`), DefaultExpirationMarker)
+
+	data := append([]byte(nil), inner.Get("first")...)
+	data[len(data)-1] ^= 1
+	inner.SetOrUpdate("first", data, DefaultExpirationMarker)
+	v, err := c.Lookup("first")
+	as.Equal(ErrDecrypt, err)
+	as.Nil(v)
+	as.Nil(c.Get("first"))
+
+	c.SetOrUpdate("second", []byte(`azaz`), DefaultExpirationMarker)
+	inner.SetOrUpdate("moved", inner.Get("second"), DefaultExpirationMarker)
+	_, err = c.Lookup("moved")
+	as.Equal(ErrDecrypt, err, "value is bound to its name")
+
+	inner.SetOrUpdate("short", []byte{sealVersion, 0}, DefaultExpirationMarker)
+	_, err = c.Lookup("short")
+	as.Equal(ErrDecrypt, err)
+
+	c.Dead() //Cleanup
+}
+
+func TestEncryptCache_Rotate(t *testing.T) {
+	as := assert.New(t)
+	inner := NewRwCache(defaultConfig())
+	keys := NewStaticKeyProvider(1, testKey1)
+	c := NewEncryptCache(inner, keys)
+	c.SetOrUpdate("old", []byte(`zaza`), DefaultExpirationMarker)
+
+	keys.Rotate(2, testKey2)
+	c.SetOrUpdate("new", []byte(`azaz`), DefaultExpirationMarker)
+
+	as.Equal([]byte{0, 0, 0, 1}, inner.Get("old")[1:5])
+	as.Equal([]byte{0, 0, 0, 2}, inner.Get("new")[1:5])
+	as.Equal([]byte(`zaza`), c.Get("old"))
+	as.Equal([]byte(`azaz`), c.Get("new"))
+
+	other := NewEncryptCache(inner, NewStaticKeyProvider(2, testKey2))
+	_, err := other.Lookup("old")
+	as.Equal(ErrUnknownKey, err)
+
+	c.Dead() //Cleanup
+}
+
+func TestEncryptCache_CAS(t *testing.T) {
+	c := NewEncryptCache(NewRwCache(defaultConfig()), NewStaticKeyProvider(1, testKey1))
+	checkVersionedCacher(t, c)
+	c.Dead() //Cleanup
+}
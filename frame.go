@@ -0,0 +1,108 @@
+package gcache
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math"
+)
+
+//DefaultMaxFrameSize is a maximum size of one message in stream connections
+const DefaultMaxFrameSize = 64 << 20 //64Mb
+
+//frameSkipFactor is a multiple of maximum size of frame which is skipped, bigger frame close stream
+const frameSkipFactor = 4
+
+var (
+	//ErrFrameTooLarge is returned for frame bigger than allowed size, the frame is skipped
+	ErrFrameTooLarge = errors.New("Frame is too large")
+	//ErrFrameSize is returned for frame length which is not skipped because it is much bigger than allowed size,
+	// stream is out of sync and should be closed
+	ErrFrameSize = errors.New("Frame size is out of range")
+)
+
+//AppendFrame append varint length prefixed data to dst
+func AppendFrame(dst, data []byte) []byte {
+	var prefix [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(prefix[:], uint64(len(data)))
+	dst = append(dst, prefix[:n]...)
+	return append(dst, data...)
+}
+
+//WriteFrame write varint length prefixed data, it does not allocate if w is *bufio.Writer
+func WriteFrame(w io.Writer, data []byte) error {
+	if bw, ok := w.(*bufio.Writer); ok {
+		if _, err := bw.Write(binary.AppendUvarint(bw.AvailableBuffer(), uint64(len(data)))); err != nil {
+			return err
+		}
+		_, err := bw.Write(data)
+		return err
+	}
+	_, err := w.Write(AppendFrame(make([]byte, 0, len(data)+binary.MaxVarintLen64), data))
+	return err
+}
+
+//FrameReader read varint length prefixed frames from stream, it handles partial reads
+// and many frames inside one read
+type FrameReader struct {
+	r       *bufio.Reader
+	maxSize int
+	maxSkip uint64 //bigger frame is ErrFrameSize
+	buf     []byte
+}
+
+//NewFrameReader create frame reader, maxSize <= 0 mean DefaultMaxFrameSize
+func NewFrameReader(r io.Reader, maxSize int) *FrameReader {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxFrameSize
+	}
+	maxSkip := uint64(math.MaxInt64)
+	if maxSize <= math.MaxInt64/frameSkipFactor {
+		maxSkip = uint64(maxSize) * frameSkipFactor
+	}
+	return &FrameReader{
+		r:       bufio.NewReader(r),
+		maxSize: maxSize,
+		maxSkip: maxSkip,
+	}
+}
+
+//ReadFrame return next frame, data is valid until next call
+//ErrFrameTooLarge is returned after skipping oversized frame so reading can be continued,
+// frame bigger than frameSkipFactor of maxSize is not skipped and ErrFrameSize is returned. Other errors are fatal too
+func (f *FrameReader) ReadFrame() ([]byte, error) {
+	size, err := binary.ReadUvarint(f.r)
+	if err != nil {
+		return nil, err
+	}
+	if size > uint64(f.maxSize) {
+		if size > f.maxSkip {
+			return nil, ErrFrameSize
+		}
+		if _, err := io.CopyN(ioutil.Discard, f.r, int64(size)); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		return nil, ErrFrameTooLarge
+	}
+	if cap(f.buf) < int(size) {
+		f.buf = make([]byte, size)
+	}
+	f.buf = f.buf[:size]
+	if _, err := io.ReadFull(f.r, f.buf); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return f.buf, nil
+}
+
+//Buffered return number of bytes which are read from stream but not returned yet
+func (f *FrameReader) Buffered() int {
+	return f.r.Buffered()
+}
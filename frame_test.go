@@ -0,0 +1,102 @@
+package gcache
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFrameReader_Pipelined(t *testing.T) {
+	as := assert.New(t)
+	big := bytes.Repeat([]byte(`zaza`), 1000)
+	var buf bytes.Buffer
+	as.NoError(WriteFrame(&buf, []byte(`first`)))
+	as.NoError(WriteFrame(&buf, nil))
+	as.NoError(WriteFrame(&buf, big))
+
+	for name, r := range map[string]io.Reader{
+		"one write":  bytes.NewReader(buf.Bytes()),
+		"one byte":   iotest.OneByteReader(bytes.NewReader(buf.Bytes())),
+		"half reads": iotest.HalfReader(bytes.NewReader(buf.Bytes())),
+	} {
+		f := NewFrameReader(r, 0)
+		data, err := f.ReadFrame()
+		as.NoError(err, name)
+		as.Equal([]byte(`first`), data, name)
+		data, err = f.ReadFrame()
+		as.NoError(err, name)
+		as.Empty(data, name)
+		data, err = f.ReadFrame()
+		as.NoError(err, name)
+		as.Equal(big, data, name)
+		_, err = f.ReadFrame()
+		as.Equal(io.EOF, err, name)
+	}
+}
+
+func TestFrameReader_TooLarge(t *testing.T) {
+	as := assert.New(t)
+	data := AppendFrame(nil, bytes.Repeat([]byte(`z`), 11))
+	data = AppendFrame(data, []byte(`zaza`))
+
+	f := NewFrameReader(bytes.NewReader(data), 10)
+	_, err := f.ReadFrame()
+	as.Equal(ErrFrameTooLarge, err)
+	frame, err := f.ReadFrame()
+	as.NoError(err, "oversized frame is skipped")
+	as.Equal([]byte(`zaza`), frame)
+
+	f = NewFrameReader(bytes.NewReader(data[:5]), 0)
+	_, err = f.ReadFrame()
+	as.Equal(io.ErrUnexpectedEOF, err)
+}
+
+func TestFrameReader_SizeOverflow(t *testing.T) {
+	as := assert.New(t)
+	data := []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01} //max uint64
+	data = AppendFrame(data, []byte(`zaza`))
+	f := NewFrameReader(bytes.NewReader(data), 10)
+	_, err := f.ReadFrame()
+	as.Equal(ErrFrameSize, err)
+
+	data = AppendFrame(nil, bytes.Repeat([]byte(`z`), 41))
+	data = AppendFrame(data, bytes.Repeat([]byte(`z`), 40))
+	data = AppendFrame(data, []byte(`zaza`))
+	f = NewFrameReader(bytes.NewReader(data), 10)
+	_, err = f.ReadFrame()
+	as.Equal(ErrFrameSize, err, "frame bigger than frameSkipFactor of maxSize is not skipped")
+	f = NewFrameReader(bytes.NewReader(data[42:]), 10)
+	_, err = f.ReadFrame()
+	as.Equal(ErrFrameTooLarge, err)
+	frame, err := f.ReadFrame()
+	as.NoError(err)
+	as.Equal([]byte(`zaza`), frame)
+
+	data = AppendFrame(nil, bytes.Repeat([]byte(`z`), 100))
+	f = NewFrameReader(bytes.NewReader(data[:50]), 40)
+	_, err = f.ReadFrame()
+	as.Equal(io.ErrUnexpectedEOF, err, "short skip is fatal")
+}
+
+func TestHandleLongTCP_SizeOverflow(t *testing.T) {
+	as := assert.New(t)
+	c := NewRwCache(defaultConfig())
+	ln := startLongTCP(t, c)
+	defer ln.Close()
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if !as.NoError(err) {
+		return
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	conn.Write([]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01})
+	_, err = conn.Read(make([]byte, 1))
+	as.Equal(io.EOF, err, "connection is closed")
+
+	c.Dead() //Cleanup
+}
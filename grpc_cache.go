@@ -0,0 +1,227 @@
+package gcache
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+//GRPCCache is a client of grpc server, it implements Cacher
+type GRPCCache struct {
+	conn    *grpc.ClientConn
+	client  CacheServiceClient
+	timeout time.Duration
+}
+
+//NewGRPCCache use connection to grpc server, timeout of one request is not limited if zero
+func NewGRPCCache(conn *grpc.ClientConn, timeout time.Duration) *GRPCCache {
+	return &GRPCCache{
+		conn:    conn,
+		client:  NewCacheServiceClient(conn),
+		timeout: timeout,
+	}
+}
+
+func (c *GRPCCache) context() (context.Context, context.CancelFunc) {
+	if c.timeout > 0 {
+		return context.WithTimeout(context.Background(), c.timeout)
+	}
+	return context.WithCancel(context.Background())
+}
+
+//grpcCacheError restore cache error from grpc error
+func grpcCacheError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if known := knownError(status.Convert(err).Message()); known != nil {
+		return known
+	}
+	return err
+}
+
+//Get return value or nil if it is missing or server is unavailable
+func (c *GRPCCache) Get(name string) []byte {
+	if itm := c.GetItem(name); itm != nil {
+		return itm.Object
+	}
+	return nil
+}
+
+//GetItem return copy of item with expiration and version
+func (c *GRPCCache) GetItem(name string) *Item {
+	ctx, cancel := c.context()
+	defer cancel()
+	resp, err := c.client.Get(ctx, &ItemMessage{Name: name})
+	if err != nil {
+		return nil
+	}
+	return messageItem(resp)
+}
+
+//messageItem convert GET responce to Item
+func messageItem(resp *ItemMessage) *Item {
+	object := resp.GetObject()
+	if object == nil {
+		object = []byte{}
+	}
+	return &Item{
+		Object:     object,
+		Expiration: resp.GetExpiration(),
+		Version:    resp.GetVersion(),
+	}
+}
+
+//SetOrUpdate store value and wait acknowledge of server
+func (c *GRPCCache) SetOrUpdate(name string, value []byte, exp time.Duration) {
+	ctx, cancel := c.context()
+	defer cancel()
+	c.client.Set(ctx, &ItemMessage{Name: name, Object: value, Expiration: int64(exp)})
+}
+
+func (c *GRPCCache) do(req *ItemMessage) (*ItemMessage, error) {
+	ctx, cancel := c.context()
+	defer cancel()
+	resp, err := c.client.Do(ctx, req)
+	return resp, grpcCacheError(err)
+}
+
+//Incr atomically increase integer value on server
+func (c *GRPCCache) Incr(name string, delta int64, exp time.Duration) (int64, error) {
+	return c.incr(ItemMessage_INCR, name, delta, exp)
+}
+
+//Decr atomically decrease integer value on server
+func (c *GRPCCache) Decr(name string, delta int64, exp time.Duration) (int64, error) {
+	return c.incr(ItemMessage_DECR, name, delta, exp)
+}
+
+func (c *GRPCCache) incr(cmd ItemMessage_Commands, name string, delta int64, exp time.Duration) (int64, error) {
+	resp, err := c.do(&ItemMessage{Command: cmd, Name: name, Delta: delta, Expiration: int64(exp)})
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(string(resp.GetObject()), 10, 64)
+}
+
+//Add store value only if name is missing
+func (c *GRPCCache) Add(name string, value []byte, exp time.Duration) (uint64, error) {
+	return c.versioned(&ItemMessage{Command: ItemMessage_ADD, Name: name, Object: value, Expiration: int64(exp)})
+}
+
+//Replace store value only if name is present
+func (c *GRPCCache) Replace(name string, value []byte, exp time.Duration) (uint64, error) {
+	return c.versioned(&ItemMessage{Command: ItemMessage_REPLACE, Name: name, Object: value, Expiration: int64(exp)})
+}
+
+//CAS store value only if current version is equal to version
+func (c *GRPCCache) CAS(name string, value []byte, version uint64, exp time.Duration) (uint64, error) {
+	return c.versioned(&ItemMessage{Command: ItemMessage_CAS, Name: name, Object: value, Version: version, Expiration: int64(exp)})
+}
+
+func (c *GRPCCache) versioned(req *ItemMessage) (uint64, error) {
+	resp, err := c.do(req)
+	if err != nil {
+		return 0, err
+	}
+	return resp.GetVersion(), nil
+}
+
+//Delete remove item, false mean item is missing or server is unavailable
+func (c *GRPCCache) Delete(name string) bool {
+	ctx, cancel := c.context()
+	defer cancel()
+	_, err := c.client.Delete(ctx, &ItemMessage{Name: name})
+	return err == nil
+}
+
+//Keys return names of all items on server, nil if server is unavailable
+func (c *GRPCCache) Keys() []string {
+	resp, err := c.do(&ItemMessage{Command: ItemMessage_KEYS})
+	if err != nil {
+		return nil
+	}
+	return resp.GetKeys()
+}
+
+//Purge remove all items on server
+func (c *GRPCCache) Purge() {
+	ctx, cancel := c.context()
+	defer cancel()
+	c.client.Purge(ctx, &ItemMessage{})
+}
+
+//Dead close connection, server is not stopped
+func (c *GRPCCache) Dead() {
+	c.conn.Close()
+}
+
+//Statistic return statistic of server cache, zero Stats if server is unavailable
+func (c *GRPCCache) Statistic() Stats {
+	ctx, cancel := c.context()
+	defer cancel()
+	resp, err := c.client.Stats(ctx, &ItemMessage{})
+	if err != nil {
+		return Stats{}
+	}
+	return statsFromMessage(resp)
+}
+
+//BatchGet return items by names via one stream, missing items are absent in result
+func (c *GRPCCache) BatchGet(names []string) (map[string]*Item, error) {
+	ctx, cancel := c.context()
+	defer cancel()
+	stream, err := c.client.BatchGet(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sendErr := make(chan error, 1)
+	go func() {
+		for _, name := range names {
+			if err := stream.Send(&ItemMessage{Name: name}); err != nil {
+				sendErr <- err
+				return
+			}
+		}
+		sendErr <- stream.CloseSend()
+	}()
+	items := make(map[string]*Item, len(names))
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if resp.GetStatus() == ItemMessage_OK {
+			items[resp.GetName()] = messageItem(resp)
+		}
+	}
+	if err = <-sendErr; err != nil && err != io.EOF {
+		return nil, err
+	}
+	return items, nil
+}
+
+//TokenCredentials send token to server with every request, use it with grpc.WithPerRPCCredentials
+type TokenCredentials string
+
+//GetRequestMetadata implements credentials.PerRPCCredentials
+func (t TokenCredentials) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{"authorization": bearerPrefix + string(t)}, nil
+}
+
+//RequireTransportSecurity implements credentials.PerRPCCredentials, token can be sent without tls
+func (t TokenCredentials) RequireTransportSecurity() bool {
+	return false
+}
+
+//Watch stream changes of items with prefix until ctx is done
+func (c *GRPCCache) Watch(ctx context.Context, prefix string) (CacheService_WatchClient, error) {
+	return c.client.Watch(ctx, &ItemMessage{Name: prefix})
+}
@@ -0,0 +1,69 @@
+package gcache
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGRPCCache_CAS(t *testing.T) {
+	inner := NewRwCache(&ConfigMessage{})
+	c, ln := startGRPC(t, inner, nil)
+	defer ln.Close()
+	checkVersionedCacher(t, c)
+	c.Dead()
+	inner.Dead() //Cleanup
+}
+
+func TestGRPCCache_SetOrUpdate(t *testing.T) {
+	as := assert.New(t)
+	inner := NewRwCache(defaultConfig())
+	c, ln := startGRPC(t, inner, nil)
+	defer ln.Close()
+
+	c.SetOrUpdate("first", []byte(`zaza`), DefaultExpirationMarker)
+	as.Equal([]byte(`zaza`), c.Get("first"))
+	as.Equal([]byte(`zaza`), inner.Get("first"))
+	as.Nil(c.Get("irst"))
+
+	value, err := c.Incr("counter", 2, DefaultExpirationMarker)
+	as.NoError(err)
+	as.Equal(int64(2), value)
+	value, err = c.Decr("counter", 3, DefaultExpirationMarker)
+	as.NoError(err)
+	as.Equal(int64(-1), value)
+	_, err = c.Incr("first", 2, DefaultExpirationMarker)
+	as.Equal(ErrNotInteger, err)
+
+	as.Equal(int64(2), c.Statistic().ItemsCount)
+	c.Purge()
+	as.Equal(int64(0), c.Statistic().ItemsCount)
+
+	c.Dead()
+	as.Nil(c.Get("first"), "closed client")
+	inner.Dead() //Cleanup
+}
+
+func TestGRPCCache_BatchGet(t *testing.T) {
+	as := assert.New(t)
+	inner := NewRwCache(&ConfigMessage{})
+	c, ln := startGRPC(t, inner, nil)
+	defer ln.Close()
+
+	c.SetOrUpdate("first", []byte(`zaza`), DefaultExpirationMarker)
+	c.SetOrUpdate("second", []byte(`azaz`), DefaultExpirationMarker)
+	items, err := c.BatchGet([]string{"first", "missing", "second"})
+	if as.NoError(err) {
+		as.Len(items, 2)
+		as.Equal([]byte(`zaza`), items["first"].Object)
+		as.Equal([]byte(`azaz`), items["second"].Object)
+		as.NotZero(items["second"].Version)
+	}
+
+	items, err = c.BatchGet(nil)
+	as.NoError(err)
+	as.Empty(items)
+
+	c.Dead()
+	inner.Dead() //Cleanup
+}
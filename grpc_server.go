@@ -0,0 +1,194 @@
+package gcache
+
+import (
+	"context"
+	"io"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+//GRPCServer implements CacheServiceServer for any Cacher
+//Requests are the same ItemMessage as in tcp modes, failed status is returned as grpc error
+type GRPCServer struct {
+	Security *Security //token is read from "authorization: Bearer <token>" metadata, see TokenCredentials
+	cache    *WatchCache
+}
+
+//NewGRPCServer wrap cache to serve it via grpc, cache is wrapped with WatchCache if it is not one
+func NewGRPCServer(cache Cacher) *GRPCServer {
+	w, ok := cache.(*WatchCache)
+	if !ok {
+		w = NewWatchCache(cache)
+	}
+	return &GRPCServer{cache: w}
+}
+
+//handleGRPC serve CacheService on listener
+func handleGRPC(ln net.Listener, cache Cacher, sec *Security, opts ...grpc.ServerOption) error {
+	return newGRPCServer(cache, sec, opts...).Serve(ln)
+}
+
+//newGRPCServer create grpc server with registered CacheService
+func newGRPCServer(cache Cacher, sec *Security, opts ...grpc.ServerOption) *grpc.Server {
+	s := grpc.NewServer(opts...)
+	srv := NewGRPCServer(cache)
+	srv.Security = sec
+	RegisterCacheServiceServer(s, srv)
+	return s
+}
+
+//session return session of request authenticated by client certificate or by token in metadata
+func (s *GRPCServer) session(ctx context.Context) (*session, error) {
+	if s.Security.acl() == nil {
+		return nil, nil
+	}
+	identity, authorization := "", ""
+	if p, ok := peer.FromContext(ctx); ok && s.Security.TLS != nil {
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			identity = s.Security.TLS.Identity(&info.State)
+		}
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("authorization"); len(values) > 0 {
+			authorization = values[0]
+		}
+	}
+	sess, err := s.Security.authorizedSession(identity, authorization)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	return sess, nil
+}
+
+//grpcCodes map wire status to grpc code
+var grpcCodes = map[ItemMessage_Statuses]codes.Code{
+	ItemMessage_NOT_FOUND:    codes.NotFound,
+	ItemMessage_EXISTS:       codes.AlreadyExists,
+	ItemMessage_INVALID:      codes.InvalidArgument,
+	ItemMessage_TOO_LARGE:    codes.ResourceExhausted,
+	ItemMessage_UNAUTHORIZED: codes.Unauthenticated,
+	ItemMessage_SERVER_ERROR: codes.Internal,
+}
+
+//grpcError return grpc error for failed responce
+func grpcError(resp *ItemMessage) error {
+	if resp.GetStatus() == ItemMessage_OK {
+		return nil
+	}
+	code, ok := grpcCodes[resp.GetStatus()]
+	if !ok {
+		code = codes.Unknown
+	}
+	if resp.GetError() == ErrForbidden.Error() {
+		code = codes.PermissionDenied
+	}
+	return status.Error(code, resp.GetError())
+}
+
+func (s *GRPCServer) call(ctx context.Context, t *ItemMessage, cmd ItemMessage_Commands) (*ItemMessage, error) {
+	sess, err := s.session(ctx)
+	if err != nil {
+		return nil, err
+	}
+	t.Command = cmd
+	resp, _ := sess.handleMessage(t, s.cache)
+	if err := grpcError(resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+//Get return item, NotFound if it is missing
+func (s *GRPCServer) Get(ctx context.Context, t *ItemMessage) (*ItemMessage, error) {
+	return s.call(ctx, t, ItemMessage_GET)
+}
+
+//Set store item
+func (s *GRPCServer) Set(ctx context.Context, t *ItemMessage) (*ItemMessage, error) {
+	return s.call(ctx, t, ItemMessage_SET)
+}
+
+//Delete remove item, NotFound if it is missing
+func (s *GRPCServer) Delete(ctx context.Context, t *ItemMessage) (*ItemMessage, error) {
+	return s.call(ctx, t, ItemMessage_DELETE)
+}
+
+//Purge remove all items
+func (s *GRPCServer) Purge(ctx context.Context, t *ItemMessage) (*ItemMessage, error) {
+	return s.call(ctx, t, ItemMessage_PURGE)
+}
+
+//Stats return statistic of cache
+func (s *GRPCServer) Stats(ctx context.Context, t *ItemMessage) (*StatsMessage, error) {
+	resp, err := s.call(ctx, t, ItemMessage_STATS)
+	if err != nil {
+		return nil, err
+	}
+	return resp.GetStats(), nil
+}
+
+//Do apply any command except DEAD, cache could not be stopped by grpc client
+func (s *GRPCServer) Do(ctx context.Context, t *ItemMessage) (*ItemMessage, error) {
+	if t.Command == ItemMessage_DEAD {
+		return nil, status.Error(codes.PermissionDenied, "Dead command is not allowed")
+	}
+	return s.call(ctx, t, t.Command)
+}
+
+//BatchGet answer every requested name in order of requests, missing item has NOT_FOUND status
+func (s *GRPCServer) BatchGet(stream CacheService_BatchGetServer) error {
+	sess, err := s.session(stream.Context())
+	if err != nil {
+		return err
+	}
+	for {
+		t, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		resp, _ := sess.handleMessage(&ItemMessage{
+			Command:    ItemMessage_GET,
+			Name:       t.GetName(),
+			Compressed: t.GetCompressed(),
+			RequestID:  t.GetRequestID(),
+		}, s.cache)
+		if err = stream.Send(resp); err != nil {
+			return err
+		}
+	}
+}
+
+//Watch stream changes of items with prefix from Name of request
+func (s *GRPCServer) Watch(t *ItemMessage, stream CacheService_WatchServer) error {
+	sess, err := s.session(stream.Context())
+	if err != nil {
+		return err
+	}
+	if err = sess.allow(ItemMessage_GET, t.GetName()); err != nil {
+		return grpcError(failedMessage(t, err))
+	}
+	events, cancel := s.cache.Watch(t.GetName(), DefaultWatchBuffer)
+	defer cancel()
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case ev, ok := <-events:
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "Watcher is too slow")
+			}
+			if err := stream.Send(ev); err != nil {
+				return err
+			}
+		}
+	}
+}
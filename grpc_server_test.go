@@ -0,0 +1,149 @@
+package gcache
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+//startGRPC run grpc server on random local port and return connected client
+func startGRPC(t *testing.T, cache Cacher, sec *Security, opts ...grpc.DialOption) (*GRPCCache, net.Listener) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go handleGRPC(ln, cache, sec)
+	opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := grpc.Dial(ln.Addr().String(), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewGRPCCache(conn, 5*time.Second), ln
+}
+
+func TestGRPCServer_Errors(t *testing.T) {
+	as := assert.New(t)
+	inner := NewRwCache(&ConfigMessage{})
+	c, ln := startGRPC(t, inner, nil)
+	defer ln.Close()
+	ctx := context.Background()
+
+	_, err := c.client.Get(ctx, &ItemMessage{Name: "first"})
+	as.Equal(codes.NotFound, status.Code(err))
+	_, err = c.client.Delete(ctx, &ItemMessage{Name: "first"})
+	as.Equal(codes.NotFound, status.Code(err))
+
+	_, err = c.client.Set(ctx, &ItemMessage{Name: "first", Object: []byte(`zaza`)})
+	as.NoError(err)
+	_, err = c.client.Do(ctx, &ItemMessage{Command: ItemMessage_ADD, Name: "first"})
+	as.Equal(codes.AlreadyExists, status.Code(err))
+	_, err = c.client.Do(ctx, &ItemMessage{Command: ItemMessage_INCR, Name: "first", Delta: 1})
+	as.Equal(codes.InvalidArgument, status.Code(err))
+	_, err = c.client.Do(ctx, &ItemMessage{Command: ItemMessage_Commands(100)})
+	as.Equal(codes.InvalidArgument, status.Code(err))
+
+	_, err = c.client.Do(ctx, &ItemMessage{Command: ItemMessage_DEAD})
+	as.Equal(codes.PermissionDenied, status.Code(err))
+	as.Equal([]byte(`zaza`), inner.Get("first"), "cache is alive")
+
+	c.Dead()
+	inner.Dead() //Cleanup
+}
+
+func TestGRPCServer_Watch(t *testing.T) {
+	as := assert.New(t)
+	inner := NewRwCache(&ConfigMessage{})
+	c, ln := startGRPC(t, inner, nil)
+	defer ln.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := c.Watch(ctx, "user:")
+	if !as.NoError(err) {
+		return
+	}
+	//watcher is registered asynchronously, repeat write until it is seen
+	var ev *ItemMessage
+	received := make(chan *ItemMessage)
+	go func() {
+		for {
+			ev, err := stream.Recv()
+			if err != nil {
+				close(received)
+				return
+			}
+			received <- ev
+		}
+	}()
+	for ev == nil {
+		c.SetOrUpdate("user:1", []byte(`zaza`), DefaultExpirationMarker)
+		select {
+		case ev = <-received:
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	as.Equal(ItemMessage_SET, ev.Command)
+	as.Equal("user:1", ev.Name)
+	as.Equal([]byte(`zaza`), ev.Object)
+
+	c.SetOrUpdate("order:1", []byte(`zaza`), DefaultExpirationMarker)
+	c.Delete("user:1")
+	for ev = range received {
+		if ev.Command == ItemMessage_DELETE {
+			break
+		}
+		as.Equal("user:1", ev.Name, "only watched prefix")
+	}
+	as.Equal("user:1", ev.Name)
+
+	c.Dead()
+	inner.Dead() //Cleanup
+}
+
+func TestGRPCServer_Auth(t *testing.T) {
+	as := assert.New(t)
+	inner := NewRwCache(&ConfigMessage{})
+	sec := &Security{ACL: testACL()}
+	guest, ln := startGRPC(t, inner, sec)
+	defer ln.Close()
+	app, appLn := startGRPC(t, inner, sec, grpc.WithPerRPCCredentials(TokenCredentials("app-secret")))
+	defer appLn.Close()
+	wrong, wrongLn := startGRPC(t, inner, sec, grpc.WithPerRPCCredentials(TokenCredentials("wrong")))
+	defer wrongLn.Close()
+	ctx := context.Background()
+
+	_, err := guest.client.Set(ctx, &ItemMessage{Name: "app:1", Object: []byte(`zaza`)})
+	as.Equal(codes.PermissionDenied, status.Code(err))
+	_, err = wrong.client.Get(ctx, &ItemMessage{Name: "public:1"})
+	as.Equal(codes.Unauthenticated, status.Code(err))
+
+	_, err = app.Add("app:1", []byte(`zaza`), NoExpiration)
+	as.NoError(err)
+	_, err = app.Add("other:1", []byte(`zaza`), NoExpiration)
+	as.Equal(ErrForbidden, err)
+	as.NotZero(app.Statistic().ItemsCount)
+	_, err = app.client.Purge(ctx, &ItemMessage{})
+	as.Equal(codes.PermissionDenied, status.Code(err))
+
+	items, err := app.BatchGet([]string{"app:1", "other:1"})
+	as.NoError(err)
+	as.Len(items, 1, "forbidden item has own status")
+
+	stream, err := guest.Watch(ctx, "app:")
+	if as.NoError(err) {
+		_, err = stream.Recv()
+		as.Equal(codes.PermissionDenied, status.Code(err))
+	}
+
+	guest.Dead()
+	app.Dead()
+	wrong.Dead()
+	inner.Dead() //Cleanup
+}
@@ -8,12 +8,31 @@ import (
 //HashCalculator interface about hash funcs
 type HashCalculator func(string) uint64
 
+//hashFuncs are names of hash functions for ConfigMessage.HashFunc
+var hashFuncs = map[string]HashCalculator{
+	"":      calcFNVInline,
+	"fnv":   calcFNVInline,
+	"crc":   calcHashCRC,
+	"djb33": djb33,
+	"sum":   calcSUM,
+}
+
 func calcHashFNV(str string) uint64 {
 	hash := fnv.New64a()
 	hash.Write([]byte(str))
 	return hash.Sum64()
 }
 
+//calcFNVInline is FNV-1a without allocations
+func calcFNVInline(str string) uint64 {
+	var hash uint64 = 14695981039346656037
+	for i := 0; i < len(str); i++ {
+		hash ^= uint64(str[i])
+		hash *= 1099511628211
+	}
+	return hash
+}
+
 func calcHashCRC(str string) uint64 {
 	crc64Table := crc64.MakeTable(0xC96C5795D7870F42)
 	hash := crc64.New(crc64Table)
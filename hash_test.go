@@ -16,6 +16,7 @@ func benchmarkHash(n int, b *testing.B) {
 		fn   HashCalculator
 	}{
 		{"calcHashFNV", calcHashFNV},
+		{"calcFNVInline", calcFNVInline},
 		{"calcHashCRC", calcHashCRC},
 		{"dj33", djb33},
 		{"calcSUM", calcSUM},
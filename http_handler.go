@@ -0,0 +1,188 @@
+package gcache
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	//HeaderTTL is a request header with expiration of item, query parameter "ttl" can be used too
+	HeaderTTL = "X-Gcache-Ttl"
+	//HeaderVersion is a response header with version of item
+	HeaderVersion = "X-Gcache-Version"
+	//HeaderExpiration is a response header with absolute expiration of item in unix nanoseconds, it is missing for item without expiration
+	HeaderExpiration = "X-Gcache-Expiration"
+
+	keysListPath     = "/keys"
+	keysPath         = "/keys/"
+	purgePath        = "/purge"
+	statsPath        = "/stats"
+	maxHTTPValueSize = 64 << 20 //64Mb
+)
+
+var errTTL = errors.New("Wrong ttl, expected duration like 10s or number of seconds")
+
+//HTTPHandler is a REST API over any Cacher:
+// GET, HEAD, PUT, DELETE /keys/{name}, GET /keys, POST /purge and GET /stats.
+//It can be mounted inside other service with http.StripPrefix
+type HTTPHandler struct {
+	Security *Security //token is read from "Authorization: Bearer <token>" header
+	cache    Cacher
+}
+
+//NewHTTPHandler create REST handler for cache
+func NewHTTPHandler(cache Cacher) *HTTPHandler {
+	return &HTTPHandler{cache: cache}
+}
+
+//ServeHTTP route request to cache
+func (h *HTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	sess, err := h.Security.requestSession(r)
+	if err != nil {
+		unauthorized(w)
+		return
+	}
+	switch {
+	case r.URL.Path == keysListPath:
+		if !allowMethod(w, r, http.MethodGet) || !authorized(w, sess, ItemMessage_KEYS, "") {
+			return
+		}
+		keys := h.cache.Keys()
+		if keys == nil {
+			keys = []string{}
+		}
+		sort.Strings(keys)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(keys)
+	case strings.HasPrefix(r.URL.Path, keysPath):
+		name := strings.TrimPrefix(r.URL.Path, keysPath)
+		if name == "" {
+			http.NotFound(w, r)
+			return
+		}
+		h.serveKey(w, r, name, sess)
+	case r.URL.Path == purgePath:
+		if !allowMethod(w, r, http.MethodPost) || !authorized(w, sess, ItemMessage_PURGE, "") {
+			return
+		}
+		h.cache.Purge()
+		w.WriteHeader(http.StatusNoContent)
+	case r.URL.Path == statsPath:
+		if !allowMethod(w, r, http.MethodGet) || !authorized(w, sess, ItemMessage_STATS, "") {
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(h.cache.Statistic())
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *HTTPHandler) serveKey(w http.ResponseWriter, r *http.Request, name string, sess *session) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		if !authorized(w, sess, ItemMessage_GET, name) {
+			return
+		}
+		itm := h.cache.GetItem(name)
+		if itm == nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Length", strconv.Itoa(len(itm.Object)))
+		w.Header().Set(HeaderVersion, strconv.FormatUint(itm.Version, 10))
+		if itm.Expiration != 0 {
+			w.Header().Set(HeaderExpiration, strconv.FormatInt(itm.Expiration, 10))
+		}
+		if r.Method == http.MethodGet {
+			w.Write(itm.Object)
+		}
+	case http.MethodPut:
+		if !authorized(w, sess, ItemMessage_SET, name) {
+			return
+		}
+		exp, err := requestTTL(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		value, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxHTTPValueSize))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		h.cache.SetOrUpdate(name, value, exp)
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		if !authorized(w, sess, ItemMessage_DELETE, name) {
+			return
+		}
+		if !h.cache.Delete(name) {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT, DELETE")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+//authorized write 401 or 403 responce if session could not run command with item name
+func authorized(w http.ResponseWriter, sess *session, cmd ItemMessage_Commands, name string) bool {
+	switch sess.allow(cmd, name) {
+	case nil:
+		return true
+	case ErrUnauthorized:
+		unauthorized(w)
+	default:
+		http.Error(w, ErrForbidden.Error(), http.StatusForbidden)
+	}
+	return false
+}
+
+func unauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	http.Error(w, ErrUnauthorized.Error(), http.StatusUnauthorized)
+}
+
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	return false
+}
+
+//requestTTL read expiration from header or query, negative value mean no expiration
+func requestTTL(r *http.Request) (time.Duration, error) {
+	ttl := r.Header.Get(HeaderTTL)
+	if ttl == "" {
+		ttl = r.URL.Query().Get("ttl")
+	}
+	if ttl == "" {
+		return DefaultExpirationMarker, nil
+	}
+	if seconds, err := strconv.ParseInt(ttl, 10, 64); err == nil {
+		if seconds < 0 {
+			return NoExpiration, nil
+		}
+		return time.Duration(seconds) * time.Second, nil
+	}
+	exp, err := time.ParseDuration(ttl)
+	if err != nil {
+		return 0, errTTL
+	}
+	if exp < 0 {
+		return NoExpiration, nil
+	}
+	return exp, nil
+}
@@ -0,0 +1,131 @@
+package gcache
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func doHTTP(h http.Handler, method, target, body string, headers ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	for i := 0; i+1 < len(headers); i += 2 {
+		r.Header.Set(headers[i], headers[i+1])
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestHTTPHandler_Keys(t *testing.T) {
+	as := assert.New(t)
+	c := NewRwCache(defaultConfig())
+	h := NewHTTPHandler(c)
+
+	as.Equal(http.StatusNotFound, doHTTP(h, http.MethodGet, "/keys/first", "").Code)
+	as.Equal(http.StatusNotFound, doHTTP(h, http.MethodHead, "/keys/first", "").Code)
+
+	as.Equal(http.StatusNoContent, doHTTP(h, http.MethodPut, "/keys/first", "zaza").Code)
+	w := doHTTP(h, http.MethodGet, "/keys/first", "")
+	as.Equal(http.StatusOK, w.Code)
+	as.Equal("zaza", w.Body.String())
+	as.Equal("1", w.Header().Get(HeaderVersion))
+
+	w = doHTTP(h, http.MethodHead, "/keys/first", "")
+	as.Equal(http.StatusOK, w.Code)
+	as.Equal("4", w.Header().Get("Content-Length"))
+	as.Empty(w.Body.String())
+
+	as.Equal(http.StatusNoContent, doHTTP(h, http.MethodDelete, "/keys/first", "").Code)
+	as.Equal(http.StatusNotFound, doHTTP(h, http.MethodDelete, "/keys/first", "").Code)
+	as.Nil(c.Get("first"))
+
+	w = doHTTP(h, http.MethodPost, "/keys/first", "")
+	as.Equal(http.StatusMethodNotAllowed, w.Code)
+	as.Equal("GET, HEAD, PUT, DELETE", w.Header().Get("Allow"))
+	as.Equal(http.StatusNotFound, doHTTP(h, http.MethodGet, "/keys/", "").Code)
+
+	c.Dead() //Cleanup
+}
+
+func TestHTTPHandler_TTL(t *testing.T) {
+	as := assert.New(t)
+	c := NewRwCache(&ConfigMessage{})
+	h := NewHTTPHandler(c)
+
+	as.Equal(http.StatusNoContent, doHTTP(h, http.MethodPut, "/keys/header", "zaza", HeaderTTL, "1ms").Code)
+	as.Equal(http.StatusNoContent, doHTTP(h, http.MethodPut, "/keys/query?ttl=3600", "zaza").Code)
+	as.Equal(http.StatusNoContent, doHTTP(h, http.MethodPut, "/keys/forever?ttl=-1", "zaza").Code)
+	as.Equal(http.StatusBadRequest, doHTTP(h, http.MethodPut, "/keys/wrong?ttl=zaza", "zaza").Code)
+
+	now := time.Now().UnixNano()
+	as.InDelta(now+int64(time.Millisecond), c.GetItem("header").Expiration, float64(time.Second))
+	as.InDelta(now+int64(time.Hour), c.GetItem("query").Expiration, float64(time.Second))
+	as.Zero(c.GetItem("forever").Expiration)
+	as.Nil(c.GetItem("wrong"))
+	as.Equal(strconv.FormatInt(c.GetItem("query").Expiration, 10), doHTTP(h, http.MethodGet, "/keys/query", "").Header().Get(HeaderExpiration))
+	as.Empty(doHTTP(h, http.MethodGet, "/keys/forever", "").Header().Get(HeaderExpiration))
+
+	c.Dead() //Cleanup
+}
+
+func TestHTTPHandler_PurgeStats(t *testing.T) {
+	as := assert.New(t)
+	c := NewRwCache(defaultConfig())
+	mux := http.NewServeMux()
+	mux.Handle("/cache/", http.StripPrefix("/cache", NewHTTPHandler(c)))
+
+	doHTTP(mux, http.MethodPut, "/cache/keys/first", "zaza")
+	doHTTP(mux, http.MethodPut, "/cache/keys/second", "azaz")
+
+	w := doHTTP(mux, http.MethodGet, "/cache/stats", "")
+	as.Equal(http.StatusOK, w.Code)
+	as.Equal("application/json", w.Header().Get("Content-Type"))
+	var s Stats
+	as.NoError(json.Unmarshal(w.Body.Bytes(), &s))
+	as.Equal(int64(2), s.ItemsCount)
+	as.Equal(int64(2), s.SetOrReplaceCount)
+
+	w = doHTTP(mux, http.MethodGet, "/cache/keys", "")
+	as.Equal(http.StatusOK, w.Code)
+	as.JSONEq(`["first","second"]`, w.Body.String())
+
+	as.Equal(http.StatusMethodNotAllowed, doHTTP(mux, http.MethodGet, "/cache/purge", "").Code)
+	as.Equal(http.StatusNoContent, doHTTP(mux, http.MethodPost, "/cache/purge", "").Code)
+	as.Equal(int64(0), c.Statistic().ItemsCount)
+	as.JSONEq(`[]`, doHTTP(mux, http.MethodGet, "/cache/keys", "").Body.String())
+
+	c.Dead() //Cleanup
+}
+
+func TestHTTPHandler_Auth(t *testing.T) {
+	as := assert.New(t)
+	c := NewRwCache(&ConfigMessage{})
+	h := NewHTTPHandler(c)
+	h.Security = &Security{ACL: testACL()}
+	app := []string{"Authorization", "Bearer app-secret"}
+
+	as.Equal(http.StatusForbidden, doHTTP(h, http.MethodPut, "/keys/app:1", "zaza").Code, "guest is read only")
+	as.Equal(http.StatusNoContent, doHTTP(h, http.MethodPut, "/keys/app:1", "zaza", app...).Code)
+	as.Equal(http.StatusForbidden, doHTTP(h, http.MethodPut, "/keys/other", "zaza", app...).Code)
+	as.Equal(http.StatusOK, doHTTP(h, http.MethodGet, "/keys/app:1", "", app...).Code)
+	as.Equal(http.StatusForbidden, doHTTP(h, http.MethodPost, "/purge", "", app...).Code)
+	as.Equal(http.StatusForbidden, doHTTP(h, http.MethodGet, "/keys", "", app...).Code)
+
+	w := doHTTP(h, http.MethodGet, "/keys/app:1", "", "Authorization", "Bearer wrong")
+	as.Equal(http.StatusUnauthorized, w.Code)
+	as.Equal("Bearer", w.Header().Get("WWW-Authenticate"))
+	as.Equal(http.StatusUnauthorized, doHTTP(h, http.MethodGet, "/stats", "", "Authorization", "Basic YTpi").Code)
+
+	h.Security.ACL.Anonymous = ""
+	as.Equal(http.StatusUnauthorized, doHTTP(h, http.MethodGet, "/stats", "").Code, "anonymous access is disabled")
+	as.Equal(http.StatusNoContent, doHTTP(h, http.MethodPost, "/purge", "", "Authorization", "Bearer admin-secret").Code)
+	as.Nil(c.Get("app:1"))
+
+	c.Dead() //Cleanup
+}
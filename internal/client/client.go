@@ -0,0 +1,432 @@
+//Package client implement requests of gcachectl and gcache-bench for http, tcp_long, tcp_short, udp and unix modes
+package client
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/Asuan/gcache"
+	"github.com/golang/protobuf/proto"
+)
+
+const (
+	ModeHTTP     = "http"
+	ModeTCPLong  = "tcp_long"
+	ModeTCPShort = "tcp_short"
+	ModeUDP      = "udp"
+	ModeUnix     = "unix"
+
+	maxDatagramSize   = 65507 //the biggest udp payload of ipv4
+	defaultUDPAttempt = time.Second
+)
+
+//ErrEmptyResponce is returned when server close connection without responce
+var ErrEmptyResponce = errors.New("Empty responce")
+
+//Client is a connection to server of one mode, missing item is reported as gcache.ErrNotFound.
+//Clients of http, tcp_short and udp can be shared by goroutines, tcp_long and unix clients have one connection
+// and should be used by one goroutine
+type Client interface {
+	Get(name string) (*gcache.Item, error)
+	Set(name string, value []byte, ttl time.Duration) error
+	Delete(name string) error
+	Purge() error
+	Stats() (gcache.Stats, error)
+	Keys() ([]string, error)
+	Close()
+}
+
+//Options is a settings of Client
+type Options struct {
+	Mode    string
+	Address string //host:port, socket path of unix mode or @name in abstract namespace
+	Token   string //sent with every request
+	Timeout time.Duration
+	TLS     *tls.Config
+	//Retries is a number of udp resends when responce is lost, Timeout is split between attempts
+	Retries int
+	//Fallback is an address of tcp_long server for udp requests and responces which do not fit datagram,
+	// gcache.ErrTruncated is returned for them if it is empty
+	Fallback string
+}
+
+//New create client of mode, connections are dialed by requests
+func New(opts Options) (Client, error) {
+	switch opts.Mode {
+	case ModeHTTP:
+		return newHTTPClient(opts), nil
+	case ModeUDP:
+		if opts.TLS != nil {
+			return nil, errors.New("Tls is not supported by udp")
+		}
+		return &messageClient{opts: opts, requestID: rand.Uint64()}, nil
+	case ModeTCPLong, ModeTCPShort, ModeUnix:
+		return &messageClient{opts: opts}, nil
+	}
+	return nil, errors.New("Unsupported mode: " + opts.Mode)
+}
+
+//httpClient use REST API of http mode, see gcache.HTTPHandler
+type httpClient struct {
+	base   string
+	token  string
+	client *http.Client
+}
+
+func newHTTPClient(opts Options) *httpClient {
+	scheme := "http://"
+	if opts.TLS != nil {
+		scheme = "https://"
+	}
+	return &httpClient{
+		base:  scheme + opts.Address,
+		token: opts.Token,
+		client: &http.Client{
+			Timeout:   opts.Timeout,
+			Transport: &http.Transport{TLSClientConfig: opts.TLS},
+		},
+	}
+}
+
+//do send request and return body of successful responce, error statuses are mapped to gcache errors
+func (c *httpClient) do(method, path string, body []byte, header http.Header) (*http.Response, []byte, error) {
+	req, err := http.NewRequest(method, c.base+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch {
+	case resp.StatusCode < 300:
+		return resp, data, nil
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, nil, gcache.ErrNotFound
+	case resp.StatusCode == http.StatusUnauthorized:
+		return nil, nil, gcache.ErrUnauthorized
+	case resp.StatusCode == http.StatusForbidden:
+		return nil, nil, gcache.ErrForbidden
+	}
+	return nil, nil, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(data)))
+}
+
+func (c *httpClient) Get(name string) (*gcache.Item, error) {
+	resp, data, err := c.do(http.MethodGet, "/keys/"+url.PathEscape(name), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	itm := &gcache.Item{Object: data}
+	itm.Version, _ = strconv.ParseUint(resp.Header.Get(gcache.HeaderVersion), 10, 64)
+	if exp := resp.Header.Get(gcache.HeaderExpiration); exp != "" {
+		if itm.Expiration, err = strconv.ParseInt(exp, 10, 64); err != nil {
+			return nil, err
+		}
+	}
+	return itm, nil
+}
+
+func (c *httpClient) Set(name string, value []byte, ttl time.Duration) error {
+	header := http.Header{}
+	switch {
+	case ttl < 0:
+		header.Set(gcache.HeaderTTL, "-1")
+	case ttl > 0:
+		header.Set(gcache.HeaderTTL, ttl.String())
+	}
+	_, _, err := c.do(http.MethodPut, "/keys/"+url.PathEscape(name), value, header)
+	return err
+}
+
+func (c *httpClient) Delete(name string) error {
+	_, _, err := c.do(http.MethodDelete, "/keys/"+url.PathEscape(name), nil, nil)
+	return err
+}
+
+func (c *httpClient) Purge() error {
+	_, _, err := c.do(http.MethodPost, "/purge", nil, nil)
+	return err
+}
+
+func (c *httpClient) Stats() (gcache.Stats, error) {
+	var stats gcache.Stats
+	_, data, err := c.do(http.MethodGet, "/stats", nil, nil)
+	if err != nil {
+		return stats, err
+	}
+	return stats, json.Unmarshal(data, &stats)
+}
+
+func (c *httpClient) Keys() ([]string, error) {
+	var keys []string
+	_, data, err := c.do(http.MethodGet, "/keys", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return keys, json.Unmarshal(data, &keys)
+}
+
+func (c *httpClient) Close() {
+	c.client.CloseIdleConnections()
+}
+
+//messageClient send ItemMessage via tcp_long, tcp_short, udp or unix, token is sent with every request
+type messageClient struct {
+	opts      Options
+	requestID uint64   //udp requests have random ids, so server recognize retries but not requests of other process
+	conn      net.Conn //open connection of tcp_long
+	reader    *gcache.FrameReader
+}
+
+func (c *messageClient) dial(network string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: c.opts.Timeout}
+	if c.opts.TLS != nil {
+		return tls.DialWithDialer(dialer, network, c.opts.Address, c.opts.TLS)
+	}
+	return dialer.Dial(network, c.opts.Address)
+}
+
+func (c *messageClient) deadline() time.Time {
+	if c.opts.Timeout <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(c.opts.Timeout)
+}
+
+//roundTrip send request and return responce, failed status is returned as error
+func (c *messageClient) roundTrip(req *gcache.ItemMessage) (*gcache.ItemMessage, error) {
+	req.Token = c.opts.Token
+	if c.opts.Mode == ModeUDP {
+		req.RequestID = atomic.AddUint64(&c.requestID, 1)
+	}
+	request, err := proto.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	switch c.opts.Mode {
+	case ModeTCPLong, ModeUnix:
+		data, err = c.exchangeLong(request)
+	case ModeTCPShort:
+		data, err = c.exchangeShort(request)
+	default:
+		data, err = c.exchangeUDP(request, req.RequestID)
+		if err == gcache.ErrTruncated && c.opts.Fallback != "" {
+			data, err = c.exchangeFallback(request)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, ErrEmptyResponce
+	}
+	resp := &gcache.ItemMessage{}
+	if err = proto.Unmarshal(data, resp); err != nil {
+		return nil, err
+	}
+	return resp, responceError(resp)
+}
+
+//exchangeLong write frame via open connection and read responce frame
+func (c *messageClient) exchangeLong(data []byte) ([]byte, error) {
+	if c.conn == nil {
+		network := "tcp"
+		if c.opts.Mode == ModeUnix {
+			network = "unix"
+		}
+		conn, err := c.dial(network)
+		if err != nil {
+			return nil, err
+		}
+		c.conn = conn
+		c.reader = gcache.NewFrameReader(conn, gcache.DefaultMaxFrameSize)
+	}
+	c.conn.SetDeadline(c.deadline())
+	if err := gcache.WriteFrame(c.conn, data); err != nil {
+		return nil, err
+	}
+	return c.reader.ReadFrame()
+}
+
+//exchangeShort write message to new connection, close write and read responce until EOF
+func (c *messageClient) exchangeShort(data []byte) ([]byte, error) {
+	conn, err := c.dial("tcp")
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(c.deadline())
+	if _, err = conn.Write(data); err != nil {
+		return nil, err
+	}
+	if cw, ok := conn.(interface{ CloseWrite() error }); ok {
+		if err = cw.CloseWrite(); err != nil {
+			return nil, err
+		}
+	}
+	return ioutil.ReadAll(io.LimitReader(conn, gcache.DefaultMaxFrameSize))
+}
+
+//exchangeUDP send datagram and wait responce with the same id, datagram is sent again if responce is not received in time.
+//Responces of previous attempts are skipped, gcache.ErrTruncated is returned if request or responce does not fit datagram
+func (c *messageClient) exchangeUDP(data []byte, id uint64) ([]byte, error) {
+	if len(data) > maxDatagramSize {
+		return nil, gcache.ErrTruncated
+	}
+	conn, err := c.dial("udp")
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	attempt := c.opts.Timeout / time.Duration(c.opts.Retries+1)
+	if attempt <= 0 {
+		attempt = defaultUDPAttempt
+	}
+	buf := make([]byte, maxDatagramSize)
+	for i := 0; i <= c.opts.Retries; i++ {
+		if _, err = conn.Write(data); err != nil {
+			return nil, err
+		}
+		conn.SetReadDeadline(time.Now().Add(attempt))
+		for {
+			n, err := conn.Read(buf)
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				break //lost request or responce
+			}
+			if err != nil {
+				return nil, err
+			}
+			resp := &gcache.ItemMessage{}
+			if proto.Unmarshal(buf[:n], resp) != nil || resp.GetRequestID() != id {
+				continue //duplicate of previous responce or garbage
+			}
+			if resp.GetStatus() == gcache.ItemMessage_TRUNCATED {
+				return nil, gcache.ErrTruncated
+			}
+			return buf[:n], nil
+		}
+	}
+	return nil, gcache.ErrRemoteTimeout
+}
+
+//exchangeFallback send request via new connection to tcp_long server of Fallback address
+func (c *messageClient) exchangeFallback(data []byte) ([]byte, error) {
+	conn, err := net.DialTimeout("tcp", c.opts.Fallback, c.opts.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(c.deadline())
+	if err = gcache.WriteFrame(conn, data); err != nil {
+		return nil, err
+	}
+	return gcache.NewFrameReader(conn, gcache.DefaultMaxFrameSize).ReadFrame()
+}
+
+//responceError return error of failed request
+func responceError(resp *gcache.ItemMessage) error {
+	switch resp.GetStatus() {
+	case gcache.ItemMessage_OK:
+		return nil
+	case gcache.ItemMessage_NOT_FOUND:
+		return gcache.ErrNotFound
+	case gcache.ItemMessage_TRUNCATED:
+		return gcache.ErrTruncated
+	case gcache.ItemMessage_UNAUTHORIZED:
+		if resp.GetError() == gcache.ErrForbidden.Error() {
+			return gcache.ErrForbidden
+		}
+		return gcache.ErrUnauthorized
+	}
+	return &gcache.RemoteError{Status: resp.GetStatus(), Message: resp.GetError()}
+}
+
+func (c *messageClient) Get(name string) (*gcache.Item, error) {
+	resp, err := c.roundTrip(&gcache.ItemMessage{Command: gcache.ItemMessage_GET, Name: name})
+	if err != nil {
+		return nil, err
+	}
+	object := resp.GetObject()
+	if object == nil {
+		object = []byte{}
+	}
+	return &gcache.Item{Object: object, Expiration: resp.GetExpiration(), Version: resp.GetVersion()}, nil
+}
+
+func (c *messageClient) Set(name string, value []byte, ttl time.Duration) error {
+	_, err := c.roundTrip(&gcache.ItemMessage{
+		Command:    gcache.ItemMessage_SET,
+		Name:       name,
+		Object:     value,
+		Expiration: int64(ttl),
+	})
+	return err
+}
+
+func (c *messageClient) Delete(name string) error {
+	_, err := c.roundTrip(&gcache.ItemMessage{Command: gcache.ItemMessage_DELETE, Name: name})
+	return err
+}
+
+func (c *messageClient) Purge() error {
+	_, err := c.roundTrip(&gcache.ItemMessage{Command: gcache.ItemMessage_PURGE})
+	return err
+}
+
+func (c *messageClient) Stats() (gcache.Stats, error) {
+	resp, err := c.roundTrip(&gcache.ItemMessage{Command: gcache.ItemMessage_STATS})
+	if err != nil {
+		return gcache.Stats{}, err
+	}
+	m := resp.GetStats()
+	return gcache.Stats{
+		ItemsCount:        m.GetItemsCount(),
+		GetSuccessNumber:  m.GetGetSuccessNumber(),
+		GetErrorNumber:    m.GetGetErrorNumber(),
+		SetOrReplaceCount: m.GetSetOrReplaceCount(),
+		DeleteCount:       m.GetDeleteCount(),
+		DeleteExpired:     m.GetDeleteExpired(),
+		SizeLimit:         m.GetSizeLimit(),
+		RawBytes:          m.GetRawBytes(),
+		CompressedBytes:   m.GetCompressedBytes(),
+	}, nil
+}
+
+func (c *messageClient) Keys() ([]string, error) {
+	resp, err := c.roundTrip(&gcache.ItemMessage{Command: gcache.ItemMessage_KEYS})
+	if err != nil {
+		return nil, err
+	}
+	return resp.GetKeys(), nil
+}
+
+func (c *messageClient) Close() {
+	if c.conn != nil {
+		c.conn.Close()
+	}
+}
@@ -0,0 +1,124 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Asuan/gcache"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+//startServer run server of modes on random local ports
+func startServer(t *testing.T, modes ...string) (*gcache.Server, context.CancelFunc) {
+	opts := gcache.ServerOptions{}
+	for _, mode := range modes {
+		opts.Endpoints = append(opts.Endpoints, gcache.Endpoint{Mode: mode, Address: "127.0.0.1:0"})
+	}
+	s, err := gcache.NewServer(gcache.NewRwCache(&gcache.ConfigMessage{}), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go s.Serve(ctx)
+	for _, mode := range modes {
+		for s.Addr(mode) == nil {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	return s, cancel
+}
+
+//lossyProxy forward datagrams to server, it lose the first responce and send stale datagram
+// and duplicates with others
+func lossyProxy(t *testing.T, server string) net.PacketConn {
+	ln, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	upstream, err := net.Dial("udp", server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale, _ := proto.Marshal(&gcache.ItemMessage{RequestID: 1, Status: gcache.ItemMessage_NOT_FOUND})
+	go func() {
+		defer upstream.Close()
+		buf := make([]byte, maxDatagramSize)
+		resp := make([]byte, maxDatagramSize)
+		for i := 0; ; i++ {
+			n, addr, err := ln.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			upstream.Write(buf[:n])
+			upstream.SetReadDeadline(time.Now().Add(time.Second))
+			m, err := upstream.Read(resp)
+			if err != nil || i == 0 {
+				continue
+			}
+			ln.WriteTo(stale, addr)
+			ln.WriteTo(resp[:m], addr)
+			ln.WriteTo(resp[:m], addr)
+		}
+	}()
+	return ln
+}
+
+func TestClient_UDPLoss(t *testing.T) {
+	as := assert.New(t)
+	s, cancel := startServer(t, ModeUDP)
+	defer cancel()
+	s.Cache().SetOrUpdate("first", []byte(`zaza`), gcache.NoExpiration)
+	proxy := lossyProxy(t, s.Addr(ModeUDP).String())
+	defer proxy.Close()
+
+	c, err := New(Options{Mode: ModeUDP, Address: proxy.LocalAddr().String(), Timeout: 600 * time.Millisecond, Retries: 2})
+	if !as.NoError(err) {
+		return
+	}
+	defer c.Close()
+	as.NoError(c.Delete("first"), "request is resent and server repeat responce instead of applying it again")
+	as.Nil(s.Cache().Get("first"))
+	_, err = c.Get("first")
+	as.Equal(gcache.ErrNotFound, err)
+	as.NoError(c.Set("second", []byte(`azaz`), gcache.NoExpiration))
+	itm, err := c.Get("second")
+	if as.NoError(err, "stale datagram and duplicate are skipped") {
+		as.Equal([]byte(`azaz`), itm.Object)
+	}
+
+	c, _ = New(Options{Mode: ModeUDP, Address: proxy.LocalAddr().String(), Timeout: 100 * time.Millisecond})
+	proxy.Close()
+	_, err = c.Get("second")
+	as.Error(err, "no responce")
+}
+
+func TestClient_UDPFallback(t *testing.T) {
+	as := assert.New(t)
+	s, cancel := startServer(t, ModeUDP, ModeTCPLong)
+	defer cancel()
+	big := bytes.Repeat([]byte(`z`), 2*maxDatagramSize)
+	s.Cache().SetOrUpdate("big", big, gcache.NoExpiration)
+
+	c, err := New(Options{Mode: ModeUDP, Address: s.Addr(ModeUDP).String(), Timeout: time.Second})
+	if !as.NoError(err) {
+		return
+	}
+	_, err = c.Get("big")
+	as.Equal(gcache.ErrTruncated, err, "responce does not fit datagram")
+	as.Equal(gcache.ErrTruncated, c.Set("other", big, gcache.NoExpiration), "request does not fit datagram")
+
+	c, _ = New(Options{Mode: ModeUDP, Address: s.Addr(ModeUDP).String(), Timeout: time.Second, Fallback: s.Addr(ModeTCPLong).String()})
+	itm, err := c.Get("big")
+	if as.NoError(err) {
+		as.Equal(big, itm.Object)
+	}
+	as.NoError(c.Set("other", big, gcache.NoExpiration))
+	as.Equal(big, s.Cache().Get("other"))
+	keys, err := c.Keys()
+	as.NoError(err)
+	as.Len(keys, 2)
+}
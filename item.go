@@ -1,5 +1,11 @@
 package gcache
 
+import (
+	"math"
+	"strconv"
+	"time"
+)
+
 //expired returns true if the item has expired.
 func (item Item) expired(now int64) bool {
 	if item.Expiration == 0 {
@@ -7,3 +13,110 @@ func (item Item) expired(now int64) bool {
 	}
 	return now > item.Expiration
 }
+
+//expireAt convert expiration duration to absolute time in UnixNano, 0 mean no expiration
+func expireAt(exp time.Duration, defaultExpiration int64) int64 {
+	switch {
+	case exp == DefaultExpirationMarker:
+		return time.Now().UnixNano() + defaultExpiration
+	case exp < 0:
+		return 0
+	}
+	return time.Now().UnixNano() + int64(exp)
+}
+
+//incrValue add delta to decimal value, nil value is treated as zero
+func incrValue(value []byte, delta int64) ([]byte, int64, error) {
+	var current int64
+	if value != nil {
+		var err error
+		if current, err = strconv.ParseInt(string(value), 10, 64); err != nil {
+			return nil, 0, ErrNotInteger
+		}
+	}
+	if (delta > 0 && current > math.MaxInt64-delta) || (delta < 0 && current < math.MinInt64-delta) {
+		return nil, 0, ErrOverflow
+	}
+	current += delta
+	return strconv.AppendInt(nil, current, 10), current, nil
+}
+
+//negate return delta of Incr for Decr, math.MinInt64 could not be negated
+func negate(delta int64) (int64, error) {
+	if delta == math.MinInt64 {
+		return 0, ErrOverflow
+	}
+	return -delta, nil
+}
+
+//checkStore return error if write by cmd is not allowed for current live item, nil itm is a missing item
+func checkStore(cmd ItemMessage_Commands, itm *Item, version uint64) error {
+	switch cmd {
+	case ItemMessage_SET:
+		return nil
+	case ItemMessage_ADD:
+		if itm != nil {
+			return ErrExists
+		}
+		return nil
+	case ItemMessage_REPLACE:
+		if itm == nil {
+			return ErrNotFound
+		}
+		return nil
+	case ItemMessage_CAS:
+		if itm == nil {
+			return ErrNotFound
+		}
+		if itm.Version != version {
+			return ErrVersionMismatch
+		}
+		return nil
+	}
+	return ErrNotSupported
+}
+
+//storeFlags write item by SET, ADD, REPLACE or CAS cmd, flags are dropped if cache is not FlagsCacher
+func storeFlags(cache Cacher, cmd ItemMessage_Commands, name string, value []byte, flags uint32, version uint64, exp time.Duration) (uint64, error) {
+	if f, ok := cache.(FlagsCacher); ok {
+		return f.StoreFlags(cmd, name, value, flags, version, exp)
+	}
+	return storeValue(cache, cmd, name, value, version, exp)
+}
+
+//storeValue write item by SET, ADD, REPLACE or CAS cmd with methods of Cacher, version of SET is zero
+func storeValue(cache Cacher, cmd ItemMessage_Commands, name string, value []byte, version uint64, exp time.Duration) (uint64, error) {
+	switch cmd {
+	case ItemMessage_SET:
+		cache.SetOrUpdate(name, value, exp)
+		return 0, nil
+	case ItemMessage_ADD:
+		return cache.Add(name, value, exp)
+	case ItemMessage_REPLACE:
+		return cache.Replace(name, value, exp)
+	case ItemMessage_CAS:
+		return cache.CAS(name, value, version, exp)
+	}
+	return 0, ErrNotSupported
+}
+
+//touchItem change expiration of live item with CAS, false mean item is missing
+func touchItem(cache Cacher, name string, exp time.Duration) (bool, error) {
+	for {
+		itm := cache.GetItem(name)
+		if itm == nil {
+			return false, nil
+		}
+		_, err := storeFlags(cache, ItemMessage_CAS, name, itm.Object, itm.Flags, itm.Version, exp)
+		switch err {
+		case nil:
+			return true, nil
+		case ErrVersionMismatch:
+			continue //concurrent update, try again
+		case ErrNotFound:
+			return false, nil
+		default:
+			return false, err
+		}
+	}
+}
@@ -9,6 +9,7 @@ It is generated from these files:
 
 It has these top-level messages:
 	ItemMessage
+	StatsMessage
 	ConfigMessage
 */
 package gcache
@@ -17,6 +18,11 @@ import proto "github.com/golang/protobuf/proto"
 import fmt "fmt"
 import math "math"
 
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+)
+
 // Reference imports to suppress errors if they are not otherwise used.
 var _ = proto.Marshal
 var _ = fmt.Errorf
@@ -31,23 +37,53 @@ const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
 type ItemMessage_Commands int32
 
 const (
-	ItemMessage_SET   ItemMessage_Commands = 0
-	ItemMessage_GET   ItemMessage_Commands = 1
-	ItemMessage_PURGE ItemMessage_Commands = 2
-	ItemMessage_DEAD  ItemMessage_Commands = 3
+	ItemMessage_SET     ItemMessage_Commands = 0
+	ItemMessage_GET     ItemMessage_Commands = 1
+	ItemMessage_PURGE   ItemMessage_Commands = 2
+	ItemMessage_DEAD    ItemMessage_Commands = 3
+	ItemMessage_INCR    ItemMessage_Commands = 4
+	ItemMessage_DECR    ItemMessage_Commands = 5
+	ItemMessage_ADD     ItemMessage_Commands = 6
+	ItemMessage_REPLACE ItemMessage_Commands = 7
+	ItemMessage_CAS     ItemMessage_Commands = 8
+	ItemMessage_DELETE  ItemMessage_Commands = 9
+	ItemMessage_STATS   ItemMessage_Commands = 10
+	ItemMessage_KEYS    ItemMessage_Commands = 11
+	ItemMessage_AUTH    ItemMessage_Commands = 12
+	ItemMessage_PING    ItemMessage_Commands = 13
 )
 
 var ItemMessage_Commands_name = map[int32]string{
-	0: "SET",
-	1: "GET",
-	2: "PURGE",
-	3: "DEAD",
+	0:  "SET",
+	1:  "GET",
+	2:  "PURGE",
+	3:  "DEAD",
+	4:  "INCR",
+	5:  "DECR",
+	6:  "ADD",
+	7:  "REPLACE",
+	8:  "CAS",
+	9:  "DELETE",
+	10: "STATS",
+	11: "KEYS",
+	12: "AUTH",
+	13: "PING",
 }
 var ItemMessage_Commands_value = map[string]int32{
-	"SET":   0,
-	"GET":   1,
-	"PURGE": 2,
-	"DEAD":  3,
+	"SET":     0,
+	"GET":     1,
+	"PURGE":   2,
+	"DEAD":    3,
+	"INCR":    4,
+	"DECR":    5,
+	"ADD":     6,
+	"REPLACE": 7,
+	"CAS":     8,
+	"DELETE":  9,
+	"STATS":   10,
+	"KEYS":    11,
+	"AUTH":    12,
+	"PING":    13,
 }
 
 func (x ItemMessage_Commands) String() string {
@@ -55,6 +91,45 @@ func (x ItemMessage_Commands) String() string {
 }
 func (ItemMessage_Commands) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{0, 0} }
 
+type ItemMessage_Statuses int32
+
+const (
+	ItemMessage_OK           ItemMessage_Statuses = 0
+	ItemMessage_NOT_FOUND    ItemMessage_Statuses = 1
+	ItemMessage_EXISTS       ItemMessage_Statuses = 2
+	ItemMessage_INVALID      ItemMessage_Statuses = 3
+	ItemMessage_TOO_LARGE    ItemMessage_Statuses = 4
+	ItemMessage_UNAUTHORIZED ItemMessage_Statuses = 5
+	ItemMessage_SERVER_ERROR ItemMessage_Statuses = 6
+	ItemMessage_TRUNCATED    ItemMessage_Statuses = 7
+)
+
+var ItemMessage_Statuses_name = map[int32]string{
+	0: "OK",
+	1: "NOT_FOUND",
+	2: "EXISTS",
+	3: "INVALID",
+	4: "TOO_LARGE",
+	5: "UNAUTHORIZED",
+	6: "SERVER_ERROR",
+	7: "TRUNCATED",
+}
+var ItemMessage_Statuses_value = map[string]int32{
+	"OK":           0,
+	"NOT_FOUND":    1,
+	"EXISTS":       2,
+	"INVALID":      3,
+	"TOO_LARGE":    4,
+	"UNAUTHORIZED": 5,
+	"SERVER_ERROR": 6,
+	"TRUNCATED":    7,
+}
+
+func (x ItemMessage_Statuses) String() string {
+	return proto.EnumName(ItemMessage_Statuses_name, int32(x))
+}
+func (ItemMessage_Statuses) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{0, 1} }
+
 type ConfigMessage_CacheTypes int32
 
 const (
@@ -62,6 +137,7 @@ const (
 	ConfigMessage_LOCKONLY       ConfigMessage_CacheTypes = 1
 	ConfigMessage_SINGLEGORUTINE ConfigMessage_CacheTypes = 2
 	ConfigMessage_REMOTE         ConfigMessage_CacheTypes = 3
+	ConfigMessage_OFFHEAP        ConfigMessage_CacheTypes = 4
 )
 
 var ConfigMessage_CacheTypes_name = map[int32]string{
@@ -69,24 +145,35 @@ var ConfigMessage_CacheTypes_name = map[int32]string{
 	1: "LOCKONLY",
 	2: "SINGLEGORUTINE",
 	3: "REMOTE",
+	4: "OFFHEAP",
 }
 var ConfigMessage_CacheTypes_value = map[string]int32{
 	"RWL":            0,
 	"LOCKONLY":       1,
 	"SINGLEGORUTINE": 2,
 	"REMOTE":         3,
+	"OFFHEAP":        4,
 }
 
 func (x ConfigMessage_CacheTypes) String() string {
 	return proto.EnumName(ConfigMessage_CacheTypes_name, int32(x))
 }
-func (ConfigMessage_CacheTypes) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{1, 0} }
+func (ConfigMessage_CacheTypes) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{2, 0} }
 
 type ItemMessage struct {
 	Command    ItemMessage_Commands `protobuf:"varint,1,opt,name=Command,json=command,enum=gcache.ItemMessage_Commands" json:"Command,omitempty"`
 	Name       string               `protobuf:"bytes,2,opt,name=Name,json=name" json:"Name,omitempty"`
 	Expiration int64                `protobuf:"varint,3,opt,name=Expiration,json=expiration" json:"Expiration,omitempty"`
 	Object     []byte               `protobuf:"bytes,4,opt,name=Object,json=object,proto3" json:"Object,omitempty"`
+	Compressed bool                 `protobuf:"varint,5,opt,name=Compressed,json=compressed" json:"Compressed,omitempty"`
+	Delta      int64                `protobuf:"zigzag64,6,opt,name=Delta,json=delta" json:"Delta,omitempty"`
+	Version    uint64               `protobuf:"varint,7,opt,name=Version,json=version" json:"Version,omitempty"`
+	Stats      *StatsMessage        `protobuf:"bytes,8,opt,name=Stats,json=stats" json:"Stats,omitempty"`
+	RequestID  uint64               `protobuf:"varint,9,opt,name=RequestID,json=requestID" json:"RequestID,omitempty"`
+	Status     ItemMessage_Statuses `protobuf:"varint,10,opt,name=Status,json=status,enum=gcache.ItemMessage_Statuses" json:"Status,omitempty"`
+	Error      string               `protobuf:"bytes,11,opt,name=Error,json=error" json:"Error,omitempty"`
+	Keys       []string             `protobuf:"bytes,12,rep,name=Keys,json=keys" json:"Keys,omitempty"`
+	Token      string               `protobuf:"bytes,13,opt,name=Token,json=token" json:"Token,omitempty"`
 }
 
 func (m *ItemMessage) Reset()                    { *m = ItemMessage{} }
@@ -122,18 +209,163 @@ func (m *ItemMessage) GetObject() []byte {
 	return nil
 }
 
+func (m *ItemMessage) GetCompressed() bool {
+	if m != nil {
+		return m.Compressed
+	}
+	return false
+}
+
+func (m *ItemMessage) GetDelta() int64 {
+	if m != nil {
+		return m.Delta
+	}
+	return 0
+}
+
+func (m *ItemMessage) GetVersion() uint64 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+func (m *ItemMessage) GetStats() *StatsMessage {
+	if m != nil {
+		return m.Stats
+	}
+	return nil
+}
+
+func (m *ItemMessage) GetRequestID() uint64 {
+	if m != nil {
+		return m.RequestID
+	}
+	return 0
+}
+
+func (m *ItemMessage) GetStatus() ItemMessage_Statuses {
+	if m != nil {
+		return m.Status
+	}
+	return ItemMessage_OK
+}
+
+func (m *ItemMessage) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+func (m *ItemMessage) GetKeys() []string {
+	if m != nil {
+		return m.Keys
+	}
+	return nil
+}
+
+func (m *ItemMessage) GetToken() string {
+	if m != nil {
+		return m.Token
+	}
+	return ""
+}
+
+type StatsMessage struct {
+	ItemsCount        int64 `protobuf:"zigzag64,1,opt,name=ItemsCount,json=itemsCount" json:"ItemsCount,omitempty"`
+	GetSuccessNumber  int64 `protobuf:"zigzag64,2,opt,name=GetSuccessNumber,json=getSuccessNumber" json:"GetSuccessNumber,omitempty"`
+	GetErrorNumber    int64 `protobuf:"zigzag64,3,opt,name=GetErrorNumber,json=getErrorNumber" json:"GetErrorNumber,omitempty"`
+	SetOrReplaceCount int64 `protobuf:"zigzag64,4,opt,name=SetOrReplaceCount,json=setOrReplaceCount" json:"SetOrReplaceCount,omitempty"`
+	DeleteCount       int64 `protobuf:"zigzag64,5,opt,name=DeleteCount,json=deleteCount" json:"DeleteCount,omitempty"`
+	DeleteExpired     int64 `protobuf:"zigzag64,6,opt,name=DeleteExpired,json=deleteExpired" json:"DeleteExpired,omitempty"`
+	SizeLimit         int64 `protobuf:"zigzag64,7,opt,name=SizeLimit,json=sizeLimit" json:"SizeLimit,omitempty"`
+	RawBytes          int64 `protobuf:"zigzag64,8,opt,name=RawBytes,json=rawBytes" json:"RawBytes,omitempty"`
+	CompressedBytes   int64 `protobuf:"zigzag64,9,opt,name=CompressedBytes,json=compressedBytes" json:"CompressedBytes,omitempty"`
+}
+
+func (m *StatsMessage) Reset()                    { *m = StatsMessage{} }
+func (m *StatsMessage) String() string            { return proto.CompactTextString(m) }
+func (*StatsMessage) ProtoMessage()               {}
+func (*StatsMessage) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+
+func (m *StatsMessage) GetItemsCount() int64 {
+	if m != nil {
+		return m.ItemsCount
+	}
+	return 0
+}
+
+func (m *StatsMessage) GetGetSuccessNumber() int64 {
+	if m != nil {
+		return m.GetSuccessNumber
+	}
+	return 0
+}
+
+func (m *StatsMessage) GetGetErrorNumber() int64 {
+	if m != nil {
+		return m.GetErrorNumber
+	}
+	return 0
+}
+
+func (m *StatsMessage) GetSetOrReplaceCount() int64 {
+	if m != nil {
+		return m.SetOrReplaceCount
+	}
+	return 0
+}
+
+func (m *StatsMessage) GetDeleteCount() int64 {
+	if m != nil {
+		return m.DeleteCount
+	}
+	return 0
+}
+
+func (m *StatsMessage) GetDeleteExpired() int64 {
+	if m != nil {
+		return m.DeleteExpired
+	}
+	return 0
+}
+
+func (m *StatsMessage) GetSizeLimit() int64 {
+	if m != nil {
+		return m.SizeLimit
+	}
+	return 0
+}
+
+func (m *StatsMessage) GetRawBytes() int64 {
+	if m != nil {
+		return m.RawBytes
+	}
+	return 0
+}
+
+func (m *StatsMessage) GetCompressedBytes() int64 {
+	if m != nil {
+		return m.CompressedBytes
+	}
+	return 0
+}
+
 type ConfigMessage struct {
 	DefaultExpiration int64                    `protobuf:"varint,1,opt,name=DefaultExpiration,json=defaultExpiration" json:"DefaultExpiration,omitempty"`
 	SizeLimit         int64                    `protobuf:"zigzag64,2,opt,name=SizeLimit,json=sizeLimit" json:"SizeLimit,omitempty"`
 	ShardCount        int64                    `protobuf:"zigzag64,3,opt,name=ShardCount,json=shardCount" json:"ShardCount,omitempty"`
 	IsKeepUsefull     bool                     `protobuf:"varint,4,opt,name=IsKeepUsefull,json=isKeepUsefull" json:"IsKeepUsefull,omitempty"`
 	CacheType         ConfigMessage_CacheTypes `protobuf:"varint,5,opt,name=CacheType,json=cacheType,enum=gcache.ConfigMessage_CacheTypes" json:"CacheType,omitempty"`
+	MemoryLimit       int64                    `protobuf:"zigzag64,6,opt,name=MemoryLimit,json=memoryLimit" json:"MemoryLimit,omitempty"`
+	HashFunc          string                   `protobuf:"bytes,7,opt,name=HashFunc,json=hashFunc" json:"HashFunc,omitempty"`
 }
 
 func (m *ConfigMessage) Reset()                    { *m = ConfigMessage{} }
 func (m *ConfigMessage) String() string            { return proto.CompactTextString(m) }
 func (*ConfigMessage) ProtoMessage()               {}
-func (*ConfigMessage) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+func (*ConfigMessage) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
 
 func (m *ConfigMessage) GetDefaultExpiration() int64 {
 	if m != nil {
@@ -170,39 +402,453 @@ func (m *ConfigMessage) GetCacheType() ConfigMessage_CacheTypes {
 	return ConfigMessage_RWL
 }
 
+func (m *ConfigMessage) GetMemoryLimit() int64 {
+	if m != nil {
+		return m.MemoryLimit
+	}
+	return 0
+}
+
+func (m *ConfigMessage) GetHashFunc() string {
+	if m != nil {
+		return m.HashFunc
+	}
+	return ""
+}
+
 func init() {
 	proto.RegisterType((*ItemMessage)(nil), "gcache.ItemMessage")
+	proto.RegisterType((*StatsMessage)(nil), "gcache.StatsMessage")
 	proto.RegisterType((*ConfigMessage)(nil), "gcache.ConfigMessage")
 	proto.RegisterEnum("gcache.ItemMessage_Commands", ItemMessage_Commands_name, ItemMessage_Commands_value)
+	proto.RegisterEnum("gcache.ItemMessage_Statuses", ItemMessage_Statuses_name, ItemMessage_Statuses_value)
 	proto.RegisterEnum("gcache.ConfigMessage_CacheTypes", ConfigMessage_CacheTypes_name, ConfigMessage_CacheTypes_value)
 }
 
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// Client API for CacheService service
+
+type CacheServiceClient interface {
+	Get(ctx context.Context, in *ItemMessage, opts ...grpc.CallOption) (*ItemMessage, error)
+	Set(ctx context.Context, in *ItemMessage, opts ...grpc.CallOption) (*ItemMessage, error)
+	Delete(ctx context.Context, in *ItemMessage, opts ...grpc.CallOption) (*ItemMessage, error)
+	Purge(ctx context.Context, in *ItemMessage, opts ...grpc.CallOption) (*ItemMessage, error)
+	Stats(ctx context.Context, in *ItemMessage, opts ...grpc.CallOption) (*StatsMessage, error)
+	Do(ctx context.Context, in *ItemMessage, opts ...grpc.CallOption) (*ItemMessage, error)
+	BatchGet(ctx context.Context, opts ...grpc.CallOption) (CacheService_BatchGetClient, error)
+	Watch(ctx context.Context, in *ItemMessage, opts ...grpc.CallOption) (CacheService_WatchClient, error)
+}
+
+type cacheServiceClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewCacheServiceClient(cc *grpc.ClientConn) CacheServiceClient {
+	return &cacheServiceClient{cc}
+}
+
+func (c *cacheServiceClient) Get(ctx context.Context, in *ItemMessage, opts ...grpc.CallOption) (*ItemMessage, error) {
+	out := new(ItemMessage)
+	err := grpc.Invoke(ctx, "/gcache.CacheService/Get", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cacheServiceClient) Set(ctx context.Context, in *ItemMessage, opts ...grpc.CallOption) (*ItemMessage, error) {
+	out := new(ItemMessage)
+	err := grpc.Invoke(ctx, "/gcache.CacheService/Set", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cacheServiceClient) Delete(ctx context.Context, in *ItemMessage, opts ...grpc.CallOption) (*ItemMessage, error) {
+	out := new(ItemMessage)
+	err := grpc.Invoke(ctx, "/gcache.CacheService/Delete", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cacheServiceClient) Purge(ctx context.Context, in *ItemMessage, opts ...grpc.CallOption) (*ItemMessage, error) {
+	out := new(ItemMessage)
+	err := grpc.Invoke(ctx, "/gcache.CacheService/Purge", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cacheServiceClient) Stats(ctx context.Context, in *ItemMessage, opts ...grpc.CallOption) (*StatsMessage, error) {
+	out := new(StatsMessage)
+	err := grpc.Invoke(ctx, "/gcache.CacheService/Stats", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cacheServiceClient) Do(ctx context.Context, in *ItemMessage, opts ...grpc.CallOption) (*ItemMessage, error) {
+	out := new(ItemMessage)
+	err := grpc.Invoke(ctx, "/gcache.CacheService/Do", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cacheServiceClient) BatchGet(ctx context.Context, opts ...grpc.CallOption) (CacheService_BatchGetClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_CacheService_serviceDesc.Streams[0], c.cc, "/gcache.CacheService/BatchGet", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &cacheServiceBatchGetClient{stream}
+	return x, nil
+}
+
+type CacheService_BatchGetClient interface {
+	Send(*ItemMessage) error
+	Recv() (*ItemMessage, error)
+	grpc.ClientStream
+}
+
+type cacheServiceBatchGetClient struct {
+	grpc.ClientStream
+}
+
+func (x *cacheServiceBatchGetClient) Send(m *ItemMessage) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *cacheServiceBatchGetClient) Recv() (*ItemMessage, error) {
+	m := new(ItemMessage)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *cacheServiceClient) Watch(ctx context.Context, in *ItemMessage, opts ...grpc.CallOption) (CacheService_WatchClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_CacheService_serviceDesc.Streams[1], c.cc, "/gcache.CacheService/Watch", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &cacheServiceWatchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type CacheService_WatchClient interface {
+	Recv() (*ItemMessage, error)
+	grpc.ClientStream
+}
+
+type cacheServiceWatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *cacheServiceWatchClient) Recv() (*ItemMessage, error) {
+	m := new(ItemMessage)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Server API for CacheService service
+
+type CacheServiceServer interface {
+	Get(context.Context, *ItemMessage) (*ItemMessage, error)
+	Set(context.Context, *ItemMessage) (*ItemMessage, error)
+	Delete(context.Context, *ItemMessage) (*ItemMessage, error)
+	Purge(context.Context, *ItemMessage) (*ItemMessage, error)
+	Stats(context.Context, *ItemMessage) (*StatsMessage, error)
+	Do(context.Context, *ItemMessage) (*ItemMessage, error)
+	BatchGet(CacheService_BatchGetServer) error
+	Watch(*ItemMessage, CacheService_WatchServer) error
+}
+
+func RegisterCacheServiceServer(s *grpc.Server, srv CacheServiceServer) {
+	s.RegisterService(&_CacheService_serviceDesc, srv)
+}
+
+func _CacheService_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ItemMessage)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CacheServiceServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/gcache.CacheService/Get",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CacheServiceServer).Get(ctx, req.(*ItemMessage))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CacheService_Set_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ItemMessage)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CacheServiceServer).Set(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/gcache.CacheService/Set",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CacheServiceServer).Set(ctx, req.(*ItemMessage))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CacheService_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ItemMessage)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CacheServiceServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/gcache.CacheService/Delete",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CacheServiceServer).Delete(ctx, req.(*ItemMessage))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CacheService_Purge_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ItemMessage)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CacheServiceServer).Purge(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/gcache.CacheService/Purge",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CacheServiceServer).Purge(ctx, req.(*ItemMessage))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CacheService_Stats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ItemMessage)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CacheServiceServer).Stats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/gcache.CacheService/Stats",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CacheServiceServer).Stats(ctx, req.(*ItemMessage))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CacheService_Do_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ItemMessage)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CacheServiceServer).Do(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/gcache.CacheService/Do",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CacheServiceServer).Do(ctx, req.(*ItemMessage))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CacheService_BatchGet_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(CacheServiceServer).BatchGet(&cacheServiceBatchGetServer{stream})
+}
+
+type CacheService_BatchGetServer interface {
+	Send(*ItemMessage) error
+	Recv() (*ItemMessage, error)
+	grpc.ServerStream
+}
+
+type cacheServiceBatchGetServer struct {
+	grpc.ServerStream
+}
+
+func (x *cacheServiceBatchGetServer) Send(m *ItemMessage) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *cacheServiceBatchGetServer) Recv() (*ItemMessage, error) {
+	m := new(ItemMessage)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _CacheService_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ItemMessage)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CacheServiceServer).Watch(m, &cacheServiceWatchServer{stream})
+}
+
+type CacheService_WatchServer interface {
+	Send(*ItemMessage) error
+	grpc.ServerStream
+}
+
+type cacheServiceWatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *cacheServiceWatchServer) Send(m *ItemMessage) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+var _CacheService_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "gcache.CacheService",
+	HandlerType: (*CacheServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Get",
+			Handler:    _CacheService_Get_Handler,
+		},
+		{
+			MethodName: "Set",
+			Handler:    _CacheService_Set_Handler,
+		},
+		{
+			MethodName: "Delete",
+			Handler:    _CacheService_Delete_Handler,
+		},
+		{
+			MethodName: "Purge",
+			Handler:    _CacheService_Purge_Handler,
+		},
+		{
+			MethodName: "Stats",
+			Handler:    _CacheService_Stats_Handler,
+		},
+		{
+			MethodName: "Do",
+			Handler:    _CacheService_Do_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "BatchGet",
+			Handler:       _CacheService_BatchGet_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "Watch",
+			Handler:       _CacheService_Watch_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "item.proto",
+}
+
 func init() { proto.RegisterFile("item.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 371 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x5c, 0x91, 0xdf, 0x8a, 0x9b, 0x40,
-	0x18, 0xc5, 0x77, 0xa2, 0x6b, 0xf4, 0xeb, 0x66, 0x71, 0xbf, 0x8b, 0xe2, 0xc5, 0x52, 0x44, 0x7a,
-	0xe1, 0x45, 0x11, 0xda, 0x42, 0x2f, 0x0b, 0xc5, 0x0c, 0x22, 0xeb, 0x6a, 0x19, 0x0d, 0xa5, 0x97,
-	0xb3, 0x66, 0x92, 0x4c, 0x89, 0x7f, 0xc8, 0x4c, 0xa0, 0xed, 0x03, 0xf5, 0x49, 0xfa, 0x60, 0x45,
-	0x53, 0xd3, 0x74, 0xef, 0x8e, 0xe7, 0x3b, 0xe0, 0x39, 0xbf, 0x01, 0x90, 0x5a, 0x34, 0x51, 0x7f,
-	0xe8, 0x74, 0x87, 0xd6, 0xb6, 0xe6, 0xf5, 0x4e, 0x04, 0xbf, 0x09, 0xbc, 0x48, 0xb5, 0x68, 0x1e,
-	0x85, 0x52, 0x7c, 0x2b, 0xf0, 0x03, 0xcc, 0xe3, 0xae, 0x69, 0x78, 0xbb, 0xf6, 0x88, 0x4f, 0xc2,
-	0xdb, 0x77, 0xf7, 0xd1, 0x29, 0x19, 0x5d, 0xa4, 0xa2, 0xbf, 0x11, 0xc5, 0xe6, 0xf5, 0x49, 0x21,
-	0x82, 0x99, 0xf3, 0x46, 0x78, 0x33, 0x9f, 0x84, 0x0e, 0x33, 0x5b, 0xde, 0x08, 0x7c, 0x05, 0x40,
-	0xbf, 0xf7, 0xf2, 0xc0, 0xb5, 0xec, 0x5a, 0xcf, 0xf0, 0x49, 0x68, 0x30, 0x10, 0x67, 0x07, 0x5f,
-	0x82, 0x55, 0x3c, 0x7d, 0x13, 0xb5, 0xf6, 0x4c, 0x9f, 0x84, 0x37, 0xcc, 0xea, 0xc6, 0xaf, 0xe0,
-	0x2d, 0xd8, 0xd3, 0x0f, 0x70, 0x0e, 0x46, 0x49, 0x2b, 0xf7, 0x6a, 0x10, 0x09, 0xad, 0x5c, 0x82,
-	0x0e, 0x5c, 0x7f, 0x5e, 0xb1, 0x84, 0xba, 0x33, 0xb4, 0xc1, 0x5c, 0xd2, 0x4f, 0x4b, 0xd7, 0x08,
-	0x7e, 0xcd, 0x60, 0x11, 0x77, 0xed, 0x46, 0x6e, 0xa7, 0x21, 0x6f, 0xe0, 0x6e, 0x29, 0x36, 0xfc,
-	0xb8, 0xd7, 0x17, 0x1d, 0xc8, 0xd8, 0xe1, 0x6e, 0xfd, 0xfc, 0x80, 0xf7, 0xe0, 0x94, 0xf2, 0xa7,
-	0xc8, 0x64, 0x23, 0xf5, 0xb8, 0x01, 0x99, 0xa3, 0x26, 0x63, 0x18, 0x52, 0xee, 0xf8, 0x61, 0x1d,
-	0x77, 0xc7, 0x56, 0x8f, 0x43, 0x90, 0x81, 0x3a, 0x3b, 0xf8, 0x1a, 0x16, 0xa9, 0x7a, 0x10, 0xa2,
-	0x5f, 0x29, 0xb1, 0x39, 0xee, 0xf7, 0xe3, 0x1e, 0x9b, 0x2d, 0xe4, 0xa5, 0x89, 0x1f, 0xc1, 0x89,
-	0x07, 0x92, 0xd5, 0x8f, 0x5e, 0x78, 0xd7, 0x23, 0x5c, 0x7f, 0x82, 0xfb, 0x5f, 0xf7, 0xe8, 0x1c,
-	0x53, 0xcc, 0xa9, 0x27, 0x1d, 0xc4, 0x00, 0xff, 0x0e, 0x03, 0x0f, 0xf6, 0x25, 0x73, 0xaf, 0xf0,
-	0x06, 0xec, 0xac, 0x88, 0x1f, 0x8a, 0x3c, 0xfb, 0xea, 0x12, 0x44, 0xb8, 0x2d, 0xd3, 0x3c, 0xc9,
-	0x68, 0x52, 0xb0, 0x55, 0x95, 0xe6, 0x03, 0x26, 0x00, 0x8b, 0xd1, 0xc7, 0xa2, 0xa2, 0xae, 0xf1,
-	0x64, 0x8d, 0xcf, 0xff, 0xfe, 0x4f, 0x00, 0x00, 0x00, 0xff, 0xff, 0x1a, 0xad, 0xdc, 0xb5, 0x0c,
-	0x02, 0x00, 0x00,
+	// 939 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0x94, 0x55, 0x6d, 0x6e, 0xdb, 0x46,
+	0x10, 0x0d, 0x25, 0x91, 0x22, 0x47, 0x92, 0xb3, 0xde, 0x06, 0x05, 0x11, 0x04, 0x01, 0x21, 0x14,
+	0x85, 0x10, 0x04, 0x6a, 0xea, 0x14, 0x45, 0x7f, 0x15, 0x50, 0xc8, 0xb5, 0x4c, 0x58, 0x26, 0x85,
+	0x25, 0xe5, 0x34, 0xfd, 0x63, 0xd0, 0xd4, 0x58, 0x66, 0x23, 0x8a, 0x2a, 0x97, 0x4a, 0xeb, 0xa2,
+	0x17, 0xe9, 0x15, 0x7a, 0x85, 0x5e, 0xa5, 0x97, 0xe8, 0x0d, 0x8a, 0x5d, 0x4a, 0xfe, 0x82, 0x0b,
+	0xd4, 0xff, 0x66, 0x9e, 0xde, 0xdb, 0x19, 0xbe, 0x9d, 0x59, 0x01, 0x64, 0x15, 0xe6, 0xc3, 0x75,
+	0x59, 0x54, 0x05, 0x35, 0x16, 0x69, 0x92, 0x5e, 0x62, 0xff, 0x2f, 0x1d, 0x3a, 0x7e, 0x85, 0xf9,
+	0x09, 0x0a, 0x91, 0x2c, 0x90, 0x7e, 0x0b, 0x6d, 0xb7, 0xc8, 0xf3, 0x64, 0x35, 0xb7, 0x35, 0x47,
+	0x1b, 0xec, 0x1d, 0xbc, 0x18, 0xd6, 0xcc, 0xe1, 0x2d, 0xd6, 0x70, 0x4b, 0x11, 0xbc, 0x9d, 0xd6,
+	0x11, 0xa5, 0xd0, 0x0a, 0x92, 0x1c, 0xed, 0x86, 0xa3, 0x0d, 0x2c, 0xde, 0x5a, 0x25, 0x39, 0xd2,
+	0x97, 0x00, 0xec, 0xd7, 0x75, 0x56, 0x26, 0x55, 0x56, 0xac, 0xec, 0xa6, 0xa3, 0x0d, 0x9a, 0x1c,
+	0xf0, 0x1a, 0xa1, 0x9f, 0x83, 0x11, 0x9e, 0xff, 0x84, 0x69, 0x65, 0xb7, 0x1c, 0x6d, 0xd0, 0xe5,
+	0x46, 0xa1, 0x32, 0xa9, 0x73, 0x8b, 0x7c, 0x5d, 0xa2, 0x10, 0x38, 0xb7, 0x75, 0x47, 0x1b, 0x98,
+	0x1c, 0xd2, 0x6b, 0x84, 0x3e, 0x03, 0xdd, 0xc3, 0x65, 0x95, 0xd8, 0x86, 0xa3, 0x0d, 0x28, 0xd7,
+	0xe7, 0x32, 0xa1, 0x36, 0xb4, 0x4f, 0xb1, 0x14, 0xb2, 0x54, 0xdb, 0xd1, 0x06, 0x2d, 0xde, 0xfe,
+	0x54, 0xa7, 0xf4, 0x15, 0xe8, 0x51, 0x95, 0x54, 0xc2, 0x36, 0x1d, 0x6d, 0xd0, 0x39, 0x78, 0xb6,
+	0xfb, 0x22, 0x05, 0x6e, 0x3f, 0x89, 0xeb, 0x42, 0x66, 0xf4, 0x05, 0x58, 0x1c, 0x7f, 0xde, 0xa0,
+	0xa8, 0x7c, 0xcf, 0xb6, 0xd4, 0x39, 0x56, 0xb9, 0x03, 0xe8, 0x37, 0x60, 0x48, 0xd1, 0x46, 0xd8,
+	0xf0, 0xdf, 0xe6, 0xd4, 0x0c, 0x14, 0xdc, 0x10, 0x2a, 0x92, 0xfd, 0xb2, 0xb2, 0x2c, 0x4a, 0xbb,
+	0xa3, 0xcc, 0xd1, 0x51, 0x26, 0xd2, 0xb1, 0x63, 0xbc, 0x12, 0x76, 0xd7, 0x69, 0x4a, 0xc7, 0x3e,
+	0xe2, 0x95, 0x62, 0xc6, 0xc5, 0x47, 0x5c, 0xd9, 0xbd, 0x9a, 0x59, 0xc9, 0xa4, 0xff, 0x87, 0x06,
+	0xe6, 0xce, 0x71, 0xda, 0x86, 0x66, 0xc4, 0x62, 0xf2, 0x44, 0x06, 0x63, 0x16, 0x13, 0x8d, 0x5a,
+	0xa0, 0x4f, 0x67, 0x7c, 0xcc, 0x48, 0x83, 0x9a, 0xd0, 0xf2, 0xd8, 0xc8, 0x23, 0x4d, 0x19, 0xf9,
+	0x81, 0xcb, 0x49, 0xab, 0xc6, 0x5c, 0x4e, 0x74, 0xa9, 0x18, 0x79, 0x1e, 0x31, 0x68, 0x07, 0xda,
+	0x9c, 0x4d, 0x27, 0x23, 0x97, 0x91, 0xb6, 0x44, 0xdd, 0x51, 0x44, 0x4c, 0x0a, 0x60, 0x78, 0x6c,
+	0xc2, 0x62, 0x46, 0x2c, 0x79, 0x66, 0x14, 0x8f, 0xe2, 0x88, 0x80, 0xd4, 0x1f, 0xb3, 0x0f, 0x11,
+	0xe9, 0xc8, 0x68, 0x34, 0x8b, 0x8f, 0x48, 0x57, 0x46, 0x53, 0x3f, 0x18, 0x93, 0x5e, 0xff, 0x77,
+	0x30, 0x77, 0xdf, 0x4b, 0x0d, 0x68, 0x84, 0xc7, 0xe4, 0x09, 0xed, 0x81, 0x15, 0x84, 0xf1, 0xd9,
+	0x61, 0x38, 0x0b, 0x3c, 0xa2, 0xc9, 0x73, 0xd9, 0x0f, 0x7e, 0x14, 0x47, 0xa4, 0x21, 0x2b, 0xfb,
+	0xc1, 0xe9, 0x68, 0xe2, 0xcb, 0x1e, 0x7b, 0x60, 0xc5, 0x61, 0x78, 0x36, 0x19, 0xc9, 0xe6, 0x5b,
+	0x94, 0x40, 0x77, 0x16, 0xc8, 0x02, 0x21, 0xf7, 0x7f, 0x64, 0x1e, 0xd1, 0x25, 0x12, 0x31, 0x7e,
+	0xca, 0xf8, 0x19, 0xe3, 0x3c, 0xe4, 0xc4, 0x50, 0x12, 0x3e, 0x0b, 0xdc, 0x51, 0xcc, 0x3c, 0xd2,
+	0xee, 0xff, 0xdd, 0x80, 0xee, 0xed, 0x5b, 0x94, 0xa3, 0x23, 0xaf, 0x42, 0xb8, 0xc5, 0x66, 0x55,
+	0xa9, 0x09, 0xa6, 0x1c, 0xb2, 0x6b, 0x84, 0xbe, 0x02, 0x32, 0xc6, 0x2a, 0xda, 0xa4, 0x29, 0x0a,
+	0x11, 0x6c, 0xf2, 0x73, 0x2c, 0xd5, 0xc8, 0x52, 0x4e, 0x16, 0xf7, 0x70, 0xfa, 0x25, 0xec, 0x8d,
+	0xb1, 0x52, 0x37, 0xb7, 0x65, 0x36, 0x15, 0x73, 0x6f, 0x71, 0x07, 0xa5, 0xaf, 0x61, 0x3f, 0xc2,
+	0x2a, 0x2c, 0x39, 0xae, 0x97, 0x49, 0x8a, 0x75, 0xe9, 0x96, 0xa2, 0xee, 0x8b, 0xfb, 0x3f, 0x50,
+	0x07, 0x3a, 0x1e, 0x2e, 0xb1, 0xda, 0xf2, 0x74, 0xc5, 0xeb, 0xcc, 0x6f, 0x20, 0xfa, 0x05, 0xf4,
+	0x6a, 0x86, 0x5a, 0x1e, 0x9c, 0x6f, 0xc7, 0xbc, 0x37, 0xbf, 0x0d, 0xca, 0x41, 0x8d, 0xb2, 0xdf,
+	0x70, 0x92, 0xe5, 0x59, 0xa5, 0x06, 0x9e, 0x72, 0x4b, 0xec, 0x00, 0xfa, 0x1c, 0x4c, 0x9e, 0xfc,
+	0xf2, 0xee, 0xaa, 0xc2, 0x7a, 0xea, 0x29, 0x37, 0xcb, 0x6d, 0x4e, 0x07, 0xf0, 0xf4, 0x66, 0xbd,
+	0x6a, 0x8a, 0xa5, 0x28, 0x4f, 0xd3, 0xbb, 0x70, 0xff, 0x9f, 0x06, 0xf4, 0xdc, 0x62, 0x75, 0x91,
+	0x2d, 0x76, 0xfe, 0xbe, 0x86, 0x7d, 0x0f, 0x2f, 0x92, 0xcd, 0xb2, 0xba, 0xb5, 0xd9, 0x9a, 0xda,
+	0xec, 0xfd, 0xf9, 0xfd, 0x1f, 0xee, 0xf6, 0xd8, 0xb8, 0xdf, 0xe3, 0x4b, 0x80, 0xe8, 0x32, 0x29,
+	0xe7, 0xb5, 0x11, 0xb5, 0xb7, 0x20, 0xae, 0x11, 0xe9, 0x83, 0x2f, 0x8e, 0x11, 0xd7, 0x33, 0x81,
+	0x17, 0x9b, 0xe5, 0x52, 0x79, 0x6a, 0xf2, 0x5e, 0x76, 0x1b, 0xa4, 0xdf, 0x83, 0xe5, 0xca, 0x15,
+	0x8c, 0xaf, 0xd6, 0xa8, 0xdc, 0xdc, 0x3b, 0x70, 0x76, 0x5b, 0x79, 0xa7, 0xf7, 0xe1, 0x35, 0x4d,
+	0x70, 0x2b, 0xdd, 0xc5, 0xf2, 0x3e, 0x4e, 0x30, 0x2f, 0xca, 0xab, 0xba, 0xcb, 0xda, 0xeb, 0x4e,
+	0x7e, 0x03, 0x49, 0x2f, 0x8f, 0x12, 0x71, 0x79, 0xb8, 0x59, 0xa5, 0xca, 0x68, 0x8b, 0x9b, 0x97,
+	0xdb, 0xbc, 0x3f, 0x05, 0xb8, 0x39, 0x56, 0xae, 0x12, 0x7f, 0x3f, 0x21, 0x4f, 0x68, 0x17, 0xcc,
+	0x49, 0xe8, 0x1e, 0x87, 0xc1, 0xe4, 0x03, 0xd1, 0x28, 0x85, 0xbd, 0xc8, 0x0f, 0xc6, 0x13, 0x36,
+	0x0e, 0xf9, 0x2c, 0xf6, 0x03, 0xb9, 0xa9, 0x00, 0x06, 0x67, 0x27, 0x61, 0xcc, 0x48, 0x53, 0x2e,
+	0x45, 0x78, 0x78, 0x78, 0xc4, 0x46, 0x53, 0xd2, 0x3a, 0xf8, 0xb3, 0x09, 0x5d, 0x75, 0x64, 0x84,
+	0xe5, 0xa7, 0x2c, 0x45, 0xfa, 0x15, 0x34, 0xc7, 0x58, 0xd1, 0xcf, 0x1e, 0x78, 0x6a, 0x9e, 0x3f,
+	0x04, 0x4a, 0x41, 0xf4, 0x28, 0xc1, 0x01, 0x18, 0xf5, 0xc0, 0x3d, 0x42, 0xf3, 0x35, 0xe8, 0xd3,
+	0x4d, 0xb9, 0xc0, 0x47, 0x95, 0xa9, 0x9f, 0xe1, 0x87, 0x25, 0x0f, 0xbe, 0xca, 0x74, 0x08, 0x0d,
+	0xaf, 0x78, 0x44, 0x8d, 0xef, 0xc0, 0x7c, 0x97, 0x54, 0xe9, 0xe5, 0xa3, 0x1c, 0x1b, 0x68, 0x6f,
+	0x34, 0xfa, 0x16, 0xf4, 0xf7, 0x52, 0xf9, 0xff, 0x65, 0x6f, 0xb4, 0x73, 0x43, 0xfd, 0x99, 0xbe,
+	0xfd, 0x77, 0x00, 0x6c, 0x6e, 0xef, 0xdd, 0x5a, 0x07, 0x00, 0x00,
 }
@@ -0,0 +1,515 @@
+package gcache
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	memcacheMaxKeyLength     = 250
+	memcacheMaxLineLength    = 2048
+	memcacheRelativeExpLimit = 60 * 60 * 24 * 30 //bigger exptime is unix timestamp
+	memcacheMaxSkip          = 1 << 30           //bigger value is not skipped and connection is closed
+
+	memcacheEnd           = "END\r\n"
+	memcacheStored        = "STORED\r\n"
+	memcacheNotStored     = "NOT_STORED\r\n"
+	memcacheExists        = "EXISTS\r\n"
+	memcacheNotFound      = "NOT_FOUND\r\n"
+	memcacheDeleted       = "DELETED\r\n"
+	memcacheTouched       = "TOUCHED\r\n"
+	memcacheOK            = "OK\r\n"
+	memcacheError         = "ERROR\r\n"
+	memcacheBadFormat     = "CLIENT_ERROR bad command line format\r\n"
+	memcacheBadChunk      = "CLIENT_ERROR bad data chunk\r\n"
+	memcacheBadDelta      = "CLIENT_ERROR invalid numeric delta argument\r\n"
+	memcacheNonNumeric    = "CLIENT_ERROR cannot increment or decrement non-numeric value\r\n"
+	memcacheLineTooLong   = "CLIENT_ERROR line is too long\r\n"
+	memcacheTooLarge      = "SERVER_ERROR object too large for cache\r\n"
+	memcacheOutOfMemory   = "SERVER_ERROR out of memory storing object\r\n"
+	memcacheServerFailure = "SERVER_ERROR "
+)
+
+var processStart = time.Now()
+
+//memcacheCommands map storage commands to cache commands to check permissions
+var memcacheCommands = map[string]ItemMessage_Commands{
+	"set":     ItemMessage_SET,
+	"add":     ItemMessage_ADD,
+	"replace": ItemMessage_REPLACE,
+	"cas":     ItemMessage_CAS,
+}
+
+//handleMemcache serve memcached text protocol
+func handleMemcache(ln net.Listener, cache Cacher, sec *Security) {
+	serveListener(modeMemcache, ln, cache, sec)
+}
+
+//memcacheConn is a state of one memcached text protocol connection
+//Client flags are stored with item if cache is FlagsCacher, they are 0 for other caches and values written by other protocols.
+//Protocol has no authentication, so connection is anonymous or authenticated by client certificate
+type memcacheConn struct {
+	r     *bufio.Reader
+	w     *bufio.Writer
+	cache Cacher
+	sess  *session
+	flush *delayedPurge
+}
+
+//serveMemcache handle commands until quit or end of stream, nil session allow all commands.
+//Delayed flush_all is scheduled by flush, nil flush mean it is canceled when stream is finished
+func serveMemcache(rw io.ReadWriter, cache Cacher, sess *session, flush *delayedPurge) {
+	if flush == nil {
+		flush = &delayedPurge{}
+		defer flush.stop()
+	}
+	m := &memcacheConn{
+		r:     bufio.NewReaderSize(rw, memcacheMaxLineLength),
+		w:     bufio.NewWriter(rw),
+		cache: cache,
+		sess:  sess,
+		flush: flush,
+	}
+	defer m.w.Flush()
+	for {
+		line, err := m.r.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			m.w.WriteString(memcacheLineTooLong)
+			return
+		}
+		if err != nil {
+			return
+		}
+		if m.handle(strings.Fields(string(line))) {
+			return
+		}
+		if m.r.Buffered() == 0 { //flush only after all pipelined commands are handled
+			if m.w.Flush() != nil {
+				return
+			}
+		}
+	}
+}
+
+//handle execute one command, true mean connection should be closed
+func (m *memcacheConn) handle(args []string) bool {
+	if len(args) == 0 {
+		m.w.WriteString(memcacheError)
+		return false
+	}
+	switch args[0] {
+	case "get", "gets":
+		m.get(args[1:], args[0] == "gets")
+	case "set", "add", "replace", "cas":
+		return m.store(args[0], args[1:])
+	case "delete":
+		m.delete(args[1:])
+	case "incr", "decr":
+		m.incr(args[0] == "incr", args[1:])
+	case "touch":
+		m.touch(args[1:])
+	case "flush_all":
+		m.flushAll(args[1:])
+	case "stats":
+		m.stats()
+	case "version":
+		m.w.WriteString("VERSION " + ServerVersion + "\r\n")
+	case "verbosity":
+		m.reply(memcacheOK, noreply(args[1:], 2))
+	case "quit":
+		return true
+	default:
+		m.w.WriteString(memcacheError)
+	}
+	return false
+}
+
+//noreply check optional last argument
+func noreply(args []string, pos int) bool {
+	return len(args) == pos && args[pos-1] == "noreply"
+}
+
+func (m *memcacheConn) reply(msg string, quiet bool) {
+	if !quiet {
+		m.w.WriteString(msg)
+	}
+}
+
+//allowed check permission of session, false mean error is written
+func (m *memcacheConn) allowed(cmd ItemMessage_Commands, key string) bool {
+	err := m.sess.allow(cmd, key)
+	if err != nil {
+		m.w.WriteString("CLIENT_ERROR " + strings.ToLower(err.Error()) + "\r\n")
+		return false
+	}
+	return true
+}
+
+func validKey(key string) bool {
+	if len(key) > memcacheMaxKeyLength {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] <= ' ' || key[i] == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *memcacheConn) get(keys []string, withCAS bool) {
+	if len(keys) == 0 {
+		m.w.WriteString(memcacheError)
+		return
+	}
+	for _, key := range keys {
+		if !validKey(key) {
+			m.w.WriteString(memcacheBadFormat)
+			return
+		}
+	}
+	for _, key := range keys {
+		if !m.allowed(ItemMessage_GET, key) {
+			return
+		}
+	}
+	for _, key := range keys {
+		itm := m.cache.GetItem(key)
+		if itm == nil {
+			continue
+		}
+		if withCAS {
+			fmt.Fprintf(m.w, "VALUE %s %d %d %d\r\n", key, itm.Flags, len(itm.Object), itm.Version)
+		} else {
+			fmt.Fprintf(m.w, "VALUE %s %d %d\r\n", key, itm.Flags, len(itm.Object))
+		}
+		m.w.Write(itm.Object)
+		m.w.WriteString("\r\n")
+	}
+	m.w.WriteString(memcacheEnd)
+}
+
+//store handle <command> <key> <flags> <exptime> <bytes> [<cas unique>] [noreply], true mean connection should be closed
+func (m *memcacheConn) store(cmd string, args []string) bool {
+	need := 4
+	if cmd == "cas" {
+		need = 5
+	}
+	if len(args) != need && !noreply(args, need+1) {
+		m.w.WriteString(memcacheBadFormat)
+		return false
+	}
+	var (
+		key             = args[0]
+		quiet           = len(args) == need+1
+		flags, errFlags = strconv.ParseUint(args[1], 10, 32)
+		exptime, e1     = strconv.ParseInt(args[2], 10, 64)
+		size, e2        = strconv.Atoi(args[3])
+		version         uint64
+		e3              error
+	)
+	if cmd == "cas" {
+		version, e3 = strconv.ParseUint(args[4], 10, 64)
+	}
+	if !validKey(key) || errFlags != nil || e1 != nil || e2 != nil || e3 != nil || size < 0 {
+		m.w.WriteString(memcacheBadFormat)
+		return false
+	}
+	if size > DefaultMaxFrameSize {
+		if size > memcacheMaxSkip {
+			m.reply(memcacheTooLarge, quiet)
+			return true
+		}
+		if _, err := m.r.Discard(size + 2); err != nil {
+			return true
+		}
+		m.reply(memcacheTooLarge, quiet)
+		return false
+	}
+	data := make([]byte, size+2)
+	if _, err := io.ReadFull(m.r, data); err != nil {
+		return true
+	}
+	if data[size] != '\r' || data[size+1] != '\n' {
+		if data[size+1] != '\n' {
+			m.r.ReadSlice('\n') //skip rest of broken chunk
+		}
+		m.w.WriteString(memcacheBadChunk)
+		return false
+	}
+	value := data[:size]
+	exp, expired := memcacheExpiration(exptime)
+	if !m.allowed(memcacheCommands[cmd], key) {
+		return false
+	}
+
+	_, err := storeFlags(m.cache, memcacheCommands[cmd], key, value, uint32(flags), version, exp)
+	switch err {
+	case nil:
+		if expired { //stored and expired immediately
+			m.cache.Delete(key)
+		}
+		m.reply(memcacheStored, quiet)
+	case ErrExists:
+		m.reply(memcacheNotStored, quiet)
+	case ErrNotFound:
+		if cmd == "cas" {
+			m.reply(memcacheNotFound, quiet)
+		} else {
+			m.reply(memcacheNotStored, quiet)
+		}
+	case ErrVersionMismatch:
+		m.reply(memcacheExists, quiet)
+	case ErrSizeLimit:
+		m.reply(memcacheOutOfMemory, quiet)
+	default:
+		m.reply(memcacheServerFailure+err.Error()+"\r\n", quiet)
+	}
+	return false
+}
+
+//delete handle delete <key> [noreply]
+func (m *memcacheConn) delete(args []string) {
+	if (len(args) != 1 && !noreply(args, 2)) || !validKey(args[0]) {
+		m.w.WriteString(memcacheBadFormat)
+		return
+	}
+	if !m.allowed(ItemMessage_DELETE, args[0]) {
+		return
+	}
+	if m.cache.Delete(args[0]) {
+		m.reply(memcacheDeleted, len(args) == 2)
+	} else {
+		m.reply(memcacheNotFound, len(args) == 2)
+	}
+}
+
+//incr handle incr|decr <key> <value> [noreply], value is unsigned 64 bit, incr wraps and decr stops at 0
+//Missing item is not created, so it is implemented with CAS instead of Cacher.Incr
+func (m *memcacheConn) incr(up bool, args []string) {
+	if (len(args) != 2 && !noreply(args, 3)) || !validKey(args[0]) {
+		m.w.WriteString(memcacheBadFormat)
+		return
+	}
+	key, quiet := args[0], len(args) == 3
+	delta, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil {
+		m.w.WriteString(memcacheBadDelta)
+		return
+	}
+	cmd := ItemMessage_DECR
+	if up {
+		cmd = ItemMessage_INCR
+	}
+	if !m.allowed(cmd, key) {
+		return
+	}
+	for {
+		itm := m.cache.GetItem(key)
+		if itm == nil {
+			m.reply(memcacheNotFound, quiet)
+			return
+		}
+		value, err := strconv.ParseUint(strings.TrimSpace(string(itm.Object)), 10, 64)
+		if err != nil {
+			m.reply(memcacheNonNumeric, quiet)
+			return
+		}
+		switch {
+		case up:
+			value += delta
+		case delta > value:
+			value = 0
+		default:
+			value -= delta
+		}
+		data := strconv.AppendUint(nil, value, 10)
+		_, err = storeFlags(m.cache, ItemMessage_CAS, key, data, itm.Flags, itm.Version, remainingExpiration(itm))
+		switch err {
+		case nil:
+			m.reply(string(data)+"\r\n", quiet)
+			return
+		case ErrVersionMismatch:
+			continue //concurrent update, try again
+		case ErrNotFound:
+			m.reply(memcacheNotFound, quiet)
+			return
+		default:
+			m.reply(memcacheServerFailure+err.Error()+"\r\n", quiet)
+			return
+		}
+	}
+}
+
+//touch handle touch <key> <exptime> [noreply]
+func (m *memcacheConn) touch(args []string) {
+	if (len(args) != 2 && !noreply(args, 3)) || !validKey(args[0]) {
+		m.w.WriteString(memcacheBadFormat)
+		return
+	}
+	key, quiet := args[0], len(args) == 3
+	exptime, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		m.w.WriteString(memcacheBadFormat)
+		return
+	}
+	if !m.allowed(ItemMessage_SET, key) {
+		return
+	}
+	exp, expired := memcacheExpiration(exptime)
+	touched, err := touchItem(m.cache, key, exp)
+	switch {
+	case err != nil:
+		m.reply(memcacheServerFailure+err.Error()+"\r\n", quiet)
+	case !touched:
+		m.reply(memcacheNotFound, quiet)
+	default:
+		if expired {
+			m.cache.Delete(key)
+		}
+		m.reply(memcacheTouched, quiet)
+	}
+}
+
+//flushAll handle flush_all [delay] [noreply]
+func (m *memcacheConn) flushAll(args []string) {
+	quiet := len(args) > 0 && args[len(args)-1] == "noreply"
+	if quiet {
+		args = args[:len(args)-1]
+	}
+	if len(args) > 1 {
+		m.w.WriteString(memcacheBadFormat)
+		return
+	}
+	if !m.allowed(ItemMessage_PURGE, "") {
+		return
+	}
+	if len(args) == 1 {
+		delay, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			m.w.WriteString(memcacheBadFormat)
+			return
+		}
+		if delay > 0 {
+			m.flush.schedule(time.Duration(delay)*time.Second, m.cache)
+			m.reply(memcacheOK, quiet)
+			return
+		}
+	}
+	m.flush.cancel()
+	m.cache.Purge()
+	m.reply(memcacheOK, quiet)
+}
+
+//delayedPurge is a pending flush_all with delay, new flush_all replace it like in memcached.
+//It is stopped on shutdown of server so cache is not purged after Dead
+type delayedPurge struct {
+	l       sync.Mutex
+	timer   *time.Timer
+	stopped bool
+}
+
+//schedule purge of cache after delay instead of pending one
+func (d *delayedPurge) schedule(delay time.Duration, cache Cacher) {
+	d.l.Lock()
+	defer d.l.Unlock()
+	if d.stopped {
+		return
+	}
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(delay, func() {
+		d.l.Lock()
+		defer d.l.Unlock()
+		if !d.stopped && d.timer == timer { //timer could fire while it is replaced
+			d.timer = nil
+			cache.Purge()
+		}
+	})
+	d.timer = timer
+}
+
+//cancel pending purge
+func (d *delayedPurge) cancel() {
+	d.l.Lock()
+	defer d.l.Unlock()
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+}
+
+//stop cancel pending purge and ignore next ones, it waits purge which is in progress
+func (d *delayedPurge) stop() {
+	d.l.Lock()
+	defer d.l.Unlock()
+	d.stopped = true
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+}
+
+func (m *memcacheConn) stats() {
+	if !m.allowed(ItemMessage_STATS, "") {
+		return
+	}
+	s := m.cache.Statistic()
+	now := time.Now()
+	for _, stat := range []struct {
+		name  string
+		value interface{}
+	}{
+		{"pid", os.Getpid()},
+		{"uptime", int64(now.Sub(processStart) / time.Second)},
+		{"time", now.Unix()},
+		{"version", ServerVersion},
+		{"curr_items", s.ItemsCount},
+		{"get_hits", s.GetSuccessNumber},
+		{"get_misses", s.GetErrorNumber},
+		{"cmd_set", s.SetOrReplaceCount},
+		{"delete_hits", s.DeleteCount},
+		{"expired_unfetched", s.DeleteExpired},
+		{"limit_items", s.SizeLimit},
+	} {
+		fmt.Fprintf(m.w, "STAT %s %v\r\n", stat.name, stat.value)
+	}
+	m.w.WriteString(memcacheEnd)
+}
+
+//memcacheExpiration convert exptime: 0 mean forever, negative mean already expired,
+// more than 30 days is unix timestamp
+func memcacheExpiration(exptime int64) (exp time.Duration, expired bool) {
+	switch {
+	case exptime == 0:
+		return NoExpiration, false
+	case exptime > memcacheRelativeExpLimit:
+		exp = time.Until(time.Unix(exptime, 0))
+	default:
+		exp = time.Duration(exptime) * time.Second
+	}
+	if exp <= 0 {
+		return time.Nanosecond, true
+	}
+	return exp, false
+}
+
+//remainingExpiration return relative expiration which keep item absolute expiration
+func remainingExpiration(itm *Item) time.Duration {
+	if itm.Expiration == 0 {
+		return NoExpiration
+	}
+	if exp := time.Until(time.Unix(0, itm.Expiration)); exp > 0 {
+		return exp
+	}
+	return time.Nanosecond
+}
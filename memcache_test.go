@@ -0,0 +1,192 @@
+package gcache
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//readTranscript parse client lines "> " and expected server lines "< "
+func readTranscript(t *testing.T, path string) (request, responce string) {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var in, out bytes.Buffer
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, ">"):
+			in.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, ">"), " ") + "\r\n")
+		case strings.HasPrefix(line, "<"):
+			out.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "<"), " ") + "\r\n")
+		}
+	}
+	return in.String(), out.String()
+}
+
+//runTranscripts replay every transcript of dir against fresh cache and fresh session of sec
+func runTranscripts(t *testing.T, dir string, sec *Security, serve func(io.ReadWriter, Cacher, *session)) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.txt"))
+	if err != nil || len(files) == 0 {
+		t.Fatal("no transcripts", err)
+	}
+	for _, path := range files {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			c := NewRwCache(&ConfigMessage{}) //keepUsefull would change ttl on every get
+			request, expected := readTranscript(t, path)
+			var out bytes.Buffer
+			serve(struct {
+				io.Reader
+				io.Writer
+			}{strings.NewReader(request), &out}, c, sec.session(nil))
+			assert.Equal(t, expected, out.String())
+			c.Dead() //Cleanup
+		})
+	}
+}
+
+//serveMemcacheConn is serveMemcache with delayed flush_all of connection
+func serveMemcacheConn(rw io.ReadWriter, cache Cacher, sess *session) {
+	serveMemcache(rw, cache, sess, nil)
+}
+
+func TestMemcache_Transcripts(t *testing.T) {
+	runTranscripts(t, "testdata/memcache", nil, serveMemcacheConn)
+}
+
+func TestMemcache_AuthTranscripts(t *testing.T) {
+	runTranscripts(t, "testdata/memcache-acl", &Security{ACL: testACL()}, serveMemcacheConn)
+}
+
+func TestMemcache_Stats(t *testing.T) {
+	as := assert.New(t)
+	c := NewRwCache(defaultConfig())
+	c.SetOrUpdate("first", []byte(`zaza`), DefaultExpirationMarker)
+	c.Get("first")
+	c.Get("absent")
+	var out bytes.Buffer
+	serveMemcache(struct {
+		io.Reader
+		io.Writer
+	}{strings.NewReader("stats\r\n"), &out}, c, nil, nil)
+
+	as.Contains(out.String(), "STAT version "+ServerVersion+"\r\n")
+	as.Contains(out.String(), "STAT curr_items 1\r\n")
+	as.Contains(out.String(), "STAT get_hits 1\r\n")
+	as.Contains(out.String(), "STAT get_misses 1\r\n")
+	as.True(strings.HasSuffix(out.String(), "END\r\n"))
+
+	c.Dead() //Cleanup
+}
+
+//pendingFlush report that delayed flush_all is scheduled
+func pendingFlush(d *delayedPurge) bool {
+	d.l.Lock()
+	defer d.l.Unlock()
+	return d.timer != nil
+}
+
+func TestMemcache_DelayedFlush(t *testing.T) {
+	as := assert.New(t)
+	c := NewGorCache(defaultConfig())
+	_, err := c.Add("first", []byte(`zaza`), NoExpiration)
+	as.NoError(err)
+
+	flush := &delayedPurge{}
+	flush.schedule(time.Hour, c)
+	flush.schedule(10*time.Millisecond, c)
+	time.Sleep(50 * time.Millisecond)
+	as.Nil(c.Get("first"), "the last flush_all replace previous one")
+
+	_, err = c.Add("first", []byte(`zaza`), NoExpiration)
+	as.NoError(err)
+	flush.schedule(10*time.Millisecond, c)
+	flush.cancel()
+	flush.schedule(time.Hour, c)
+	flush.stop()
+	flush.schedule(10*time.Millisecond, c)
+	time.Sleep(50 * time.Millisecond)
+	as.Equal([]byte(`zaza`), c.Get("first"), "flush_all is not done after stop")
+	as.False(pendingFlush(flush))
+	c.Dead() //Cleanup
+
+	s, cancel, served := startServer(t, NewGorCache(defaultConfig()), ServerOptions{}, modeMemcache)
+	conn, err := net.Dial("tcp", s.Addr(modeMemcache).String())
+	if as.NoError(err) {
+		fmt.Fprint(conn, "flush_all 1\r\n")
+		reply := make([]byte, len("OK\r\n"))
+		_, err = io.ReadFull(conn, reply)
+		as.NoError(err)
+		conn.Close()
+	}
+	as.True(pendingFlush(&s.flush))
+	cancel()
+	as.Equal(ErrServerClosed, <-served)
+	as.False(pendingFlush(&s.flush), "delayed flush_all is canceled on shutdown")
+}
+
+func TestMemcache_FlagsCaches(t *testing.T) {
+	as := assert.New(t)
+	rw := func(c ConfigCacheInterface) Cacher { return NewRwCache(c) }
+	for name, c := range map[string]Cacher{
+		"gor":     NewGorCache(defaultConfig()),
+		"offheap": NewOffHeapCache(defaultOffHeapConfig()),
+		"shard":   NewShardCache(defaultShardConfig(), rw, calcSUM),
+	} {
+		version, err := storeFlags(c, ItemMessage_ADD, "counter", []byte(`1`), 42, 0, NoExpiration)
+		as.NoError(err, name)
+		_, err = storeFlags(c, ItemMessage_CAS, "counter", []byte(`2`), 43, version, NoExpiration)
+		as.NoError(err, name)
+		_, err = c.Incr("counter", 1, NoExpiration)
+		as.NoError(err, name)
+		if itm := c.GetItem("counter"); as.NotNil(itm, name) {
+			as.Equal([]byte(`3`), itm.Object, name)
+			as.Equal(uint32(43), itm.Flags, "incr keep flags of %s", name)
+		}
+		_, err = c.Replace("counter", []byte(`zaza`), NoExpiration)
+		as.NoError(err, name)
+		as.Zero(c.GetItem("counter").Flags, "write without flags reset them in %s", name)
+		c.Dead() //Cleanup
+	}
+}
+
+func TestHandleMemcache(t *testing.T) {
+	as := assert.New(t)
+	c := NewCompressCache(NewRwCache(defaultConfig()), 1)
+	ln, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if !as.NoError(err) {
+		return
+	}
+	defer ln.Close()
+	go handleMemcache(ln, c, nil)
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if !as.NoError(err) {
+		return
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	conn.Write([]byte("set first 0 0 4\r\nzaza\r\n"))
+	line, _ := r.ReadString('\n')
+	as.Equal("STORED\r\n", line)
+	conn.Write([]byte("get first\r\n"))
+	for _, expected := range []string{"VALUE first 0 4\r\n", "zaza\r\n", "END\r\n"} {
+		line, _ = r.ReadString('\n')
+		as.Equal(expected, line)
+	}
+	as.Equal([]byte(`zaza`), c.Get("first"), "shared with other protocols")
+
+	c.Dead() //Cleanup
+}
@@ -0,0 +1,456 @@
+package gcache
+
+import (
+	"encoding/binary"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	//DefaultMemoryLimit is a size of all ring buffers of OffHeapCache if config does not set it
+	DefaultMemoryLimit = 64 << 20 //64Mb
+
+	defaultOffHeapShards = 16
+	maxShardMemory       = 1<<32 - 1 //offsets are uint32
+
+	//entry header: length(4) hash(8) expiration(8) version(8) flags(4) key length(2)
+	entryLenOffset     = 0
+	entryHashOffset    = 4
+	entryExpOffset     = 12
+	entryVersionOffset = 20
+	entryFlagsOffset   = 28
+	entryKeyLen        = 32
+	entryHeaderSize    = 34
+	maxKeySize         = 1<<16 - 1
+)
+
+//OffHeapCache keeps keys and values in preallocated byte ring buffers
+// and index it with a pointer free map, so GC does not scan items.
+//Old items are overwritten when ring buffer is full.
+type OffHeapCache struct {
+	defaultExpiration int64
+	shards            []*offHeapShard
+	shardCount        uint64
+	janitor           *janitor
+	stats             Stats
+	isKeepUsefull     bool
+	version           uint64
+}
+
+type offHeapShard struct {
+	l     sync.RWMutex
+	index map[uint64]uint32 //hash of key -> offset of entry in ring
+	ring  []byte
+	head  uint64 //virtual offset of the oldest entry
+	tail  uint64 //virtual offset for the next entry
+}
+
+//NewOffHeapCache create new OffHeapCache based on cache config
+//MemoryLimit is split between ShardCount ring buffers
+func NewOffHeapCache(config ConfigOffHeapCacheInterface) *OffHeapCache {
+	defaultExpiration := config.GetDefaultExpiration()
+	if defaultExpiration <= 0 {
+		defaultExpiration = int64(DefaultExpiration)
+	}
+	shardCount := config.GetShardCount()
+	if shardCount <= 0 {
+		shardCount = defaultOffHeapShards
+	}
+	memory := config.GetMemoryLimit()
+	if memory <= 0 {
+		memory = DefaultMemoryLimit
+	}
+	shardSize := memory / shardCount
+	if shardSize > maxShardMemory {
+		shardSize = maxShardMemory
+	}
+	if shardSize < entryHeaderSize {
+		shardSize = entryHeaderSize
+	}
+
+	cache := &OffHeapCache{
+		defaultExpiration: defaultExpiration,
+		shards:            make([]*offHeapShard, shardCount),
+		shardCount:        uint64(shardCount),
+		janitor: &janitor{
+			Interval: time.Duration(defaultExpiration * 5),
+			stop:     make(chan bool),
+		},
+		stats: Stats{
+			SizeLimit: config.GetSizeLimit(),
+		},
+		isKeepUsefull: config.GetIsKeepUsefull(),
+	}
+	for i := range cache.shards {
+		cache.shards[i] = &offHeapShard{
+			index: make(map[uint64]uint32),
+			ring:  make([]byte, shardSize),
+		}
+	}
+
+	go cache.janitor.Run(cache)
+
+	return cache
+}
+
+func (c *OffHeapCache) getShard(hash uint64) *offHeapShard {
+	return c.shards[hash%c.shardCount]
+}
+
+//Get return copy of item by name or nil
+func (c *OffHeapCache) Get(name string) []byte {
+	if itm, ok := c.get(name); ok {
+		return itm.Object
+	}
+	return nil
+}
+
+//GetItem return copy of item by name or nil
+func (c *OffHeapCache) GetItem(name string) *Item {
+	if itm, ok := c.get(name); ok {
+		return &itm
+	}
+	return nil
+}
+
+func (c *OffHeapCache) get(name string) (Item, bool) {
+	hash := calcFNVInline(name)
+	s := c.getShard(hash)
+	now := time.Now().UnixNano()
+	var (
+		itm Item
+		ok  bool
+	)
+	if c.isKeepUsefull {
+		s.l.Lock()
+		var offset uint32
+		if offset, ok = s.lookup(hash, name, now); ok {
+			if exp := s.expiration(offset); exp != 0 {
+				s.setExpiration(offset, now+atomic.LoadInt64(&c.defaultExpiration))
+			}
+			itm = s.item(offset)
+		}
+		s.l.Unlock()
+	} else {
+		s.l.RLock()
+		var offset uint32
+		if offset, ok = s.lookup(hash, name, now); ok {
+			itm = s.item(offset)
+		}
+		s.l.RUnlock()
+	}
+	if !ok {
+		atomic.AddInt64(&c.stats.GetErrorNumber, 1)
+		return itm, false
+	}
+	atomic.AddInt64(&c.stats.GetSuccessNumber, 1)
+	return itm, true
+}
+
+//SetOrUpdate set or update item in cache
+//Items bigger than shard ring buffer are ignored
+func (c *OffHeapCache) SetOrUpdate(name string, value []byte, exp time.Duration) {
+	c.StoreFlags(ItemMessage_SET, name, value, 0, 0, exp)
+}
+
+//Add set item only if it is absent
+func (c *OffHeapCache) Add(name string, value []byte, exp time.Duration) (uint64, error) {
+	return c.StoreFlags(ItemMessage_ADD, name, value, 0, 0, exp)
+}
+
+//Replace set item only if it is present
+func (c *OffHeapCache) Replace(name string, value []byte, exp time.Duration) (uint64, error) {
+	return c.StoreFlags(ItemMessage_REPLACE, name, value, 0, 0, exp)
+}
+
+//CAS set item only if its version is not changed
+func (c *OffHeapCache) CAS(name string, value []byte, version uint64, exp time.Duration) (uint64, error) {
+	return c.StoreFlags(ItemMessage_CAS, name, value, 0, version, exp)
+}
+
+//StoreFlags is SetOrUpdate, Add, Replace or CAS by cmd which also store client flags of memcached protocol
+func (c *OffHeapCache) StoreFlags(cmd ItemMessage_Commands, name string, value []byte, flags uint32, version uint64, exp time.Duration) (uint64, error) {
+	if len(name) > maxKeySize {
+		return 0, ErrNotSupported
+	}
+	hash := calcFNVInline(name)
+	s := c.getShard(hash)
+	s.l.Lock()
+	defer s.l.Unlock()
+	var current *Item
+	if offset, found := s.lookup(hash, name, time.Now().UnixNano()); found {
+		current = &Item{Version: s.version(offset)}
+	}
+	if err := checkStore(cmd, current, version); err != nil {
+		return 0, err
+	}
+	newVersion := atomic.AddUint64(&c.version, 1)
+	if !s.push(hash, name, value, expireAt(exp, atomic.LoadInt64(&c.defaultExpiration)), newVersion, flags) {
+		return 0, ErrNotSupported
+	}
+	atomic.AddInt64(&c.stats.SetOrReplaceCount, 1)
+	return newVersion, nil
+}
+
+//Incr add delta to decimal item or create it
+func (c *OffHeapCache) Incr(name string, delta int64, exp time.Duration) (int64, error) {
+	if len(name) > maxKeySize {
+		return 0, ErrNotSupported
+	}
+	hash := calcFNVInline(name)
+	s := c.getShard(hash)
+	s.l.Lock()
+	defer s.l.Unlock()
+	var (
+		current    []byte
+		expiration = expireAt(exp, atomic.LoadInt64(&c.defaultExpiration))
+		flags      uint32
+	)
+	if offset, ok := s.lookup(hash, name, time.Now().UnixNano()); ok {
+		current = s.value(offset)
+		expiration = s.expiration(offset)
+		flags = s.flags(offset)
+	}
+	value, result, err := incrValue(current, delta)
+	if err != nil {
+		return 0, err
+	}
+	if !s.push(hash, name, value, expiration, atomic.AddUint64(&c.version, 1), flags) {
+		return 0, ErrNotSupported
+	}
+	atomic.AddInt64(&c.stats.SetOrReplaceCount, 1)
+	return result, nil
+}
+
+//Decr subtract delta from decimal item or create it
+func (c *OffHeapCache) Decr(name string, delta int64, exp time.Duration) (int64, error) {
+	delta, err := negate(delta)
+	if err != nil {
+		return 0, err
+	}
+	return c.Incr(name, delta, exp)
+}
+
+//Delete item from the cache, ring space is reused later
+func (c *OffHeapCache) Delete(name string) bool {
+	hash := calcFNVInline(name)
+	s := c.getShard(hash)
+	s.l.Lock()
+	_, found := s.lookup(hash, name, time.Now().UnixNano())
+	if found {
+		delete(s.index, hash)
+	}
+	s.l.Unlock()
+	if found {
+		atomic.AddInt64(&c.stats.DeleteCount, 1)
+	}
+	return found
+}
+
+//Keys return names of all live items
+func (c *OffHeapCache) Keys() []string {
+	var (
+		now  = time.Now().UnixNano()
+		keys []string
+	)
+	for _, s := range c.shards {
+		s.l.RLock()
+		for _, offset := range s.index {
+			if exp := s.expiration(offset); exp == 0 || now <= exp {
+				keys = append(keys, string(s.key(offset)))
+			}
+		}
+		s.l.RUnlock()
+	}
+	return keys
+}
+
+//Purge delete all items from the cache
+func (c *OffHeapCache) Purge() {
+	for _, s := range c.shards {
+		s.l.Lock()
+		atomic.AddInt64(&c.stats.DeleteCount, int64(len(s.index)))
+		s.index = make(map[uint64]uint32)
+		s.head, s.tail = 0, 0
+		s.l.Unlock()
+	}
+}
+
+//Dead stop all internal func and clear cache
+func (c *OffHeapCache) Dead() {
+	c.janitor.stop <- true
+	c.Purge()
+}
+
+//Statistic return all cache statistic
+func (c *OffHeapCache) Statistic() Stats {
+	var count int64
+	for _, s := range c.shards {
+		s.l.RLock()
+		count += int64(len(s.index))
+		s.l.RUnlock()
+	}
+	stats := Stats{
+		GetSuccessNumber:  atomic.LoadInt64(&c.stats.GetSuccessNumber),
+		GetErrorNumber:    atomic.LoadInt64(&c.stats.GetErrorNumber),
+		SetOrReplaceCount: atomic.LoadInt64(&c.stats.SetOrReplaceCount),
+		DeleteCount:       atomic.LoadInt64(&c.stats.DeleteCount),
+		DeleteExpired:     atomic.LoadInt64(&c.stats.DeleteExpired),
+		SizeLimit:         atomic.LoadInt64(&c.stats.SizeLimit),
+		ItemsCount:        count,
+	}
+	return stats
+}
+
+//Reconfigure apply DefaultExpiration and SizeLimit of config, SizeLimit is only reported because memory is limited by MemoryLimit
+func (c *OffHeapCache) Reconfigure(config ConfigCacheInterface) {
+	defaultExpiration := config.GetDefaultExpiration()
+	if defaultExpiration <= 0 {
+		defaultExpiration = int64(DefaultExpiration)
+	}
+	atomic.StoreInt64(&c.defaultExpiration, defaultExpiration)
+	atomic.StoreInt64(&c.stats.SizeLimit, config.GetSizeLimit())
+}
+
+//deleteExpired drop expired items from index, ring space is reused later
+func (c *OffHeapCache) deleteExpired() {
+	now := time.Now().UnixNano()
+	for _, s := range c.shards {
+		s.l.Lock()
+		for hash, offset := range s.index {
+			if exp := s.expiration(offset); exp != 0 && now > exp {
+				delete(s.index, hash)
+				atomic.AddInt64(&c.stats.DeleteExpired, 1)
+			}
+		}
+		s.l.Unlock()
+	}
+}
+
+//lookup find live entry offset of the key
+func (s *offHeapShard) lookup(hash uint64, name string, now int64) (uint32, bool) {
+	offset, ok := s.index[hash]
+	if !ok {
+		return 0, false
+	}
+	if string(s.key(offset)) != name { //hash collision
+		return 0, false
+	}
+	if exp := s.expiration(offset); exp != 0 && now > exp {
+		return 0, false
+	}
+	return offset, true
+}
+
+func (s *offHeapShard) entryLen(offset uint32) uint32 {
+	return binary.LittleEndian.Uint32(s.ring[offset+entryLenOffset:])
+}
+
+func (s *offHeapShard) expiration(offset uint32) int64 {
+	return int64(binary.LittleEndian.Uint64(s.ring[offset+entryExpOffset:]))
+}
+
+func (s *offHeapShard) setExpiration(offset uint32, exp int64) {
+	binary.LittleEndian.PutUint64(s.ring[offset+entryExpOffset:], uint64(exp))
+}
+
+func (s *offHeapShard) version(offset uint32) uint64 {
+	return binary.LittleEndian.Uint64(s.ring[offset+entryVersionOffset:])
+}
+
+func (s *offHeapShard) flags(offset uint32) uint32 {
+	return binary.LittleEndian.Uint32(s.ring[offset+entryFlagsOffset:])
+}
+
+func (s *offHeapShard) key(offset uint32) []byte {
+	keyLen := uint32(binary.LittleEndian.Uint16(s.ring[offset+entryKeyLen:]))
+	start := offset + entryHeaderSize
+	return s.ring[start : start+keyLen]
+}
+
+//value return copy of entry value
+func (s *offHeapShard) value(offset uint32) []byte {
+	keyLen := uint32(binary.LittleEndian.Uint16(s.ring[offset+entryKeyLen:]))
+	start := offset + entryHeaderSize + keyLen
+	end := offset + s.entryLen(offset)
+	v := make([]byte, end-start)
+	copy(v, s.ring[start:end])
+	return v
+}
+
+//item return copy of entry
+func (s *offHeapShard) item(offset uint32) Item {
+	return Item{
+		Object:     s.value(offset),
+		Expiration: s.expiration(offset),
+		Version:    s.version(offset),
+		Flags:      s.flags(offset),
+	}
+}
+
+//push write new entry at tail of ring and evict the oldest entries if there is no space
+func (s *offHeapShard) push(hash uint64, name string, value []byte, exp int64, version uint64, flags uint32) bool {
+	size := uint64(len(s.ring))
+	need := uint64(entryHeaderSize + len(name) + len(value))
+	if need > size {
+		return false
+	}
+	var pos, padding uint64
+	for {
+		if s.head == s.tail {
+			s.head, s.tail = 0, 0
+		}
+		pos = s.tail % size
+		padding = 0
+		if pos+need > size { //entry does not fit before the end, skip to the beginning
+			padding = size - pos
+		}
+		if s.tail+padding+need-s.head <= size {
+			break
+		}
+		s.evict()
+	}
+	if padding > 0 {
+		if padding >= 4 {
+			binary.LittleEndian.PutUint32(s.ring[pos:], 0)
+		}
+		s.tail += padding
+		pos = 0
+	}
+
+	offset := uint32(pos)
+	entry := s.ring[pos : pos+need]
+	binary.LittleEndian.PutUint32(entry[entryLenOffset:], uint32(need))
+	binary.LittleEndian.PutUint64(entry[entryHashOffset:], hash)
+	binary.LittleEndian.PutUint64(entry[entryExpOffset:], uint64(exp))
+	binary.LittleEndian.PutUint64(entry[entryVersionOffset:], version)
+	binary.LittleEndian.PutUint32(entry[entryFlagsOffset:], flags)
+	binary.LittleEndian.PutUint16(entry[entryKeyLen:], uint16(len(name)))
+	copy(entry[entryHeaderSize:], name)
+	copy(entry[entryHeaderSize+len(name):], value)
+	s.tail += need
+	s.index[hash] = offset
+	return true
+}
+
+//evict drop the oldest entry or skip padding at the end of ring
+func (s *offHeapShard) evict() {
+	size := uint64(len(s.ring))
+	pos := s.head % size
+	if size-pos < 4 {
+		s.head += size - pos
+		return
+	}
+	length := uint64(binary.LittleEndian.Uint32(s.ring[pos:]))
+	if length == 0 || s.head+length > s.tail { //padding
+		s.head += size - pos
+		return
+	}
+	offset := uint32(pos)
+	hash := binary.LittleEndian.Uint64(s.ring[pos+entryHashOffset:])
+	if current, ok := s.index[hash]; ok && current == offset {
+		delete(s.index, hash)
+	}
+	s.head += length
+}
@@ -0,0 +1,184 @@
+package gcache
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func defaultOffHeapConfig() ConfigOffHeapCacheInterface {
+	r := ConfigMessage{
+		SizeLimit:         20000,
+		DefaultExpiration: -1,
+		IsKeepUsefull:     true,
+		CacheType:         ConfigMessage_OFFHEAP,
+		ShardCount:        4,
+		MemoryLimit:       4 * 1024,
+	}
+	return &r
+}
+
+func TestOffHeapCache_Purge(t *testing.T) {
+	as := assert.New(t)
+	c := NewOffHeapCache(defaultOffHeapConfig())
+	c.SetOrUpdate("first", []byte(`zaza`), DefaultExpirationMarker)
+	as.Equal(int64(1), c.Statistic().ItemsCount)
+	c.Purge()
+
+	as.Nil(c.Get("first"))
+	as.Equal(int64(0), c.Statistic().ItemsCount)
+	as.Equal(int64(1), c.Statistic().DeleteCount)
+	c.Dead() //Cleanup
+}
+
+func TestOffHeapCache_Get(t *testing.T) {
+	as := assert.New(t)
+	c := NewOffHeapCache(defaultOffHeapConfig())
+	c.SetOrUpdate("first", []byte(`zaza`), DefaultExpirationMarker)
+	c.SetOrUpdate("second", []byte(`azaz`), DefaultExpirationMarker)
+
+	as.Nil(c.Get("irst"))
+	as.Equal(int64(2), c.Statistic().ItemsCount)
+	as.Equal([]byte(`zaza`), c.Get("first"))
+	as.Equal([]byte(`azaz`), c.Get("second"))
+	as.Equal(int64(2), c.Statistic().GetSuccessNumber)
+	as.Equal(int64(1), c.Statistic().GetErrorNumber)
+
+	c.Dead() //Cleanup
+}
+
+func TestOffHeapCache_SetOrUpdate(t *testing.T) {
+	as := assert.New(t)
+	c := NewOffHeapCache(defaultOffHeapConfig())
+	c.SetOrUpdate("first", []byte(`zaza`), DefaultExpirationMarker)
+	c.SetOrUpdate("second", []byte(`azaz`), DefaultExpirationMarker)
+	c.SetOrUpdate("second", []byte(`zara`), DefaultExpirationMarker)
+
+	as.Equal(int64(2), c.Statistic().ItemsCount)
+	as.Equal([]byte(`zaza`), c.Get("first"))
+	as.Equal([]byte(`zara`), c.Get("second"))
+	as.Equal(int64(3), c.Statistic().SetOrReplaceCount)
+
+	v := c.Get("first")
+	v[0] = 'x'
+	as.Equal([]byte(`zaza`), c.Get("first"), "Get should return a copy")
+
+	c.SetOrUpdate("huge", make([]byte, 2048), DefaultExpirationMarker)
+	as.Nil(c.Get("huge"), "item bigger than shard is ignored")
+
+	c.Dead() //Cleanup
+}
+
+func TestOffHeapCache_Expiration(t *testing.T) {
+	as := assert.New(t)
+	c := NewOffHeapCache(defaultOffHeapConfig())
+	c.SetOrUpdate("short", []byte(`zaza`), time.Millisecond)
+	c.SetOrUpdate("forever", []byte(`azaz`), NoExpiration)
+	time.Sleep(5 * time.Millisecond)
+
+	as.Nil(c.Get("short"))
+	as.Equal([]byte(`azaz`), c.Get("forever"))
+	c.deleteExpired()
+	as.Equal(int64(1), c.Statistic().ItemsCount)
+	as.Equal(int64(1), c.Statistic().DeleteExpired)
+
+	c.Dead() //Cleanup
+}
+
+func TestOffHeapCache_Evict(t *testing.T) {
+	as := assert.New(t)
+	config := &ConfigMessage{ShardCount: 1, MemoryLimit: 1024}
+	c := NewOffHeapCache(config)
+	value := make([]byte, 100)
+	for i := 0; i < 100; i++ {
+		name := fmt.Sprintf("key-%d", i)
+		value[0] = byte(i)
+		c.SetOrUpdate(name, value, NoExpiration)
+		got := c.Get(name)
+		if as.NotNil(got, name) {
+			as.Equal(byte(i), got[0])
+		}
+	}
+	as.Nil(c.Get("key-0"), "the oldest item should be overwritten")
+	as.NotNil(c.Get("key-99"))
+	as.True(c.Statistic().ItemsCount < 10)
+	as.True(c.Statistic().ItemsCount > 0)
+
+	c.Dead() //Cleanup
+}
+
+func TestOffHeapCache_Incr(t *testing.T) {
+	as := assert.New(t)
+	c := NewOffHeapCache(defaultOffHeapConfig())
+	v, err := c.Incr("counter", 9, time.Hour)
+	as.NoError(err)
+	as.Equal(int64(9), v)
+	v, err = c.Incr("counter", 1, DefaultExpirationMarker)
+	as.NoError(err)
+	as.Equal(int64(10), v)
+	v, err = c.Decr("counter", 11, DefaultExpirationMarker)
+	as.NoError(err)
+	as.Equal(int64(-1), v)
+	as.Equal([]byte(`-1`), c.Get("counter"))
+	as.Equal(int64(1), c.Statistic().ItemsCount)
+
+	c.SetOrUpdate("text", []byte(`zaza`), DefaultExpirationMarker)
+	_, err = c.Incr("text", 1, DefaultExpirationMarker)
+	as.Equal(ErrNotInteger, err)
+
+	_, err = c.Incr("counter", math.MinInt64, DefaultExpirationMarker)
+	as.Equal(ErrOverflow, err)
+	_, err = c.Decr("counter", math.MinInt64, DefaultExpirationMarker)
+	as.Equal(ErrOverflow, err)
+	as.Equal([]byte(`-1`), c.Get("counter"))
+
+	long := strings.Repeat("k", 1000) //entry is bigger than ring of shard
+	_, err = c.Incr(long, 1, DefaultExpirationMarker)
+	as.Equal(ErrNotSupported, err)
+	as.Nil(c.Get(long))
+
+	c.Dead() //Cleanup
+}
+
+func TestOffHeapCache_CAS(t *testing.T) {
+	c := NewOffHeapCache(defaultOffHeapConfig())
+	checkVersionedCacher(t, c)
+	c.Dead() //Cleanup
+}
+
+func benchmarkGC(c Cacher, b *testing.B) {
+	b.StopTimer()
+	value := make([]byte, 64)
+	for i := 0; i < 1e6; i++ {
+		c.SetOrUpdate(fmt.Sprintf("key-%d", i), value, NoExpiration)
+	}
+	runtime.GC()
+	var before, after debug.GCStats
+	debug.ReadGCStats(&before)
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		runtime.GC()
+	}
+	b.StopTimer()
+	debug.ReadGCStats(&after)
+	if n := after.NumGC - before.NumGC; n > 0 {
+		b.ReportMetric(float64(after.PauseTotal-before.PauseTotal)/float64(n), "pause-ns/gc")
+	}
+	runtime.KeepAlive(c)
+	c.Dead()
+}
+
+//Benchmarks of full GC cycle with 1e6 items inside cache
+func BenchmarkGC_Rwlockcache(b *testing.B) {
+	benchmarkGC(NewRwCache(&ConfigMessage{}), b)
+}
+
+func BenchmarkGC_OffHeapCache(b *testing.B) {
+	benchmarkGC(NewOffHeapCache(&ConfigMessage{MemoryLimit: 256 << 20}), b)
+}
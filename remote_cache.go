@@ -0,0 +1,749 @@
+package gcache
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//DefaultDialTimeout is a timeout of connection to remote cache
+const DefaultDialTimeout = 5 * time.Second
+
+//DefaultRetryBackoff is a delay before first retry of request
+const DefaultRetryBackoff = 10 * time.Millisecond
+
+var (
+	//ErrRemoteClosed is returned for requests after Dead
+	ErrRemoteClosed = errors.New("Remote cache is closed")
+	//ErrRemoteTimeout is returned when responce is not received in time
+	ErrRemoteTimeout = errors.New("Remote cache timeout")
+
+	errIdle = errors.New("Connection is idle")
+)
+
+//idempotentCommands could be sent again after timeout or broken connection,
+// retried DELETE is not one of them because it would report missing item
+var idempotentCommands = map[ItemMessage_Commands]bool{
+	ItemMessage_GET:   true,
+	ItemMessage_SET:   true,
+	ItemMessage_PURGE: true,
+	ItemMessage_STATS: true,
+	ItemMessage_KEYS:  true,
+	ItemMessage_PING:  true,
+}
+
+//RemoteError is an error reported by server which has no local equivalent
+type RemoteError struct {
+	Status  ItemMessage_Statuses
+	Message string
+}
+
+func (e *RemoteError) Error() string {
+	return e.Status.String() + ": " + e.Message
+}
+
+//knownErrors are errors which are restored from server responce
+var knownErrors = []error{ErrNotFound, ErrExists, ErrVersionMismatch, ErrNotInteger, ErrOverflow, ErrNotSupported, ErrSizeLimit, ErrFrameTooLarge, ErrUnauthorized, ErrForbidden, ErrTruncated}
+
+//responceError return error of failed request
+func responceError(resp *ItemMessage) error {
+	if resp.GetStatus() == ItemMessage_OK {
+		return nil
+	}
+	if err := knownError(resp.GetError()); err != nil {
+		return err
+	}
+	return &RemoteError{Status: resp.GetStatus(), Message: resp.GetError()}
+}
+
+//knownError return local error with message or nil
+func knownError(message string) error {
+	for _, err := range knownErrors {
+		if err.Error() == message {
+			return err
+		}
+	}
+	return nil
+}
+
+//retryable return true if request of cmd failed without responce could be sent again
+func retryable(cmd ItemMessage_Commands, err error) bool {
+	return idempotentCommands[cmd] && unavailable(err)
+}
+
+//unavailable return true if request failed by timeout or broken connection
+func unavailable(err error) bool {
+	var netErr net.Error
+	return err == ErrRemoteTimeout || err == io.EOF || err == io.ErrUnexpectedEOF || errors.As(err, &netErr)
+}
+
+//RemoteOptions is a settings of RemoteCache
+type RemoteOptions struct {
+	DialTimeout       time.Duration //DefaultDialTimeout if zero
+	Timeout           time.Duration //timeout of one attempt of request, zero mean no timeout
+	Connections       int           //maximum number of connections, 1 if zero. Connection is added when others are busy
+	IdleTimeout       time.Duration //connection without requests is closed after this time, zero mean it is kept
+	HealthCheck       time.Duration //idle connection is checked by PING with this interval and timeout, zero disable checks
+	Retries           int           //retries of idempotent requests failed by timeout or broken connection
+	RetryBackoff      time.Duration //delay before first retry, it is doubled for next ones. DefaultRetryBackoff if zero
+	MaxFrameSize      int           //DefaultMaxFrameSize if zero
+	Compression       bool          //send and accept compressed values
+	CompressThreshold int           //DefaultCompressThreshold if zero
+	TLS               *tls.Config   //connect via tls if not nil, see ClientTLSConfig
+	Token             string        //every connection is authenticated by AUTH command if set
+	Codec             byte          //CodecProto by default, dial fails with ErrCodecNotSupported if server has no codec
+}
+
+//PoolStats is a state of connection pool of RemoteCache
+type PoolStats struct {
+	Open           int   //open connections
+	Busy           int   //connections with requests in flight
+	Dials          int64 //dialed connections
+	DialErrors     int64
+	IdleClosed     int64 //connections closed by IdleTimeout
+	HealthFailures int64 //connections closed by failed health check
+	Retries        int64 //requests sent again
+	Timeouts       int64 //attempts without responce in time
+}
+
+//RemoteCache is a client of tcp_long server, it implements Cacher
+//Many goroutines share pool of connections, every request has id so responces can come in any order.
+//Broken connection is dialed again on next request
+type RemoteCache struct {
+	pool *remotePool
+	ctx  context.Context
+}
+
+//remotePool is a bounded pool of multiplexed connections to one server
+type remotePool struct {
+	requestID uint64
+	address   string
+	opts      RemoteOptions
+
+	l       sync.Mutex
+	closed  bool
+	conns   []*remoteConn //broken connections are removed on next request
+	dialing int           //connections which are dialed without lock
+	dialed  chan struct{} //closed and replaced when dial is finished
+	stats   PoolStats
+	stop    chan struct{} //stop maintenance of pool
+}
+
+//remoteConn is one multiplexed connection
+type remoteConn struct {
+	conn   net.Conn
+	codec  codec
+	wl     sync.Mutex
+	writer *bufio.Writer
+	buf    []byte //marshaled request, guarded by wl
+
+	l        sync.Mutex
+	err      error //connection is broken if not nil
+	pending  map[uint64]chan *ItemMessage
+	reserved int       //requests which got connection from pool but are not finished yet
+	used     time.Time //start of last request except PING
+}
+
+//NewRemoteCache connect to tcp_long server, address is host:port or unix:///path/to/socket of unix mode,
+// unix://@name is a socket in abstract namespace. One connection is dialed at once, others on demand
+func NewRemoteCache(address string, opts RemoteOptions) (*RemoteCache, error) {
+	if opts.DialTimeout <= 0 {
+		opts.DialTimeout = DefaultDialTimeout
+	}
+	if opts.Connections <= 0 {
+		opts.Connections = 1
+	}
+	if opts.CompressThreshold <= 0 {
+		opts.CompressThreshold = DefaultCompressThreshold
+	}
+	if opts.RetryBackoff <= 0 {
+		opts.RetryBackoff = DefaultRetryBackoff
+	}
+	p := &remotePool{
+		address: address,
+		opts:    opts,
+		dialed:  make(chan struct{}),
+		stop:    make(chan struct{}),
+	}
+	rc, err := p.dial()
+	if err != nil {
+		return nil, err
+	}
+	p.conns = append(p.conns, rc)
+	p.stats.Dials++
+	if interval := p.maintenanceInterval(); interval > 0 {
+		go p.maintain(interval)
+	}
+	return &RemoteCache{pool: p, ctx: context.Background()}, nil
+}
+
+//WithContext return client which share connections with c, ctx limit requests including retries
+func (c *RemoteCache) WithContext(ctx context.Context) *RemoteCache {
+	return &RemoteCache{pool: c.pool, ctx: ctx}
+}
+
+func (p *remotePool) dial() (*remoteConn, error) {
+	var (
+		conn net.Conn
+		err  error
+	)
+	network, address := splitNetwork(p.address)
+	if p.opts.TLS != nil {
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: p.opts.DialTimeout}, network, address, p.opts.TLS)
+	} else {
+		conn, err = net.DialTimeout(network, address, p.opts.DialTimeout)
+	}
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(p.opts.DialTimeout))
+	cd, err := requestCodec(conn, p.opts.Codec)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	rc := &remoteConn{
+		conn:    conn,
+		codec:   cd,
+		writer:  bufio.NewWriter(conn),
+		pending: make(map[uint64]chan *ItemMessage),
+		used:    time.Now(),
+	}
+	go rc.readLoop(NewFrameReader(conn, p.opts.MaxFrameSize))
+	if p.opts.Token != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), p.opts.DialTimeout)
+		defer cancel()
+		if _, err = p.exchange(ctx, rc, &ItemMessage{Command: ItemMessage_AUTH, Token: p.opts.Token}); err != nil {
+			rc.fail(err)
+			return nil, err
+		}
+	}
+	return rc, nil
+}
+
+//conn return the least loaded connection, new connection is dialed in background when all connections are busy.
+//Without live connections it is dialed by caller without lock, callers over Connections wait the dial.
+//Returned connection is reserved so it is not closed as idle, caller should release it
+func (p *remotePool) conn() (*remoteConn, error) {
+	p.l.Lock()
+	for {
+		if p.closed {
+			p.l.Unlock()
+			return nil, ErrRemoteClosed
+		}
+		best, bestLoad := p.best()
+		if best != nil {
+			if bestLoad > 0 && len(p.conns)+p.dialing < p.opts.Connections {
+				p.dialing++
+				go p.grow()
+			}
+			best.reserve(1)
+			p.l.Unlock()
+			return best, nil
+		}
+		if p.dialing < p.opts.Connections {
+			p.dialing++
+			p.l.Unlock()
+			rc, err := p.dial()
+			p.l.Lock()
+			if rc, err = p.publish(rc, err); err == nil {
+				rc.reserve(1)
+			}
+			p.l.Unlock()
+			return rc, err
+		}
+		dialed := p.dialed
+		p.l.Unlock()
+		<-dialed
+		p.l.Lock()
+	}
+}
+
+//best remove broken connections and return the least loaded one, p.l should be locked
+func (p *remotePool) best() (*remoteConn, int) {
+	var (
+		best     *remoteConn
+		bestLoad int
+		live     = p.conns[:0]
+	)
+	for _, rc := range p.conns {
+		load, ok := rc.load()
+		if !ok {
+			continue
+		}
+		live = append(live, rc)
+		if best == nil || load < bestLoad {
+			best, bestLoad = rc, load
+		}
+	}
+	p.keep(live)
+	return best, bestLoad
+}
+
+//keep replace connections by live ones which are filtered in place
+func (p *remotePool) keep(live []*remoteConn) {
+	for i := len(live); i < len(p.conns); i++ {
+		p.conns[i] = nil
+	}
+	p.conns = live
+}
+
+//grow add connection dialed in background
+func (p *remotePool) grow() {
+	rc, err := p.dial()
+	p.l.Lock()
+	p.publish(rc, err)
+	p.l.Unlock()
+}
+
+//publish add dialed connection to pool and wake callers waiting the dial, p.l should be locked
+func (p *remotePool) publish(rc *remoteConn, err error) (*remoteConn, error) {
+	p.dialing--
+	close(p.dialed)
+	p.dialed = make(chan struct{})
+	if err != nil {
+		p.stats.DialErrors++
+		return nil, err
+	}
+	p.stats.Dials++
+	if p.closed {
+		rc.fail(ErrRemoteClosed)
+		return nil, ErrRemoteClosed
+	}
+	p.conns = append(p.conns, rc)
+	return rc, nil
+}
+
+//maintenanceInterval return interval of idle and health checks, zero if they are disabled
+func (p *remotePool) maintenanceInterval() time.Duration {
+	interval := p.opts.HealthCheck
+	if idle := p.opts.IdleTimeout; idle > 0 && (interval <= 0 || idle < interval) {
+		interval = idle
+	}
+	return interval
+}
+
+//maintain close idle connections and check health of others until Dead
+func (p *remotePool) maintain(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		}
+		for _, rc := range p.idle() {
+			p.check(rc)
+		}
+	}
+}
+
+//idle close connections without requests during IdleTimeout and return idle connections which should be checked
+func (p *remotePool) idle() []*remoteConn {
+	p.l.Lock()
+	defer p.l.Unlock()
+	var (
+		now   = time.Now()
+		check []*remoteConn
+		live  = p.conns[:0]
+	)
+	for _, rc := range p.conns {
+		idle, ok := rc.idle(now)
+		switch {
+		case !ok:
+		case p.opts.IdleTimeout > 0 && idle >= p.opts.IdleTimeout:
+			rc.fail(errIdle)
+			p.stats.IdleClosed++
+		default:
+			live = append(live, rc)
+			if p.opts.HealthCheck > 0 && idle >= p.opts.HealthCheck {
+				check = append(check, rc)
+			}
+		}
+	}
+	p.keep(live)
+	return check
+}
+
+//check send PING via connection, connection without any responce in HealthCheck is closed
+func (p *remotePool) check(rc *remoteConn) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.opts.HealthCheck)
+	defer cancel()
+	if resp, err := p.exchange(ctx, rc, &ItemMessage{Command: ItemMessage_PING}); resp == nil {
+		rc.fail(err)
+		p.count(&p.stats.HealthFailures)
+	}
+}
+
+//count increase counter of stats
+func (p *remotePool) count(counter *int64) {
+	p.l.Lock()
+	*counter++
+	p.l.Unlock()
+}
+
+//readLoop deliver responces to waiting requests.
+//Oversized responce has unknown request id, so connection is failed to release its request
+func (rc *remoteConn) readLoop(reader *FrameReader) {
+	for {
+		data, err := reader.ReadFrame()
+		if err != nil {
+			rc.fail(err)
+			return
+		}
+		resp := &ItemMessage{}
+		if err = rc.codec.unmarshal(data, resp); err != nil {
+			continue
+		}
+		rc.l.Lock()
+		ch, ok := rc.pending[resp.GetRequestID()]
+		delete(rc.pending, resp.GetRequestID())
+		rc.l.Unlock()
+		if ok {
+			ch <- resp
+		}
+	}
+}
+
+//fail close connection and release all waiting requests
+func (rc *remoteConn) fail(err error) {
+	rc.l.Lock()
+	defer rc.l.Unlock()
+	if rc.err != nil {
+		return
+	}
+	rc.err = err
+	rc.conn.Close()
+	for id, ch := range rc.pending {
+		close(ch)
+		delete(rc.pending, id)
+	}
+}
+
+//load return number of requests in flight, false if connection is broken
+func (rc *remoteConn) load() (int, bool) {
+	rc.l.Lock()
+	defer rc.l.Unlock()
+	return len(rc.pending) + rc.reserved, rc.err == nil
+}
+
+//reserve add delta to requests which use connection
+func (rc *remoteConn) reserve(delta int) {
+	rc.l.Lock()
+	rc.reserved += delta
+	rc.l.Unlock()
+}
+
+//idle return time since last request, it is zero while requests are in flight. False if connection is broken
+func (rc *remoteConn) idle(now time.Time) (time.Duration, bool) {
+	rc.l.Lock()
+	defer rc.l.Unlock()
+	if len(rc.pending) > 0 || rc.reserved > 0 {
+		return 0, rc.err == nil
+	}
+	return now.Sub(rc.used), rc.err == nil
+}
+
+//roundTrip send request and wait responce with the same id, failed status is returned as error.
+//Idempotent request is sent again after timeout or broken connection if Retries are set
+func (c *RemoteCache) roundTrip(req *ItemMessage) (*ItemMessage, error) {
+	p := c.pool
+	backoff := p.opts.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		resp, err := p.attempt(c.ctx, req)
+		if err == ErrRemoteTimeout {
+			p.count(&p.stats.Timeouts)
+		}
+		if resp != nil || attempt >= p.opts.Retries || !retryable(req.Command, err) || c.ctx.Err() != nil {
+			return resp, err
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-c.ctx.Done():
+			timer.Stop()
+			return nil, err
+		}
+		backoff *= 2
+		p.count(&p.stats.Retries)
+	}
+}
+
+//attempt send request via pooled connection, attempt is limited by Timeout
+func (p *remotePool) attempt(ctx context.Context, req *ItemMessage) (*ItemMessage, error) {
+	rc, err := p.conn()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.reserve(-1)
+	if p.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.opts.Timeout)
+		defer cancel()
+	}
+	return p.exchange(ctx, rc, req)
+}
+
+//exchange send request via connection and wait responce until ctx is done, deadline is reported as ErrRemoteTimeout.
+//Responce is nil if request failed without responce of server
+func (p *remotePool) exchange(ctx context.Context, rc *remoteConn, req *ItemMessage) (*ItemMessage, error) {
+	req.RequestID = atomic.AddUint64(&p.requestID, 1)
+	ch := make(chan *ItemMessage, 1)
+	rc.l.Lock()
+	if rc.err != nil {
+		rc.l.Unlock()
+		return nil, rc.err
+	}
+	rc.pending[req.RequestID] = ch
+	if req.Command != ItemMessage_PING { //health checks do not prolong idle connection
+		rc.used = time.Now()
+	}
+	rc.l.Unlock()
+
+	rc.wl.Lock()
+	data, err := rc.codec.appendMessage(rc.buf[:0], req)
+	if err != nil {
+		rc.wl.Unlock()
+		rc.l.Lock()
+		delete(rc.pending, req.RequestID)
+		rc.l.Unlock()
+		return nil, err
+	}
+	if err = WriteFrame(rc.writer, data); err == nil {
+		err = rc.writer.Flush()
+	}
+	if rc.buf = data; cap(data) > maxPooledBuffer {
+		rc.buf = nil
+	}
+	rc.wl.Unlock()
+	if err != nil {
+		rc.fail(err)
+		return nil, err
+	}
+
+	select {
+	case resp, ok := <-ch:
+		if !ok {
+			rc.l.Lock()
+			defer rc.l.Unlock()
+			return nil, rc.err
+		}
+		return resp, responceError(resp)
+	case <-ctx.Done():
+		rc.l.Lock()
+		delete(rc.pending, req.RequestID)
+		rc.l.Unlock()
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, ErrRemoteTimeout
+		}
+		return nil, ctx.Err()
+	}
+}
+
+//encode compress value if compression is enabled
+func (c *RemoteCache) encode(req *ItemMessage) *ItemMessage {
+	if c.pool.opts.Compression && req.Object != nil {
+		req.Object = CompressValue(req.Object, c.pool.opts.CompressThreshold)
+		req.Compressed = true
+	}
+	return req
+}
+
+//Get return value or nil if it is missing or server is unavailable
+func (c *RemoteCache) Get(name string) []byte {
+	if itm := c.GetItem(name); itm != nil {
+		return itm.Object
+	}
+	return nil
+}
+
+//GetItem return copy of item with expiration and version
+func (c *RemoteCache) GetItem(name string) *Item {
+	itm, _ := c.getItem(name)
+	return itm
+}
+
+//getItem is GetItem with error of request, missing item is ErrNotFound
+func (c *RemoteCache) getItem(name string) (*Item, error) {
+	resp, err := c.roundTrip(&ItemMessage{
+		Command:    ItemMessage_GET,
+		Name:       name,
+		Compressed: c.pool.opts.Compression,
+	})
+	if err != nil {
+		return nil, err
+	}
+	object := resp.GetObject()
+	if resp.GetCompressed() {
+		if object, err = DecompressValue(object); err != nil {
+			return nil, err
+		}
+	}
+	if object == nil {
+		object = []byte{}
+	}
+	return &Item{
+		Object:     object,
+		Expiration: resp.GetExpiration(),
+		Version:    resp.GetVersion(),
+	}, nil
+}
+
+//SetOrUpdate store value and wait acknowledge of server
+func (c *RemoteCache) SetOrUpdate(name string, value []byte, exp time.Duration) {
+	c.setOrUpdate(name, value, exp)
+}
+
+func (c *RemoteCache) setOrUpdate(name string, value []byte, exp time.Duration) error {
+	_, err := c.roundTrip(c.encode(&ItemMessage{
+		Command:    ItemMessage_SET,
+		Name:       name,
+		Object:     value,
+		Expiration: int64(exp),
+	}))
+	return err
+}
+
+//Incr atomically increase integer value on server
+func (c *RemoteCache) Incr(name string, delta int64, exp time.Duration) (int64, error) {
+	return c.incr(ItemMessage_INCR, name, delta, exp)
+}
+
+//Decr atomically decrease integer value on server
+func (c *RemoteCache) Decr(name string, delta int64, exp time.Duration) (int64, error) {
+	return c.incr(ItemMessage_DECR, name, delta, exp)
+}
+
+func (c *RemoteCache) incr(cmd ItemMessage_Commands, name string, delta int64, exp time.Duration) (int64, error) {
+	resp, err := c.roundTrip(&ItemMessage{
+		Command:    cmd,
+		Name:       name,
+		Delta:      delta,
+		Expiration: int64(exp),
+	})
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(string(resp.GetObject()), 10, 64)
+}
+
+//Add store value only if name is missing
+func (c *RemoteCache) Add(name string, value []byte, exp time.Duration) (uint64, error) {
+	return c.versioned(&ItemMessage{Command: ItemMessage_ADD, Name: name, Object: value, Expiration: int64(exp)})
+}
+
+//Replace store value only if name is present
+func (c *RemoteCache) Replace(name string, value []byte, exp time.Duration) (uint64, error) {
+	return c.versioned(&ItemMessage{Command: ItemMessage_REPLACE, Name: name, Object: value, Expiration: int64(exp)})
+}
+
+//CAS store value only if current version is equal to version
+func (c *RemoteCache) CAS(name string, value []byte, version uint64, exp time.Duration) (uint64, error) {
+	return c.versioned(&ItemMessage{Command: ItemMessage_CAS, Name: name, Object: value, Version: version, Expiration: int64(exp)})
+}
+
+//versioned send conditional write and return new version
+func (c *RemoteCache) versioned(req *ItemMessage) (uint64, error) {
+	resp, err := c.roundTrip(c.encode(req))
+	if err != nil {
+		return 0, err
+	}
+	return resp.GetVersion(), nil
+}
+
+//Delete remove item, false mean item is missing or server is unavailable
+func (c *RemoteCache) Delete(name string) bool {
+	return c.delete(name) == nil
+}
+
+func (c *RemoteCache) delete(name string) error {
+	_, err := c.roundTrip(&ItemMessage{Command: ItemMessage_DELETE, Name: name})
+	return err
+}
+
+//Keys return names of all items on server, nil if server is unavailable
+func (c *RemoteCache) Keys() []string {
+	keys, _ := c.keys()
+	return keys
+}
+
+func (c *RemoteCache) keys() ([]string, error) {
+	resp, err := c.roundTrip(&ItemMessage{Command: ItemMessage_KEYS})
+	if err != nil {
+		return nil, err
+	}
+	return resp.GetKeys(), nil
+}
+
+//Purge remove all items on server
+func (c *RemoteCache) Purge() {
+	c.purge()
+}
+
+func (c *RemoteCache) purge() error {
+	_, err := c.roundTrip(&ItemMessage{Command: ItemMessage_PURGE})
+	return err
+}
+
+//Dead close connections of client and clients made by WithContext,
+// server is not stopped because it can be shared with other clients
+func (c *RemoteCache) Dead() {
+	p := c.pool
+	p.l.Lock()
+	defer p.l.Unlock()
+	if p.closed {
+		return
+	}
+	p.closed = true
+	close(p.stop)
+	for _, rc := range p.conns {
+		rc.fail(ErrRemoteClosed)
+	}
+	p.conns = nil
+}
+
+//Ping check that server respond
+func (c *RemoteCache) Ping() error {
+	_, err := c.roundTrip(&ItemMessage{Command: ItemMessage_PING})
+	return err
+}
+
+//PoolStats return state of connection pool
+func (c *RemoteCache) PoolStats() PoolStats {
+	p := c.pool
+	p.l.Lock()
+	defer p.l.Unlock()
+	stats := p.stats
+	for _, rc := range p.conns {
+		if load, ok := rc.load(); ok {
+			stats.Open++
+			if load > 0 {
+				stats.Busy++
+			}
+		}
+	}
+	return stats
+}
+
+//Statistic return statistic of server cache, zero Stats if server is unavailable
+func (c *RemoteCache) Statistic() Stats {
+	stats, _ := c.statistic()
+	return stats
+}
+
+func (c *RemoteCache) statistic() (Stats, error) {
+	resp, err := c.roundTrip(&ItemMessage{Command: ItemMessage_STATS})
+	if err != nil {
+		return Stats{}, err
+	}
+	return statsFromMessage(resp.GetStats()), nil
+}
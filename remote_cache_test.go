@@ -0,0 +1,537 @@
+package gcache
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+//startLongTCP run tcp_long server on random local port
+func startLongTCP(t *testing.T, cache Cacher) *net.TCPListener {
+	ln, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go handleLongTCP(ln, cache, nil)
+	return ln
+}
+
+func TestRemoteCache_SetOrUpdate(t *testing.T) {
+	as := assert.New(t)
+	inner := NewRwCache(defaultConfig())
+	ln := startLongTCP(t, inner)
+	defer ln.Close()
+	big := bytes.Repeat([]byte(`zaza`), maxPacketSize)
+
+	for _, opts := range []RemoteOptions{{}, {Compression: true}} {
+		c, err := NewRemoteCache(ln.Addr().String(), opts)
+		if !as.NoError(err) {
+			return
+		}
+		c.SetOrUpdate("first", []byte(`zaza`), DefaultExpirationMarker)
+		c.SetOrUpdate("big", big, DefaultExpirationMarker)
+		as.Equal([]byte(`zaza`), c.Get("first"))
+		as.Equal(big, c.Get("big"), "value bigger than packet")
+		as.Nil(c.Get("irst"))
+		as.Equal(big, inner.Get("big"))
+
+		value, err := c.Incr("counter", 2, DefaultExpirationMarker)
+		as.NoError(err)
+		as.Equal(int64(2), value)
+		_, err = c.Incr("first", 2, DefaultExpirationMarker)
+		as.Equal(ErrNotInteger, err)
+
+		v, err := c.Add("added", []byte(`zaza`), time.Hour)
+		as.NoError(err)
+		itm := c.GetItem("added")
+		if as.NotNil(itm) {
+			as.Equal(v, itm.Version)
+			as.NotZero(itm.Expiration)
+		}
+		_, err = c.Add("added", []byte(`zaza`), time.Hour)
+		as.Equal(ErrExists, err)
+
+		as.Equal(int64(4), c.Statistic().ItemsCount)
+		as.True(c.Delete("added"))
+		as.False(c.Delete("added"))
+		c.Purge()
+		as.Zero(c.Statistic().ItemsCount)
+
+		c.Dead()
+		as.Nil(c.Get("first"))
+		_, err = c.Add("first", []byte(`zaza`), DefaultExpirationMarker)
+		as.Equal(ErrRemoteClosed, err)
+	}
+
+	inner.Dead() //Cleanup
+}
+
+func TestRemoteCache_CAS(t *testing.T) {
+	inner := NewRwCache(&ConfigMessage{})
+	ln := startLongTCP(t, inner)
+	defer ln.Close()
+
+	c, err := NewRemoteCache(ln.Addr().String(), RemoteOptions{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	checkVersionedCacher(t, c)
+	c.Dead()
+	inner.Dead() //Cleanup
+}
+
+func TestRemoteCache_Reconnect(t *testing.T) {
+	as := assert.New(t)
+	inner := NewRwCache(defaultConfig())
+	ln := startLongTCP(t, inner)
+	defer ln.Close()
+
+	c, err := NewRemoteCache(ln.Addr().String(), RemoteOptions{})
+	if !as.NoError(err) {
+		return
+	}
+	_, err = c.Add("first", []byte(`zaza`), DefaultExpirationMarker)
+	as.NoError(err)
+	c.pool.conns[0].conn.Close() //break connection under client
+	c.Get("first")               //can fail on broken connection
+	as.Equal([]byte(`zaza`), c.Get("first"), "next request dial again")
+
+	c.Dead()
+	inner.Dead() //Cleanup
+}
+
+func TestRemoteCache_FrameTooLarge(t *testing.T) {
+	as := assert.New(t)
+	inner := NewRwCache(defaultConfig())
+	ln := startLongTCP(t, inner)
+	defer ln.Close()
+
+	c, err := NewRemoteCache(ln.Addr().String(), RemoteOptions{MaxFrameSize: 400})
+	if !as.NoError(err) {
+		return
+	}
+	inner.SetOrUpdate("large", make([]byte, 1000), NoExpiration)
+	inner.SetOrUpdate("small", []byte(`zaza`), NoExpiration)
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.getItem("large")
+		done <- err
+	}()
+	select {
+	case err = <-done:
+		as.Equal(ErrFrameTooLarge, err)
+	case <-time.After(time.Second):
+		t.Fatal("Get of oversized responce is not returned")
+	}
+	as.Equal([]byte(`zaza`), c.Get("small"), "next request dial again")
+
+	c.Dead()
+	inner.Dead() //Cleanup
+}
+
+func TestRemoteCache_Multiplexed(t *testing.T) {
+	as := assert.New(t)
+	inner := NewRwCache(defaultConfig())
+	ln := startLongTCP(t, inner)
+	defer ln.Close()
+
+	c, err := NewRemoteCache(ln.Addr().String(), RemoteOptions{Connections: 3, Timeout: 5 * time.Second})
+	if !as.NoError(err) {
+		return
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			name := strconv.Itoa(i)
+			for j := 0; j < 20; j++ {
+				c.Incr("counter", 1, DefaultExpirationMarker)
+				c.SetOrUpdate(name, []byte(name), DefaultExpirationMarker)
+				as.Equal([]byte(name), c.Get(name))
+			}
+		}(i)
+	}
+	wg.Wait()
+	as.Equal([]byte(`1000`), c.Get("counter"))
+
+	c.Dead()
+	inner.Dead() //Cleanup
+}
+
+//startBlackHole accept connections and never respond
+func startBlackHole(t *testing.T) net.Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go io.Copy(ioutil.Discard, conn)
+		}
+	}()
+	return ln
+}
+
+//startBreakingProxy forward connections to address, the first connection is closed by first request
+func startBreakingProxy(t *testing.T, address string) net.Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for i := 0; ; i++ {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			if i == 0 {
+				go func() {
+					conn.Read(make([]byte, 1))
+					conn.Close()
+				}()
+				continue
+			}
+			upstream, err := net.Dial("tcp", address)
+			if err != nil {
+				conn.Close()
+				continue
+			}
+			go func() {
+				io.Copy(upstream, conn)
+				upstream.Close()
+			}()
+			go func() {
+				io.Copy(conn, upstream)
+				conn.Close()
+			}()
+		}
+	}()
+	return ln
+}
+
+//waitStats wait until stats of pool satisfy condition
+func waitStats(c *RemoteCache, condition func(PoolStats) bool) bool {
+	for i := 0; i < 1000; i++ {
+		if condition(c.PoolStats()) {
+			return true
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+	return false
+}
+
+func TestRemoteCache_Pool(t *testing.T) {
+	as := assert.New(t)
+	ln := startBlackHole(t)
+	defer ln.Close()
+
+	c, err := NewRemoteCache(ln.Addr().String(), RemoteOptions{Connections: 2, Timeout: 200 * time.Millisecond})
+	if !as.NoError(err) {
+		return
+	}
+	go c.Get("first")
+	as.True(waitStats(c, func(s PoolStats) bool { return s.Busy == 1 }))
+	go c.Get("second")
+	as.True(waitStats(c, func(s PoolStats) bool { return s.Dials == 2 }), "connection is added when others are busy")
+	go c.Get("third")
+	as.True(waitStats(c, func(s PoolStats) bool { return s.Busy == 2 }))
+	c.Get("fourth")
+	stats := c.PoolStats()
+	as.Equal(2, stats.Open, "pool is bounded")
+	as.Equal(int64(2), stats.Dials)
+	as.True(waitStats(c, func(s PoolStats) bool { return s.Timeouts == 4 && s.Busy == 0 }))
+	c.Dead()
+	as.Equal(PoolStats{Dials: 2, Timeouts: 4}, c.PoolStats())
+
+	inner := NewRwCache(defaultConfig())
+	server := startLongTCP(t, inner)
+	defer server.Close()
+	c, err = NewRemoteCache(server.Addr().String(), RemoteOptions{IdleTimeout: 50 * time.Millisecond})
+	if !as.NoError(err) {
+		return
+	}
+	c.SetOrUpdate("first", []byte(`zaza`), DefaultExpirationMarker)
+	as.True(waitStats(c, func(s PoolStats) bool { return s.Open == 0 && s.IdleClosed == 1 }), "idle connection is closed")
+	as.Equal([]byte(`zaza`), c.Get("first"), "connection is dialed again")
+	as.Equal(1, c.PoolStats().Open)
+	as.NoError(c.Ping())
+	c.Dead()
+	inner.Dead() //Cleanup
+}
+
+//hangingListener return the first connection, next connections are accepted and never answered
+type hangingListener struct {
+	net.Listener
+	accepted bool
+}
+
+func (l *hangingListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil || !l.accepted {
+			l.accepted = true
+			return conn, err
+		}
+		go io.Copy(ioutil.Discard, conn)
+	}
+}
+
+func TestRemoteCache_SlowDial(t *testing.T) {
+	as := assert.New(t)
+	inner := NewRwCache(defaultConfig())
+	defer inner.Dead() //Cleanup
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if !as.NoError(err) {
+		return
+	}
+	defer ln.Close()
+	go serveListener(modeTCPLong, &hangingListener{Listener: ln}, inner, nil)
+
+	c, err := NewRemoteCache(ln.Addr().String(), RemoteOptions{Codec: CodecBinary, DialTimeout: 500 * time.Millisecond, IdleTimeout: 20 * time.Millisecond})
+	if !as.NoError(err) {
+		return
+	}
+	as.True(waitStats(c, func(s PoolStats) bool { return s.Open == 0 && s.IdleClosed == 1 }))
+	done := make(chan struct{})
+	go func() {
+		c.Get("zaza") //codec hello is not answered until DialTimeout
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	start := time.Now()
+	c.PoolStats()
+	as.True(time.Since(start) < 100*time.Millisecond, "pool is not locked by dial")
+	c.Dead()
+	as.True(time.Since(start) < 100*time.Millisecond, "Dead does not wait dial")
+	<-done
+	as.Equal(PoolStats{Dials: 1, DialErrors: 1, IdleClosed: 1}, c.PoolStats())
+}
+
+func TestRemoteCache_IdleReserved(t *testing.T) {
+	as := assert.New(t)
+	inner := NewRwCache(defaultConfig())
+	defer inner.Dead() //Cleanup
+	server := startLongTCP(t, inner)
+	defer server.Close()
+	c, err := NewRemoteCache(server.Addr().String(), RemoteOptions{IdleTimeout: 10 * time.Millisecond})
+	if !as.NoError(err) {
+		return
+	}
+	defer c.Dead()
+
+	rc, err := c.pool.conn()
+	if !as.NoError(err) {
+		return
+	}
+	time.Sleep(50 * time.Millisecond) //maintenance runs between conn and exchange
+	_, err = c.pool.exchange(context.Background(), rc, &ItemMessage{Command: ItemMessage_SET, Name: "first", Object: []byte(`zaza`)})
+	as.NoError(err, "reserved connection is not closed as idle")
+	rc.reserve(-1)
+	as.Zero(c.PoolStats().IdleClosed)
+	as.True(waitStats(c, func(s PoolStats) bool { return s.IdleClosed == 1 }), "released connection is closed as idle")
+}
+
+func TestRemoteCache_HealthCheck(t *testing.T) {
+	as := assert.New(t)
+	inner := NewRwCache(defaultConfig())
+	server := startLongTCP(t, inner)
+	defer server.Close()
+	c, err := NewRemoteCache(server.Addr().String(), RemoteOptions{HealthCheck: 10 * time.Millisecond})
+	if !as.NoError(err) {
+		return
+	}
+	time.Sleep(100 * time.Millisecond)
+	stats := c.PoolStats()
+	as.Equal(1, stats.Open, "healthy connection is kept")
+	as.Equal(int64(1), stats.Dials)
+	as.Zero(stats.HealthFailures)
+	c.Dead()
+	inner.Dead() //Cleanup
+
+	ln := startBlackHole(t)
+	defer ln.Close()
+	c, err = NewRemoteCache(ln.Addr().String(), RemoteOptions{HealthCheck: 10 * time.Millisecond})
+	if !as.NoError(err) {
+		return
+	}
+	as.True(waitStats(c, func(s PoolStats) bool { return s.Open == 0 && s.HealthFailures == 1 }), "connection without responce is closed")
+	c.Dead()
+}
+
+func TestRemoteCache_Retry(t *testing.T) {
+	as := assert.New(t)
+	inner := NewRwCache(defaultConfig())
+	inner.SetOrUpdate("first", []byte(`zaza`), DefaultExpirationMarker)
+	server := startLongTCP(t, inner)
+	defer server.Close()
+
+	proxy := startBreakingProxy(t, server.Addr().String())
+	c, err := NewRemoteCache(proxy.Addr().String(), RemoteOptions{Retries: 1})
+	if !as.NoError(err) {
+		return
+	}
+	as.Equal([]byte(`zaza`), c.Get("first"), "request is sent again via new connection")
+	as.Equal(int64(1), c.PoolStats().Retries)
+	c.Dead()
+	proxy.Close()
+
+	proxy = startBreakingProxy(t, server.Addr().String())
+	c, err = NewRemoteCache(proxy.Addr().String(), RemoteOptions{Retries: 1})
+	if !as.NoError(err) {
+		return
+	}
+	_, err = c.Incr("counter", 1, DefaultExpirationMarker)
+	as.Error(err, "not idempotent request is not sent again")
+	as.Zero(c.PoolStats().Retries)
+	c.Dead()
+	proxy.Close()
+
+	ln := startBlackHole(t)
+	defer ln.Close()
+	c, err = NewRemoteCache(ln.Addr().String(), RemoteOptions{Timeout: 20 * time.Millisecond, Retries: 2, RetryBackoff: time.Millisecond})
+	if !as.NoError(err) {
+		return
+	}
+	as.Equal(ErrRemoteTimeout, c.Ping())
+	stats := c.PoolStats()
+	as.Equal(int64(2), stats.Retries)
+	as.Equal(int64(3), stats.Timeouts)
+	_, err = c.Add("first", []byte(`zaza`), DefaultExpirationMarker)
+	as.Equal(ErrRemoteTimeout, err)
+	as.Equal(int64(2), c.PoolStats().Retries)
+	c.Dead()
+	inner.Dead() //Cleanup
+}
+
+func TestRemoteCache_Context(t *testing.T) {
+	as := assert.New(t)
+	ln := startBlackHole(t)
+	defer ln.Close()
+	c, err := NewRemoteCache(ln.Addr().String(), RemoteOptions{Retries: 100})
+	if !as.NoError(err) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	as.Equal(ErrRemoteTimeout, c.WithContext(ctx).Ping(), "deadline limit all retries")
+	as.True(time.Since(start) < time.Second)
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	as.Equal(context.Canceled, c.WithContext(ctx).Ping())
+	c.WithContext(ctx).Dead()
+	as.Equal(ErrRemoteClosed, c.Ping(), "clients share pool")
+}
+
+func TestHandleLongTCP_RequestID(t *testing.T) {
+	as := assert.New(t)
+	inner := NewRwCache(defaultConfig())
+	ln := startLongTCP(t, inner)
+	defer ln.Close()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if !as.NoError(err) {
+		return
+	}
+	defer conn.Close()
+	var data []byte
+	for i := 1; i <= 10; i++ {
+		msg, _ := proto.Marshal(&ItemMessage{Command: ItemMessage_SET, Name: strconv.Itoa(i), RequestID: uint64(i)})
+		data = AppendFrame(data, msg)
+	}
+	conn.Write(data)
+
+	f := NewFrameReader(conn, 0)
+	ids := map[uint64]bool{}
+	for i := 0; i < 10; i++ {
+		frame, err := f.ReadFrame()
+		if !as.NoError(err) {
+			return
+		}
+		resp := &ItemMessage{}
+		as.NoError(proto.Unmarshal(frame, resp))
+		as.Equal(strconv.Itoa(int(resp.GetRequestID())), resp.GetName(), "responce match request")
+		ids[resp.GetRequestID()] = true
+	}
+	as.Len(ids, 10, "every request with id has responce")
+	as.Equal(int64(10), inner.Statistic().ItemsCount)
+
+	inner.Dead() //Cleanup
+}
+
+func TestHandleLongTCP_Pipelined(t *testing.T) {
+	as := assert.New(t)
+	inner := NewRwCache(defaultConfig())
+	ln := startLongTCP(t, inner)
+	defer ln.Close()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if !as.NoError(err) {
+		return
+	}
+	defer conn.Close()
+	var data []byte
+	for _, req := range []*ItemMessage{
+		{Command: ItemMessage_SET, Name: "first", Object: []byte(`zaza`)},
+		{Command: ItemMessage_GET, Name: "first"},
+		{Command: ItemMessage_INCR, Name: "counter", Delta: 1},
+	} {
+		msg, _ := proto.Marshal(req)
+		data = AppendFrame(data, msg)
+	}
+	data = append(data, AppendFrame(nil, make([]byte, DefaultMaxFrameSize+1))...)
+	msg, _ := proto.Marshal(&ItemMessage{Command: ItemMessage_GET, Name: "counter"})
+	data = AppendFrame(data, msg)
+	go func() {
+		for len(data) > 0 { //split stream in small writes
+			n := 3
+			if len(data) > 1<<20 {
+				n = 1 << 20
+			}
+			if n > len(data) {
+				n = len(data)
+			}
+			conn.Write(data[:n])
+			data = data[n:]
+		}
+	}()
+
+	f := NewFrameReader(conn, 0)
+	for _, expected := range []struct {
+		status ItemMessage_Statuses
+		object string
+	}{
+		{ItemMessage_OK, ""},
+		{ItemMessage_OK, "zaza"},
+		{ItemMessage_OK, "1"},
+		{ItemMessage_TOO_LARGE, ""},
+		{ItemMessage_OK, "1"},
+	} {
+		frame, err := f.ReadFrame()
+		if !as.NoError(err) {
+			return
+		}
+		resp := &ItemMessage{}
+		as.NoError(proto.Unmarshal(frame, resp))
+		as.Equal(expected.status, resp.GetStatus())
+		as.Equal(expected.object, string(resp.GetObject()))
+	}
+
+	inner.Dead() //Cleanup
+}
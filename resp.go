@@ -0,0 +1,743 @@
+package gcache
+
+import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	respMaxArgs         = 1 << 20
+	respPreallocArgs    = 16       //args are not preallocated by count of client
+	respMaxLineLength   = 64 << 10 //64Kb
+	respDefaultScanSize = 10
+	//respCompatVersion is reported as redis_version because client libraries check it
+	respCompatVersion = "7.0.0"
+)
+
+var (
+	errRESPProtocol = errors.New("Protocol error")
+	errRESPQuit     = errors.New("Quit")
+)
+
+//respCommands map redis commands to cache commands to check permissions, other commands are always allowed
+var respCommands = map[string]ItemMessage_Commands{
+	"GET":      ItemMessage_GET,
+	"EXISTS":   ItemMessage_GET,
+	"TTL":      ItemMessage_GET,
+	"MGET":     ItemMessage_GET,
+	"SET":      ItemMessage_SET,
+	"EXPIRE":   ItemMessage_SET,
+	"MSET":     ItemMessage_SET,
+	"DEL":      ItemMessage_DELETE,
+	"INCR":     ItemMessage_INCR,
+	"FLUSHDB":  ItemMessage_PURGE,
+	"FLUSHALL": ItemMessage_PURGE,
+	"INFO":     ItemMessage_STATS,
+	"SCAN":     ItemMessage_KEYS,
+}
+
+//handleRESP serve redis protocol
+func handleRESP(ln net.Listener, cache Cacher, sec *Security) {
+	serveListener(modeRESP, ln, cache, sec)
+}
+
+//respConn is a state of one RESP connection, HELLO 3 switch it to RESP3 replies
+type respConn struct {
+	r     *bufio.Reader
+	w     *bufio.Writer
+	cache Cacher
+	sess  *session
+	proto int
+}
+
+//serveRESP handle redis commands until QUIT or end of stream, nil session allow all commands
+func serveRESP(rw io.ReadWriter, cache Cacher, sess *session) {
+	c := &respConn{
+		r:     bufio.NewReaderSize(rw, respMaxLineLength),
+		w:     bufio.NewWriter(rw),
+		cache: cache,
+		sess:  sess,
+		proto: 2,
+	}
+	defer c.w.Flush()
+	for {
+		args, err := c.readCommand()
+		if err == errRESPProtocol {
+			c.error("ERR Protocol error: invalid request")
+			return
+		}
+		if err != nil {
+			return
+		}
+		if len(args) == 0 {
+			continue //empty inline command
+		}
+		if c.handle(args) == errRESPQuit {
+			return
+		}
+		if c.r.Buffered() == 0 { //flush only after all pipelined commands are handled
+			if c.w.Flush() != nil {
+				return
+			}
+		}
+	}
+}
+
+func (c *respConn) readLine() ([]byte, error) {
+	line, err := c.r.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		return nil, errRESPProtocol
+	}
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(line, "\r\n"), nil
+}
+
+//readCommand read array of bulk strings or inline command
+func (c *respConn) readCommand() ([][]byte, error) {
+	line, err := c.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 || line[0] != '*' {
+		return bytes.Fields(append([]byte(nil), line...)), nil
+	}
+	n, err := strconv.Atoi(string(line[1:]))
+	if err != nil || n < 0 || n > respMaxArgs { //null array is not a command
+		return nil, errRESPProtocol
+	}
+	prealloc := n
+	if prealloc > respPreallocArgs {
+		prealloc = respPreallocArgs
+	}
+	args := make([][]byte, 0, prealloc)
+	for i := 0; i < n; i++ {
+		if line, err = c.readLine(); err != nil {
+			return nil, err
+		}
+		if len(line) == 0 || line[0] != '$' {
+			return nil, errRESPProtocol
+		}
+		size, err := strconv.Atoi(string(line[1:]))
+		if err != nil || size < 0 || size > DefaultMaxFrameSize {
+			return nil, errRESPProtocol
+		}
+		arg := make([]byte, size+2)
+		if _, err = io.ReadFull(c.r, arg); err != nil {
+			return nil, err
+		}
+		if arg[size] != '\r' || arg[size+1] != '\n' {
+			return nil, errRESPProtocol
+		}
+		args = append(args, arg[:size])
+	}
+	return args, nil
+}
+
+func (c *respConn) simple(s string) {
+	c.w.WriteString("+" + s + "\r\n")
+}
+
+func (c *respConn) error(s string) {
+	c.w.WriteString("-" + s + "\r\n")
+}
+
+func (c *respConn) integer(n int64) {
+	c.w.WriteString(":" + strconv.FormatInt(n, 10) + "\r\n")
+}
+
+func (c *respConn) bulk(b []byte) {
+	c.w.WriteString("$" + strconv.Itoa(len(b)) + "\r\n")
+	c.w.Write(b)
+	c.w.WriteString("\r\n")
+}
+
+func (c *respConn) null() {
+	if c.proto == 3 {
+		c.w.WriteString("_\r\n")
+	} else {
+		c.w.WriteString("$-1\r\n")
+	}
+}
+
+func (c *respConn) array(n int) {
+	c.w.WriteString("*" + strconv.Itoa(n) + "\r\n")
+}
+
+//dict write map header, RESP2 use flat array of key value pairs
+func (c *respConn) dict(n int) {
+	if c.proto == 3 {
+		c.w.WriteString("%" + strconv.Itoa(n) + "\r\n")
+	} else {
+		c.array(2 * n)
+	}
+}
+
+func (c *respConn) wrongArgs(cmd string) {
+	c.error("ERR wrong number of arguments for '" + strings.ToLower(cmd) + "' command")
+}
+
+func (c *respConn) cacheError(err error) {
+	switch err {
+	case ErrNotInteger:
+		c.error("ERR value is not an integer or out of range")
+		return
+	case ErrOverflow:
+		c.error("ERR increment or decrement would overflow")
+		return
+	}
+	c.error("ERR " + err.Error())
+}
+
+//handle execute one command, errRESPQuit mean connection should be closed
+func (c *respConn) handle(args [][]byte) error {
+	cmd := strings.ToUpper(string(args[0]))
+	args = args[1:]
+	if !c.authorize(cmd, args) {
+		return nil
+	}
+	switch cmd {
+	case "AUTH":
+		if len(args) != 1 && len(args) != 2 {
+			c.wrongArgs(cmd)
+		} else if c.auth(args[:len(args)-1], args[len(args)-1]) {
+			c.simple("OK")
+		}
+	case "PING":
+		switch len(args) {
+		case 0:
+			c.simple("PONG")
+		case 1:
+			c.bulk(args[0])
+		default:
+			c.wrongArgs(cmd)
+		}
+	case "GET":
+		if len(args) != 1 {
+			c.wrongArgs(cmd)
+			break
+		}
+		if value := c.cache.Get(string(args[0])); value != nil {
+			c.bulk(value)
+		} else {
+			c.null()
+		}
+	case "SET":
+		if len(args) < 2 {
+			c.wrongArgs(cmd)
+			break
+		}
+		c.set(string(args[0]), args[1], args[2:])
+	case "DEL":
+		if len(args) == 0 {
+			c.wrongArgs(cmd)
+			break
+		}
+		var n int64
+		for _, key := range args {
+			if c.cache.Delete(string(key)) {
+				n++
+			}
+		}
+		c.integer(n)
+	case "EXISTS":
+		if len(args) == 0 {
+			c.wrongArgs(cmd)
+			break
+		}
+		var n int64
+		for _, key := range args {
+			if c.cache.GetItem(string(key)) != nil {
+				n++
+			}
+		}
+		c.integer(n)
+	case "EXPIRE":
+		if len(args) != 2 {
+			c.wrongArgs(cmd)
+			break
+		}
+		c.expire(string(args[0]), string(args[1]))
+	case "TTL":
+		if len(args) != 1 {
+			c.wrongArgs(cmd)
+			break
+		}
+		itm := c.cache.GetItem(string(args[0]))
+		switch {
+		case itm == nil:
+			c.integer(-2)
+		case itm.Expiration == 0:
+			c.integer(-1)
+		default:
+			ttl := time.Until(time.Unix(0, itm.Expiration))
+			c.integer(int64((ttl + time.Second/2) / time.Second))
+		}
+	case "INCR":
+		if len(args) != 1 {
+			c.wrongArgs(cmd)
+			break
+		}
+		value, err := c.cache.Incr(string(args[0]), 1, NoExpiration)
+		if err != nil {
+			c.cacheError(err)
+			break
+		}
+		c.integer(value)
+	case "MGET":
+		if len(args) == 0 {
+			c.wrongArgs(cmd)
+			break
+		}
+		c.array(len(args))
+		for _, key := range args {
+			if value := c.cache.Get(string(key)); value != nil {
+				c.bulk(value)
+			} else {
+				c.null()
+			}
+		}
+	case "MSET":
+		if len(args) == 0 || len(args)%2 != 0 {
+			c.wrongArgs(cmd)
+			break
+		}
+		for i := 0; i < len(args); i += 2 {
+			c.cache.SetOrUpdate(string(args[i]), args[i+1], NoExpiration)
+		}
+		c.simple("OK")
+	case "FLUSHDB", "FLUSHALL":
+		if len(args) > 1 {
+			c.wrongArgs(cmd)
+			break
+		}
+		c.cache.Purge()
+		c.simple("OK")
+	case "INFO":
+		c.bulk(respInfo(c.cache.Statistic(), args))
+	case "SCAN":
+		if len(args) == 0 {
+			c.wrongArgs(cmd)
+			break
+		}
+		c.scan(args)
+	case "HELLO":
+		c.hello(args)
+	case "SELECT":
+		if len(args) != 1 {
+			c.wrongArgs(cmd)
+		} else if string(args[0]) != "0" {
+			c.error("ERR DB index is out of range")
+		} else {
+			c.simple("OK")
+		}
+	case "COMMAND":
+		c.array(0) //command docs are not provided
+	case "CLIENT":
+		if len(args) > 0 && (strings.EqualFold(string(args[0]), "SETNAME") || strings.EqualFold(string(args[0]), "SETINFO")) {
+			c.simple("OK")
+		} else {
+			c.error("ERR unsupported CLIENT subcommand")
+		}
+	case "QUIT":
+		c.simple("OK")
+		return errRESPQuit
+	default:
+		c.error("ERR unknown command '" + strings.ToLower(cmd) + "'")
+	}
+	return nil
+}
+
+//set handle SET key value [NX|XX] [EX seconds|PX milliseconds]
+func (c *respConn) set(key string, value []byte, opts [][]byte) {
+	var (
+		exp      = NoExpiration
+		nx, xx   bool
+		expFound bool
+	)
+	for i := 0; i < len(opts); i++ {
+		switch opt := strings.ToUpper(string(opts[i])); opt {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "EX", "PX":
+			if expFound || i+1 == len(opts) {
+				c.error("ERR syntax error")
+				return
+			}
+			i++
+			n, err := strconv.ParseInt(string(opts[i]), 10, 64)
+			if err != nil {
+				c.error("ERR value is not an integer or out of range")
+				return
+			}
+			if n <= 0 {
+				c.error("ERR invalid expire time in 'set' command")
+				return
+			}
+			expFound = true
+			if opt == "EX" {
+				exp = time.Duration(n) * time.Second
+			} else {
+				exp = time.Duration(n) * time.Millisecond
+			}
+		default:
+			c.error("ERR syntax error")
+			return
+		}
+	}
+	var err error
+	switch {
+	case nx && xx:
+		c.error("ERR syntax error")
+		return
+	case nx:
+		_, err = c.cache.Add(key, value, exp)
+	case xx:
+		_, err = c.cache.Replace(key, value, exp)
+	default:
+		c.cache.SetOrUpdate(key, value, exp)
+	}
+	switch err {
+	case nil:
+		c.simple("OK")
+	case ErrExists, ErrNotFound:
+		c.null()
+	default:
+		c.cacheError(err)
+	}
+}
+
+//expire handle EXPIRE key seconds, not positive seconds delete the key
+func (c *respConn) expire(key, seconds string) {
+	n, err := strconv.ParseInt(seconds, 10, 64)
+	if err != nil {
+		c.error("ERR value is not an integer or out of range")
+		return
+	}
+	var ok bool
+	if n <= 0 {
+		ok = c.cache.Delete(key)
+	} else {
+		ok, err = touchItem(c.cache, key, time.Duration(n)*time.Second)
+	}
+	switch {
+	case err != nil:
+		c.cacheError(err)
+	case ok:
+		c.integer(1)
+	default:
+		c.integer(0)
+	}
+}
+
+//scan handle SCAN cursor [MATCH pattern] [COUNT count] [TYPE type]
+//Cursor is a hash of next key, so keys which exist during whole iteration are returned at least once.
+//Only COUNT keys after cursor are kept and sorted for page, see scanPage
+func (c *respConn) scan(args [][]byte) {
+	cursor, err := strconv.ParseUint(string(args[0]), 10, 64)
+	if err != nil {
+		c.error("ERR invalid cursor")
+		return
+	}
+	var (
+		pattern    string
+		count      = respDefaultScanSize
+		onlyString = true
+	)
+	for i := 1; i < len(args); i += 2 {
+		if i+1 == len(args) {
+			c.error("ERR syntax error")
+			return
+		}
+		switch strings.ToUpper(string(args[i])) {
+		case "MATCH":
+			pattern = string(args[i+1])
+		case "COUNT":
+			if count, err = strconv.Atoi(string(args[i+1])); err != nil || count < 1 {
+				c.error("ERR syntax error")
+				return
+			}
+		case "TYPE":
+			onlyString = strings.EqualFold(string(args[i+1]), "string")
+		default:
+			c.error("ERR syntax error")
+			return
+		}
+	}
+
+	page := &scanPage{limit: count + 1} //one more key is the next cursor
+	for _, key := range c.cache.Keys() {
+		if hash := calcFNVInline(key); hash >= cursor {
+			page.add(hashedKey{hash, key})
+		}
+	}
+	keys := page.sorted()
+	var next uint64
+	if len(keys) > count {
+		next = keys[count].hash
+		keys = keys[:count]
+	}
+	found := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if onlyString && (pattern == "" || globMatch(pattern, k.key)) {
+			found = append(found, k.key)
+		}
+	}
+	c.array(2)
+	c.bulk([]byte(strconv.FormatUint(next, 10)))
+	c.array(len(found))
+	for _, key := range found {
+		c.bulk([]byte(key))
+	}
+}
+
+//hashedKey is a key of SCAN with its cursor
+type hashedKey struct {
+	hash uint64
+	key  string
+}
+
+func (k hashedKey) less(other hashedKey) bool {
+	if k.hash != other.hash {
+		return k.hash < other.hash
+	}
+	return k.key < other.key
+}
+
+//scanPage keep limit smallest keys in max heap, so page of SCAN does not sort all keys after cursor
+type scanPage struct {
+	limit int
+	keys  []hashedKey
+}
+
+func (p *scanPage) Len() int           { return len(p.keys) }
+func (p *scanPage) Less(i, j int) bool { return p.keys[j].less(p.keys[i]) }
+func (p *scanPage) Swap(i, j int)      { p.keys[i], p.keys[j] = p.keys[j], p.keys[i] }
+func (p *scanPage) Push(x interface{}) { p.keys = append(p.keys, x.(hashedKey)) }
+func (p *scanPage) Pop() interface{} {
+	k := p.keys[len(p.keys)-1]
+	p.keys = p.keys[:len(p.keys)-1]
+	return k
+}
+
+//add key if it is smaller than the biggest one of full page
+func (p *scanPage) add(k hashedKey) {
+	switch {
+	case len(p.keys) < p.limit:
+		heap.Push(p, k)
+	case k.less(p.keys[0]):
+		p.keys[0] = k
+		heap.Fix(p, 0)
+	}
+}
+
+//sorted return keys in order of cursor
+func (p *scanPage) sorted() []hashedKey {
+	keys := make([]hashedKey, len(p.keys))
+	for i := len(keys) - 1; i >= 0; i-- {
+		keys[i] = heap.Pop(p).(hashedKey)
+	}
+	return keys
+}
+
+//hello handle HELLO [protover [AUTH username password] [SETNAME clientname]]
+func (c *respConn) hello(args [][]byte) {
+	proto := c.proto
+	if len(args) > 0 {
+		v, err := strconv.Atoi(string(args[0]))
+		if err != nil || v < 2 || v > 3 {
+			c.error("NOPROTO unsupported protocol version")
+			return
+		}
+		proto = v
+	}
+	for i := 1; i < len(args); i++ {
+		option := strings.ToUpper(string(args[i]))
+		switch {
+		case option == "AUTH" && i+2 < len(args):
+			if !c.auth(args[i+1:i+2], args[i+2]) {
+				return
+			}
+			i += 2
+		case option == "SETNAME" && i+1 < len(args):
+			i++
+		default:
+			c.error("ERR Syntax error in HELLO option '" + strings.ToLower(option) + "'")
+			return
+		}
+	}
+	c.proto = proto
+	c.dict(6)
+	c.bulk([]byte("server"))
+	c.bulk([]byte("gcache"))
+	c.bulk([]byte("version"))
+	c.bulk([]byte(ServerVersion))
+	c.bulk([]byte("proto"))
+	c.integer(int64(c.proto))
+	c.bulk([]byte("mode"))
+	c.bulk([]byte("standalone"))
+	c.bulk([]byte("role"))
+	c.bulk([]byte("master"))
+	c.bulk([]byte("modules"))
+	c.array(0)
+}
+
+//authorize check permission of command for every key, false mean error is written
+//Commands without keys are reported as wrong number of arguments after authorization
+func (c *respConn) authorize(cmd string, args [][]byte) bool {
+	command, ok := respCommands[cmd]
+	if !ok || !c.sess.controlled() {
+		return true
+	}
+	var err error
+	if unnamedCommands[command] {
+		err = c.sess.allow(command, "")
+		args = nil
+	}
+	for i := 0; i < len(args) && err == nil; i++ {
+		err = c.sess.allow(command, string(args[i]))
+		switch cmd {
+		case "MSET":
+			i++ //skip value
+		case "GET", "SET", "EXPIRE", "TTL", "INCR":
+			i = len(args) //only first argument is a key
+		}
+	}
+	switch err {
+	case nil:
+		return true
+	case ErrUnauthorized:
+		c.error("NOAUTH Authentication required.")
+	default:
+		c.error("NOPERM this user has no permissions to run the '" + strings.ToLower(cmd) + "' command or to access its keys")
+	}
+	return false
+}
+
+//auth switch session to principal of token, optional user must be the name of principal, false mean error is written
+func (c *respConn) auth(user [][]byte, token []byte) bool {
+	if !c.sess.controlled() {
+		c.error("ERR AUTH <password> called without any password configured for the default user. Are you sure your configuration is correct?")
+		return false
+	}
+	name := ""
+	if len(user) == 1 {
+		name = string(user[0])
+	}
+	if _, err := c.sess.authenticateUser(name, string(token)); err != nil {
+		c.error("WRONGPASS invalid username-password pair or user is disabled.")
+		return false
+	}
+	return true
+}
+
+//respInfo build INFO text for requested sections, all sections by default
+func respInfo(s Stats, args [][]byte) []byte {
+	sections := map[string]bool{}
+	for _, arg := range args {
+		sections[strings.ToLower(string(arg))] = true
+	}
+	all := len(sections) == 0 || sections["all"] || sections["everything"] || sections["default"]
+	var b bytes.Buffer
+	if all || sections["server"] {
+		fmt.Fprintf(&b, "# Server\r\nredis_version:%s\r\ngcache_version:%s\r\nredis_mode:standalone\r\nprocess_id:%d\r\nuptime_in_seconds:%d\r\n\r\n",
+			respCompatVersion, ServerVersion, os.Getpid(), int64(time.Since(processStart)/time.Second))
+	}
+	if all || sections["stats"] {
+		fmt.Fprintf(&b, "# Stats\r\nkeyspace_hits:%d\r\nkeyspace_misses:%d\r\nexpired_keys:%d\r\n\r\n",
+			s.GetSuccessNumber, s.GetErrorNumber, s.DeleteExpired)
+	}
+	if all || sections["keyspace"] {
+		fmt.Fprintf(&b, "# Keyspace\r\ndb0:keys=%d,expires=0,avg_ttl=0\r\n", s.ItemsCount)
+	}
+	return b.Bytes()
+}
+
+//globMatch match name with redis glob pattern: * ? [abc] [^a] [a-z] and \ escape
+func globMatch(pattern, name string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 0 && pattern[0] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := 0; i <= len(name); i++ {
+				if globMatch(pattern, name[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(name) == 0 {
+				return false
+			}
+		case '[':
+			if len(name) == 0 {
+				return false
+			}
+			end := strings.IndexByte(pattern[1:], ']')
+			if end < 0 { //not closed class is a literal
+				if name[0] != '[' {
+					return false
+				}
+				break
+			}
+			class := pattern[1 : end+1]
+			if !matchClass(class, name[0]) {
+				return false
+			}
+			pattern = pattern[end+1:]
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if len(name) == 0 || name[0] != pattern[0] {
+				return false
+			}
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+func matchClass(class string, ch byte) bool {
+	negate := len(class) > 0 && class[0] == '^'
+	if negate {
+		class = class[1:]
+	}
+	matched := false
+	for i := 0; i < len(class); i++ {
+		if i+2 < len(class) && class[i+1] == '-' {
+			lo, hi := class[i], class[i+2]
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			if ch >= lo && ch <= hi {
+				matched = true
+			}
+			i += 2
+			continue
+		}
+		if class[i] == ch {
+			matched = true
+		}
+	}
+	return matched != negate
+}
@@ -0,0 +1,163 @@
+package gcache
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRESP_Transcripts(t *testing.T) {
+	runTranscripts(t, "testdata/resp", nil, serveRESP)
+}
+
+func TestRESP_AuthTranscripts(t *testing.T) {
+	runTranscripts(t, "testdata/resp-acl", &Security{ACL: testACL()}, serveRESP)
+
+	c := NewRwCache(&ConfigMessage{})
+	assert.Contains(t, respRequest(c, "AUTH secret"), "-ERR AUTH <password> called without any password configured")
+	c.Dead() //Cleanup
+}
+
+//respRequest run commands on fresh connection state and return raw replies
+func respRequest(c Cacher, commands ...string) string {
+	var out bytes.Buffer
+	serveRESP(struct {
+		io.Reader
+		io.Writer
+	}{strings.NewReader(strings.Join(commands, "\r\n") + "\r\n"), &out}, c, nil)
+	return out.String()
+}
+
+func TestScanPage(t *testing.T) {
+	as := assert.New(t)
+	var all []hashedKey
+	for i := 0; i < 1000; i++ {
+		key := "key:" + strconv.Itoa(i)
+		all = append(all, hashedKey{calcFNVInline(key) % 300, key}) //equal hashes are ordered by key
+	}
+	for _, limit := range []int{1, 8, 2000} {
+		page := &scanPage{limit: limit}
+		for _, k := range all {
+			page.add(k)
+		}
+		expected := append([]hashedKey(nil), all...)
+		sort.Slice(expected, func(i, j int) bool { return expected[i].less(expected[j]) })
+		if len(expected) > limit {
+			expected = expected[:limit]
+		}
+		as.Equal(expected, page.sorted(), "limit %d", limit)
+	}
+}
+
+func TestRESP_Scan(t *testing.T) {
+	as := assert.New(t)
+	c := NewRwCache(&ConfigMessage{})
+	expected := map[string]bool{}
+	for i := 0; i < 95; i++ {
+		name := "user:" + strconv.Itoa(i)
+		c.SetOrUpdate(name, []byte(`zaza`), NoExpiration)
+		expected[name] = true
+	}
+	c.SetOrUpdate("other", []byte(`zaza`), NoExpiration)
+
+	found := map[string]bool{}
+	cursor := "0"
+	for pages := 0; pages < 100; pages++ {
+		r := bufio.NewReader(strings.NewReader(respRequest(c, "SCAN "+cursor+" MATCH user:* COUNT 7")))
+		as.Equal("*2\r\n", readRESPLine(r))
+		readRESPLine(r) //cursor length
+		cursor = strings.TrimSuffix(readRESPLine(r), "\r\n")
+		n, _ := strconv.Atoi(strings.TrimPrefix(strings.TrimSuffix(readRESPLine(r), "\r\n"), "*"))
+		as.True(n <= 7)
+		for i := 0; i < n; i++ {
+			readRESPLine(r) //key length
+			found[strings.TrimSuffix(readRESPLine(r), "\r\n")] = true
+		}
+		if cursor == "0" {
+			break
+		}
+	}
+	as.Equal("0", cursor, "iteration is finished")
+	as.Equal(expected, found)
+
+	as.Equal("*2\r\n$1\r\n0\r\n*0\r\n", respRequest(c, "SCAN 0 TYPE hash COUNT 1000"))
+	as.Equal("-ERR invalid cursor\r\n", respRequest(c, "SCAN zaza"))
+
+	c.Dead() //Cleanup
+}
+
+func readRESPLine(r *bufio.Reader) string {
+	line, _ := r.ReadString('\n')
+	return line
+}
+
+func TestRESP_Info(t *testing.T) {
+	as := assert.New(t)
+	c := NewRwCache(&ConfigMessage{})
+	c.SetOrUpdate("first", []byte(`zaza`), NoExpiration)
+
+	info := respRequest(c, "INFO")
+	as.Contains(info, "redis_version:"+respCompatVersion+"\r\n")
+	as.Contains(info, "db0:keys=1,")
+	keyspace := respRequest(c, "INFO keyspace")
+	as.NotContains(keyspace, "# Server")
+	as.Contains(keyspace, "# Keyspace")
+
+	c.Dead() //Cleanup
+}
+
+func TestRESP_GlobMatch(t *testing.T) {
+	as := assert.New(t)
+	for _, tc := range []struct {
+		pattern, name string
+		match         bool
+	}{
+		{"*", "", true},
+		{"user:*", "user:1", true},
+		{"user:*", "users", false},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h[ae]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-c]llo", "hbllo", true},
+		{"*a*b", "xxaxxb", true},
+		{"*a*b", "xxaxxbc", false},
+		{`h\*llo`, "h*llo", true},
+		{`h\*llo`, "hello", false},
+		{"a/*", "a/b/c", true},
+	} {
+		as.Equal(tc.match, globMatch(tc.pattern, tc.name), tc.pattern+" "+tc.name)
+	}
+}
+
+func TestHandleRESP(t *testing.T) {
+	as := assert.New(t)
+	c := NewRwCache(&ConfigMessage{})
+	ln, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if !as.NoError(err) {
+		return
+	}
+	defer ln.Close()
+	go handleRESP(ln, c, nil)
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if !as.NoError(err) {
+		return
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	conn.Write([]byte("*3\r\n$3\r\nSET\r\n$5\r\nfirst\r\n$4\r\nzaza\r\nGET first\r\n"))
+	for _, expected := range []string{"+OK\r\n", "$4\r\n", "zaza\r\n"} {
+		as.Equal(expected, readRESPLine(r))
+	}
+	as.Equal([]byte(`zaza`), c.Get("first"), "shared with other protocols")
+
+	c.Dead() //Cleanup
+}
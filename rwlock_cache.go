@@ -14,15 +14,21 @@ type Rwlockcache struct {
 	janitor           *janitor
 	stats             Stats
 	getterFunc        rwGetter
+	version           uint64
 }
 
-type rwGetter func(*Rwlockcache, string) []byte
+type rwGetter func(*Rwlockcache, string) (Item, bool)
 
 type janitor struct {
 	Interval time.Duration
 	stop     chan bool
 }
 
+//expirer is a cache which janitor can clean up
+type expirer interface {
+	deleteExpired()
+}
+
 //NewRwCache create new Rwlockcache based on cache config
 func NewRwCache(config ConfigCacheInterface) *Rwlockcache {
 	defaultExpiration := config.GetDefaultExpiration()
@@ -41,29 +47,31 @@ func NewRwCache(config ConfigCacheInterface) *Rwlockcache {
 		},
 	}
 	if config.GetIsKeepUsefull() {
-		cache.getterFunc = func(cache *Rwlockcache, name string) []byte {
+		cache.getterFunc = func(cache *Rwlockcache, name string) (Item, bool) {
 			cache.l.Lock()
-			if itm, ok := cache.m[name]; ok {
+			if itm := cache.lookup(name); itm != nil {
 				atomic.AddInt64(&cache.stats.GetSuccessNumber, 1)
-				itm.Expiration = int64(defaultExpiration) + itm.Expiration
-				v := itm.Object
+				if itm.Expiration != 0 {
+					itm.Expiration = time.Now().UnixNano() + cache.defaultExpiration //reset timer it looks usefull item
+				}
+				v := *itm
 				cache.l.Unlock()
-				return v
+				return v, true
 			}
 			atomic.AddInt64(&cache.stats.GetErrorNumber, 1)
 			cache.l.Unlock()
-			return nil
+			return Item{}, false
 		}
 	} else {
-		cache.getterFunc = func(cache *Rwlockcache, name string) []byte {
+		cache.getterFunc = func(cache *Rwlockcache, name string) (Item, bool) {
 			cache.l.RLock()
-			if itm, ok := cache.m[name]; ok {
-				v := itm.Object
+			if itm := cache.lookup(name); itm != nil {
+				v := *itm
 				cache.l.RUnlock()
-				return v
+				return v, true
 			}
 			cache.l.RUnlock()
-			return nil
+			return Item{}, false
 		}
 
 	}
@@ -87,19 +95,122 @@ func (c *Rwlockcache) deleteExpired() {
 
 //Get return item by name or nil
 func (c *Rwlockcache) Get(name string) []byte {
-	return c.getterFunc(c, name)
+	itm, _ := c.getterFunc(c, name)
+	return itm.Object
+}
+
+//GetItem return copy of item by name or nil
+func (c *Rwlockcache) GetItem(name string) *Item {
+	if itm, ok := c.getterFunc(c, name); ok {
+		return &itm
+	}
+	return nil
 }
 
 //SetOrUpdate set or update item in cache
 func (c *Rwlockcache) SetOrUpdate(name string, value []byte, exp time.Duration) {
-	atomic.AddInt64(&c.stats.SetOrReplaceCount, 1)
 	c.l.Lock()
+	c.store(name, value, expireAt(exp, c.defaultExpiration), 0)
+	c.l.Unlock()
+}
+
+//lookup return live item, should be called under lock
+func (c *Rwlockcache) lookup(name string) *Item {
+	if itm, ok := c.m[name]; ok && !itm.expired(time.Now().UnixNano()) {
+		return itm
+	}
+	return nil
+}
+
+//store replace item, should be called under write lock
+func (c *Rwlockcache) store(name string, value []byte, expiration int64, flags uint32) uint64 {
+	atomic.AddInt64(&c.stats.SetOrReplaceCount, 1)
+	c.version++
 	itm := &Item{
-		Expiration: int64(exp),
+		Expiration: expiration,
 		Object:     value,
+		Version:    c.version,
+		Flags:      flags,
 	}
 	c.m[name] = itm
+	return itm.Version
+}
+
+//Add set item only if it is absent
+func (c *Rwlockcache) Add(name string, value []byte, exp time.Duration) (uint64, error) {
+	return c.StoreFlags(ItemMessage_ADD, name, value, 0, 0, exp)
+}
+
+//Replace set item only if it is present
+func (c *Rwlockcache) Replace(name string, value []byte, exp time.Duration) (uint64, error) {
+	return c.StoreFlags(ItemMessage_REPLACE, name, value, 0, 0, exp)
+}
+
+//CAS set item only if its version is not changed
+func (c *Rwlockcache) CAS(name string, value []byte, version uint64, exp time.Duration) (uint64, error) {
+	return c.StoreFlags(ItemMessage_CAS, name, value, 0, version, exp)
+}
+
+//StoreFlags is SetOrUpdate, Add, Replace or CAS by cmd which also store client flags of memcached protocol
+func (c *Rwlockcache) StoreFlags(cmd ItemMessage_Commands, name string, value []byte, flags uint32, version uint64, exp time.Duration) (uint64, error) {
+	c.l.Lock()
+	defer c.l.Unlock()
+	if err := checkStore(cmd, c.lookup(name), version); err != nil {
+		return 0, err
+	}
+	return c.store(name, value, expireAt(exp, c.defaultExpiration), flags), nil
+}
+
+//Incr add delta to decimal item or create it
+func (c *Rwlockcache) Incr(name string, delta int64, exp time.Duration) (int64, error) {
+	c.l.Lock()
+	defer c.l.Unlock()
+	itm := c.lookup(name)
+	if itm == nil {
+		itm = &Item{Expiration: expireAt(exp, c.defaultExpiration)}
+	}
+	value, result, err := incrValue(itm.Object, delta)
+	if err != nil {
+		return 0, err
+	}
+	c.store(name, value, itm.Expiration, itm.Flags)
+	return result, nil
+}
+
+//Decr subtract delta from decimal item or create it
+func (c *Rwlockcache) Decr(name string, delta int64, exp time.Duration) (int64, error) {
+	delta, err := negate(delta)
+	if err != nil {
+		return 0, err
+	}
+	return c.Incr(name, delta, exp)
+}
+
+//Delete item from the cache
+func (c *Rwlockcache) Delete(name string) bool {
+	c.l.Lock()
+	itm := c.lookup(name)
+	delete(c.m, name)
 	c.l.Unlock()
+	if itm == nil {
+		return false
+	}
+	atomic.AddInt64(&c.stats.DeleteCount, 1)
+	return true
+}
+
+//Keys return names of all live items
+func (c *Rwlockcache) Keys() []string {
+	now := time.Now().UnixNano()
+	c.l.RLock()
+	keys := make([]string, 0, len(c.m))
+	for k, itm := range c.m {
+		if !itm.expired(now) {
+			keys = append(keys, k)
+		}
+	}
+	c.l.RUnlock()
+	return keys
 }
 
 //Purge delete all items from the cache
@@ -120,14 +231,27 @@ func (c *Rwlockcache) Dead() {
 
 //Statistic return all cache statistic
 func (c *Rwlockcache) Statistic() Stats {
-	c.l.RLock()
+	c.l.Lock()
 	c.stats.ItemsCount = int64(len(c.m))
-	c.l.RUnlock()
-	return c.stats
+	stats := c.stats
+	c.l.Unlock()
+	return stats
+}
+
+//Reconfigure apply DefaultExpiration and SizeLimit of config, existing items keep own expiration
+func (c *Rwlockcache) Reconfigure(config ConfigCacheInterface) {
+	defaultExpiration := config.GetDefaultExpiration()
+	if defaultExpiration <= 0 {
+		defaultExpiration = int64(DefaultExpiration)
+	}
+	c.l.Lock()
+	c.defaultExpiration = defaultExpiration
+	c.stats.SizeLimit = config.GetSizeLimit()
+	c.l.Unlock()
 }
 
 //Run async janitor
-func (j *janitor) Run(c *Rwlockcache) {
+func (j *janitor) Run(c expirer) {
 	ticker := time.NewTicker(j.Interval)
 	for {
 		select {
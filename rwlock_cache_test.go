@@ -1,7 +1,10 @@
 package gcache
 
 import (
+	"math"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -46,6 +49,21 @@ func TestRwlockcache_Get(t *testing.T) {
 	c.Dead() //Cleanup
 }
 
+func TestRwlockcache_GetExpired(t *testing.T) {
+	as := assert.New(t)
+	for _, keepUsefull := range []bool{true, false} {
+		c := NewRwCache(&ConfigMessage{DefaultExpiration: -1, IsKeepUsefull: keepUsefull})
+		c.SetOrUpdate("first", []byte(`zaza`), time.Millisecond)
+		time.Sleep(5 * time.Millisecond)
+		as.Equal(1, len(c.m), "janitor does not run yet")
+		as.Nil(c.Get("first"), "expired item is a miss like for Replace")
+		as.Nil(c.GetItem("first"))
+		_, err := c.Replace("first", []byte(`azaz`), NoExpiration)
+		as.Equal(ErrNotFound, err)
+		c.Dead() //Cleanup
+	}
+}
+
 func TestRwlockcache_SetOrUpdate(t *testing.T) {
 	as := assert.New(t)
 	c := NewRwCache(defaultConfig())
@@ -68,3 +86,131 @@ func TestRwlockcache_SetOrUpdate(t *testing.T) {
 
 	c.Dead() //Cleanup
 }
+
+func TestRwlockcache_Incr(t *testing.T) {
+	as := assert.New(t)
+	c := NewRwCache(defaultConfig())
+	v, err := c.Incr("counter", 5, DefaultExpirationMarker)
+	as.NoError(err)
+	as.Equal(int64(5), v)
+	v, err = c.Decr("counter", 7, DefaultExpirationMarker)
+	as.NoError(err)
+	as.Equal(int64(-2), v)
+	as.Equal([]byte(`-2`), c.Get("counter"))
+
+	c.SetOrUpdate("text", []byte(`zaza`), DefaultExpirationMarker)
+	_, err = c.Incr("text", 1, DefaultExpirationMarker)
+	as.Equal(ErrNotInteger, err)
+
+	_, err = c.Incr("max", math.MaxInt64, DefaultExpirationMarker)
+	as.NoError(err)
+	_, err = c.Incr("max", 1, DefaultExpirationMarker)
+	as.Equal(ErrOverflow, err)
+	as.Equal([]byte(`9223372036854775807`), c.Get("max"), "item is not changed on overflow")
+	_, err = c.Decr("min", math.MinInt64, DefaultExpirationMarker)
+	as.Equal(ErrOverflow, err, "math.MinInt64 could not be negated")
+	_, err = c.Decr("min", math.MaxInt64, DefaultExpirationMarker)
+	as.NoError(err)
+	_, err = c.Decr("min", 2, DefaultExpirationMarker)
+	as.Equal(ErrOverflow, err)
+
+	_, err = c.Incr("short", 1, time.Millisecond)
+	as.NoError(err)
+	time.Sleep(5 * time.Millisecond)
+	v, err = c.Incr("short", 1, NoExpiration)
+	as.NoError(err)
+	as.Equal(int64(1), v, "expired counter starts again")
+	as.Equal(int64(0), c.m["short"].Expiration)
+
+	c.Dead() //Cleanup
+}
+
+func TestRwlockcache_IncrParallel(t *testing.T) {
+	as := assert.New(t)
+	c := NewRwCache(defaultConfig())
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				c.Incr("counter", 1, DefaultExpirationMarker)
+			}
+		}()
+	}
+	wg.Wait()
+	as.Equal([]byte(`1000`), c.Get("counter"))
+	c.Dead() //Cleanup
+}
+
+//checkVersionedCacher check Add, Replace, CAS, GetItem, Delete and Keys of any cache
+func checkVersionedCacher(t *testing.T, c Cacher) {
+	as := assert.New(t)
+
+	as.Nil(c.GetItem("first"))
+	_, err := c.Replace("first", []byte(`zaza`), DefaultExpirationMarker)
+	as.Equal(ErrNotFound, err)
+	_, err = c.CAS("first", []byte(`zaza`), 1, DefaultExpirationMarker)
+	as.Equal(ErrNotFound, err)
+
+	v1, err := c.Add("first", []byte(`zaza`), DefaultExpirationMarker)
+	as.NoError(err)
+	as.NotZero(v1)
+	_, err = c.Add("first", []byte(`azaz`), DefaultExpirationMarker)
+	as.Equal(ErrExists, err)
+
+	itm := c.GetItem("first")
+	if as.NotNil(itm) {
+		as.Equal([]byte(`zaza`), itm.Object)
+		as.Equal(v1, itm.Version)
+		as.NotZero(itm.Expiration)
+	}
+
+	v2, err := c.Replace("first", []byte(`azaz`), NoExpiration)
+	as.NoError(err)
+	as.NotEqual(v1, v2)
+	_, err = c.CAS("first", []byte(`zara`), v1, DefaultExpirationMarker)
+	as.Equal(ErrVersionMismatch, err)
+	v3, err := c.CAS("first", []byte(`zara`), v2, NoExpiration)
+	as.NoError(err)
+	as.NotEqual(v2, v3)
+
+	itm = c.GetItem("first")
+	if as.NotNil(itm) {
+		as.Equal([]byte(`zara`), itm.Object)
+		as.Equal(v3, itm.Version)
+		as.Zero(itm.Expiration)
+	}
+	as.Equal([]byte(`zara`), c.Get("first"))
+
+	c.SetOrUpdate("first", []byte(`zaza`), DefaultExpirationMarker)
+	_, err = c.CAS("first", []byte(`azaz`), v3, DefaultExpirationMarker)
+	as.Equal(ErrVersionMismatch, err, "SetOrUpdate change version")
+
+	_, err = c.Add("short", []byte(`zaza`), time.Millisecond)
+	as.NoError(err)
+	time.Sleep(5 * time.Millisecond)
+	_, err = c.Add("short", []byte(`azaz`), DefaultExpirationMarker)
+	as.NoError(err, "expired item is absent")
+
+	deleted := c.Statistic().DeleteCount
+	as.True(c.Delete("first"))
+	as.False(c.Delete("first"))
+	as.Nil(c.Get("first"))
+	as.Nil(c.GetItem("first"))
+	as.Equal(deleted+1, c.Statistic().DeleteCount)
+
+	c.SetOrUpdate("listed", []byte(`zaza`), NoExpiration)
+	c.SetOrUpdate("expired", []byte(`zaza`), time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	keys := c.Keys()
+	as.Contains(keys, "listed")
+	as.NotContains(keys, "first", "deleted item is not listed")
+	as.NotContains(keys, "expired", "expired item is not listed")
+}
+
+func TestRwlockcache_CAS(t *testing.T) {
+	c := NewRwCache(defaultConfig())
+	checkVersionedCacher(t, c)
+	c.Dead() //Cleanup
+}
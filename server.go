@@ -1,17 +1,14 @@
 package gcache
 
 import (
+	"bufio"
+	"compress/flate"
 	"errors"
-	"flag"
-	"fmt"
 	"io"
 	"io/ioutil"
-	"log"
 	"net"
-	"net/http"
-	"os"
 	"runtime"
-	"strings"
+	"strconv"
 	"sync"
 	"time"
 
@@ -23,290 +20,419 @@ const (
 	modeTCPLong      = "tcp_long"
 	modeTCPShort     = "tcp_short"
 	modeUDP          = "udp"
-	systemBufferSize = 1e6 //1Mb
-	maxPacketSize    = 1e5 //10Kb
-	//	modeTLS  = "tls"
-	//	MODE_TLS  = "https"
-	amulet              = byte(30) //ANCI Record separator
-	closendErrorMessage = "closed network connection"
+	modeMemcache     = "memcache"
+	modeRESP         = "resp"
+	modeGRPC         = "grpc"
+	modeUnix         = "unix"
+	systemBufferSize = 1e6      //1Mb
+	maxPacketSize    = 1e5      //100Kb, bigger than any datagram
+	maxDatagramSize  = 65507    //the biggest udp payload of ipv4, bigger responces are TRUNCATED
+	maxConnInflight  = 128      //concurrent requests per tcp_long connection
+	amulet           = byte(30) //ANCI Record separator
 )
 
-var errDead = errors.New("Dead state")
+//ServerVersion is a version reported to clients
+const ServerVersion = "0.2.0"
 
-//TODO remove protobuf write custom Marshal demarhsal
-type ServerConfig struct {
-	Mode        string
-	BindAddress string
-	Expiration  int
-	HashFunc    string
-}
-type TCPHandler func(*net.TCPListener, Cacher)
+//ErrTruncated is a status of udp request which responce does not fit datagram, request should be sent via tcp
+var ErrTruncated = errors.New("Responce is too large for udp")
 
-func NewCacheServer() {
-	c := ServerConfig{}
-	c.initFlags()
-	cache := NewRwCache(nil) //TODO
-	switch c.Mode {
-	case modeHTTP:
-		err := http.ListenAndServe(c.BindAddress, nil)
-		if err != nil {
-			log.Fatalln("Could not bind address: " + c.BindAddress + " error: " + err.Error())
-		}
-	case modeTCPLong, modeTCPShort:
-		tcpAddr, err := net.ResolveTCPAddr("tcp", c.BindAddress)
-		if err != nil {
-			log.Fatalln("Could not resolve address: " + c.BindAddress + " error: " + err.Error())
-		}
-		ln, err := net.ListenTCP("tcp", tcpAddr)
-		if err != nil {
-			log.Fatalln("Could not bind address: " + c.BindAddress + " error: " + err.Error())
-		}
-		if c.Mode == modeTCPLong {
-			handleLongTCP(ln, cache)
-		} else {
-			handleShortTCP(ln, cache)
-		}
+var (
+	errDead           = errors.New("Dead state")
+	errUnknownCommand = errors.New("Unknown command")
+	errBadMessage     = errors.New("Could not parse message")
+)
 
-	case modeUDP:
-		udpAdd, err := net.ResolveUDPAddr("udp", c.BindAddress)
-		if err != nil {
-			log.Fatalln("Could not resolve address: " + c.BindAddress + " error: " + err.Error())
-		}
-		conn, err := net.ListenUDP("udp", udpAdd)
-		if err != nil {
-			log.Fatalln("Could not resolve address: " + c.BindAddress + " error: " + err.Error())
-		}
-		HandleUDP(conn, cache)
+//handleShortTCP expect only one message via tcp and return data for each
+func handleShortTCP(ln net.Listener, cache Cacher, sec *Security) {
+	serveListener(modeTCPShort, ln, cache, sec)
+}
 
-	default:
-		panic("Not implemented mode : " + c.Mode)
-	}
+//handleLongTCP expecte open connection and communicate without closing of this
+func handleLongTCP(ln net.Listener, cache Cacher, sec *Security) {
+	serveListener(modeTCPLong, ln, cache, sec)
+}
 
+//HandleUDP is simple handeler with worker pool, we have limitation with updpacket size to bufSize
+func HandleUDP(ServerConn *net.UDPConn, cache Cacher) error {
+	return handleUDP(ServerConn, cache, nil)
 }
 
-func (c *ServerConfig) initFlags() {
-	flag.StringVar(&c.Mode, "http", "http", "mode of cachec server: can be "+modeHTTP+" "+modeTCPLong+" or "+modeUDP)
-	flag.StringVar(&c.BindAddress, "bind", "", "optional options to set listening specific interface: <ip ro hostname>:<port>")
-	flag.IntVar(&c.Expiration, "expiration", 200, "expiration time in seconds")
+//handleUDP serve udp with access control, every datagram is authenticated by own Token
+func handleUDP(ServerConn *net.UDPConn, cache Cacher, sec *Security) error {
+	ep := &endpoint{Endpoint: Endpoint{Mode: modeUDP}, packet: ServerConn}
+	if err := serveEndpoint(ep, cache, sec); err != ErrServerClosed && !errors.Is(err, net.ErrClosed) {
+		return err
+	}
+	return nil
+}
 
-	flag.Parse()
+//serveListener serve stream mode on listener until it is closed or server is stopped by DEAD command
+func serveListener(mode string, ln net.Listener, cache Cacher, sec *Security) error {
+	return serveEndpoint(&endpoint{Endpoint: Endpoint{Mode: mode}, ln: ln}, cache, sec)
+}
 
-	if err := c.checkFlags(); err != nil {
-		fmt.Printf("Error: %v", err)
-		flag.PrintDefaults()
-		os.Exit(1)
+//serveEndpoint serve one endpoint without shutdown when its listener is closed
+func serveEndpoint(ep *endpoint, cache Cacher, sec *Security) error {
+	s := newServer(cache, sec)
+	if err := s.add(ep); err != nil {
+		return err
 	}
+	return s.accept(ep)
 }
 
-func (c *ServerConfig) checkFlags() error {
-	if c.Mode == modeHTTP || c.Mode == modeTCPLong || c.Mode == modeUDP {
-		return nil
+//serveShortTCP read one message until client close write and reply with one message,
+// message prefixed by hello is encoded by codec of hello and responce is prefixed by the same hello
+func (s *Server) serveShortTCP(c net.Conn) {
+	data, err := ioutil.ReadAll(io.LimitReader(c, DefaultMaxFrameSize+3)) //End client should close write tcp we wait eof
+	if err != nil {
+		return
+	}
+	cd, version, data := splitHello(data)
+	if cd == nil { //unknown codec, every client understand protobuf
+		c.Write(appendError(nil, protoCodec{}, errBadMessage))
+		return
+	}
+	var out []byte
+	if version != CodecProto {
+		out = appendHello(out, version)
+	}
+	if len(data) > DefaultMaxFrameSize {
+		c.Write(appendError(out, cd, ErrFrameTooLarge))
+		return
+	}
+	t := acquireMessage()
+	defer releaseMessage(t)
+	if err = cd.unmarshal(data, t); err != nil {
+		c.Write(appendError(out, cd, errBadMessage))
+		return
+	}
+	result, err := s.security.session(c).handleRequest(t, s.cache, cd, out)
+	c.Write(result) //Do not care about error we can't do anything with error
+	if err == errDead {
+		s.stop()
 	}
-	return fmt.Errorf("Wrong mode: %s", c.Mode)
 }
 
-//handleShortTCP expect only one message via tcp and return data for each
-func handleShortTCP(ln *net.TCPListener, cache Cacher) {
+//serveLongTCP communicate via open connection until client close it or server is stopped
+//Requests with RequestID are handled concurrently and responces can be sent out of order,
+// requests without id are handled one by one. Codec of connection is chosen by hello, see acceptCodec
+func (s *Server) serveLongTCP(c net.Conn) {
 	var (
-		once    sync.Once
-		income  = make(chan *net.TCPConn, 10)
-		wg      sync.WaitGroup
-		stopper = func() {
-			close(income)
-			ln.Close()
-		}
+		sess      = s.security.session(c)
+		br        = bufio.NewReader(c)
+		reader    = NewFrameReader(br, DefaultMaxFrameSize)
+		responces = make(chan *[]byte, maxConnInflight)
+		written   = make(chan struct{})
+		inflight  sync.WaitGroup
+		limit     = make(chan struct{}, maxConnInflight)
 	)
-	handler := func(inCon <-chan *net.TCPConn) {
-		defer wg.Done()
-		for c := range inCon {
-			data, err := ioutil.ReadAll(c) //End client should close write tcp we wait eof
-
-			var t *ItemMessage
-			err = proto.Unmarshal(data, t)
-			if err != nil {
-				c.Close()
-				continue
-			}
-			result, err := handleRequest(t, cache)
-			if err == errDead {
-				c.Close()
-				once.Do(stopper)
-				return
-			}
-			if len(result) > 0 {
-				c.Write(result) //Do not care about error we can't do anything with error
+	cd, err := acceptCodec(br, c)
+	if err != nil {
+		return
+	}
+	go func() { //single writer, flush when there is nothing to write
+		writer := bufio.NewWriter(c)
+		for b := range responces {
+			WriteFrame(writer, *b) //Do not care about error we can't do anything with error
+			releaseBuffer(b)
+			if len(responces) == 0 {
+				writer.Flush()
 			}
-			c.Close()
 		}
+		writer.Flush()
+		close(written)
+	}()
+	defer func() {
+		inflight.Wait()
+		close(responces)
+		<-written
+	}()
+	fail := func(err error) {
+		b := acquireBuffer()
+		*b = appendError(*b, cd, err)
+		responces <- b
 	}
-
-	wg.Add(runtime.NumCPU())
-	for i := 0; i < runtime.NumCPU(); i++ {
-		go handler(income)
+	reply := func(t *ItemMessage) (err error) { //request is released
+		b := acquireBuffer()
+		*b, err = sess.handleRequest(t, s.cache, cd, *b)
+		releaseMessage(t)
+		responces <- b
+		return err
 	}
 
 	for {
-		conn, err := ln.AcceptTCP()
-
+		data, err := reader.ReadFrame()
+		if err == ErrFrameTooLarge {
+			fail(err) //frame is skipped, stream is still in sync
+			continue
+		}
 		if err != nil {
-			errStr := err.Error()
-			//How to check closed conn better ?
-			if strings.Contains(errStr, closendErrorMessage) {
-				once.Do(stopper)
-				break
-			}
-			if conn != nil {
-				conn.Close() // some error appear do not try to handle income request
+			return //EOF, broken connection or shutdown
+		}
+		t := acquireMessage()
+		if err = cd.unmarshal(data, t); err != nil {
+			releaseMessage(t)
+			fail(errBadMessage)
+			continue
+		}
+		if t.GetRequestID() == 0 || t.Command == ItemMessage_DEAD || t.Command == ItemMessage_AUTH || t.GetToken() != "" {
+			inflight.Wait() //requests without id keep order, token change session of next requests
+			if reply(t) == errDead {
+				s.stop()
+				return
 			}
 			continue
 		}
-		income <- conn
+		limit <- struct{}{}
+		inflight.Add(1)
+		go func(t *ItemMessage) {
+			defer func() {
+				<-limit
+				inflight.Done()
+			}()
+			reply(t)
+		}(t)
 	}
-
-	wg.Wait()
 }
 
-//handleLongTCP expecte open connection and communicate without closing of this
-func handleLongTCP(ln *net.TCPListener, cache Cacher) {
-	var (
-		once sync.Once
-
-		stopper = func() {
-			ln.Close()
-		}
-	)
-	handler := func(c *net.TCPConn) {
-		buf := make([]byte, maxPacketSize, maxPacketSize)
-		for eof := false; eof; {
-			n, err := c.Read(buf)
-			if err != nil {
-				if err == io.EOF {
-					eof = true
-					break
+//serveUDP read datagrams by worker pool until shutdown, responce which does not fit datagram is replaced by TRUNCATED one.
+//Responces of non-idempotent requests with RequestID are repeated for retries, see udpReplies.
+//Datagram prefixed by hello is encoded by codec of hello and responce is prefixed by the same hello
+func (s *Server) serveUDP(packet *net.UDPConn) error {
+	packet.SetReadBuffer(systemBufferSize)
+	workers := runtime.NumCPU()
+	if !s.track(nil, workers) {
+		return net.ErrClosed
+	}
+	replies := newUDPReplies()
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() { //a little faster with multiple UDP packet
+			defer wg.Done()
+			defer s.untrack(nil)
+			buf := make([]byte, maxPacketSize, maxPacketSize)
+			var out []byte //responce buffer of worker
+			for {
+				n, addr, err := packet.ReadFromUDPAddrPort(buf)
+				if err != nil {
+					if errors.Is(err, net.ErrClosed) || s.isClosing() {
+						return
+					}
+					continue
+				}
+				cd, version, data := splitHello(buf[0:n])
+				if cd == nil {
+					out = appendError(out[:0], protoCodec{}, errBadMessage)
+					packet.WriteToUDPAddrPort(out, addr)
+					continue
+				}
+				out = out[:0]
+				if version != CodecProto {
+					out = appendHello(out, version)
+				}
+				t := acquireMessage()
+				if err = cd.unmarshal(data, t); err != nil {
+					releaseMessage(t)
+					out = appendError(out, cd, errBadMessage)
+					packet.WriteToUDPAddrPort(out, addr)
+					continue
+				}
+				key := udpRequest{addr: addr, id: t.GetRequestID()}
+				if responce, duplicate := replies.begin(key, t.Command); duplicate {
+					releaseMessage(t)
+					if responce != nil { //nothing is sent while first request is in progress
+						packet.WriteToUDPAddrPort(responce, addr)
+					}
+					continue
+				}
+				head := len(out)
+				out, err = s.security.session(nil).handleRequest(t, s.cache, cd, out)
+				if len(out) > maxDatagramSize {
+					out, _ = cd.appendMessage(out[:head], failedMessage(t, ErrTruncated))
+				}
+				replies.finish(key, t.Command, out)
+				releaseMessage(t)
+				packet.WriteToUDPAddrPort(out, addr)
+				if err == errDead {
+					s.stop()
+				}
+				if cap(out) > maxPooledBuffer {
+					out = nil
 				}
 			}
-			if n == 0 {
-				continue //Empty request
-			}
-			var t = &ItemMessage{} //TODO pool messages protobuf sucs
-			err = proto.Unmarshal(buf[:n], t)
-			if err != nil {
-				continue
-			}
-			result, err := handleRequest(t, cache)
-			if err == errDead {
-				once.Do(stopper)
-				break
-			}
-
-			if len(result) > 0 {
-				c.Write(result) //Do not care about error we can't do anything with error
-			}
-		}
-		c.Close()
-
+		}()
 	}
+	wg.Wait()
+	return net.ErrClosed
+}
 
-	for {
-		conn, err := ln.AcceptTCP()
+//handleRequest handle message and return marshaled responce
+func handleRequest(t *ItemMessage, cache Cacher) (message []byte, err error) {
+	resp, err := handleMessage(t, cache)
+	message, _ = proto.Marshal(resp)
+	return message, err
+}
 
-		if err != nil {
-			errStr := err.Error()
-			//How to check closed conn better ?
-			if strings.Contains(errStr, closendErrorMessage) {
-				once.Do(stopper)
-				break
-			}
-			if conn != nil {
-				conn.Close() // some error appear do not try to handle income request
-			}
-			continue
-		}
-		go handler(conn) //Long handler
+//handleMessage apply request to cache and return responce with status, errDead is returned for DEAD command and server should be stopped
+func handleMessage(t *ItemMessage, cache Cacher) (*ItemMessage, error) {
+	resp, err := applyMessage(t, cache)
+	if resp == nil {
+		resp = acquireMessage()
+		resp.Command, resp.Name = t.Command, t.GetName()
 	}
-
+	resp.RequestID = t.GetRequestID()
+	if err != nil && err != errDead {
+		resp.Status = errorStatus(err)
+		resp.Error = err.Error()
+	}
+	return resp, err
 }
 
-//HandleUDP is simple handeler with worker pool, we have limitation with updpacket size to bufSize
-func HandleUDP(ServerConn *net.UDPConn, cache Cacher) error {
-
-	var (
-		once    sync.Once
-		wg      sync.WaitGroup
-		stopper = func() {
-			ServerConn.Close()
-		}
-	)
-
-	defer once.Do(stopper)
-	ServerConn.SetReadBuffer(systemBufferSize)
-	handler := func() {
-		var (
-			buf  = make([]byte, maxPacketSize, maxPacketSize) //TODO pool ?
-			n    int
-			addr *net.UDPAddr
-			err  error
-		)
-	mainLoop:
-		for {
-			n, addr, err = ServerConn.ReadFromUDP(buf)
-			if err != nil {
-				errStr := err.Error()
-				//How to check closed conn better ?
-				if strings.Contains(errStr, closendErrorMessage) {
-					break mainLoop
-				}
-			}
+//appendError append responce for request which could not be parsed
+func appendError(dst []byte, cd codec, err error) []byte {
+	dst, _ = cd.appendMessage(dst, &ItemMessage{Status: errorStatus(err), Error: err.Error()})
+	return dst
+}
 
-			var t *ItemMessage
-			err = proto.Unmarshal(buf[0:n], t)
-			if err != nil {
-				continue
-			}
-			responce, err := handleRequest(t, cache)
-			if len(responce) > 0 {
-				ServerConn.WriteToUDP(responce, addr)
-			}
-			if err == errDead {
-				once.Do(stopper)
-				break mainLoop
-			}
-		}
-		wg.Done()
+//errorStatus map cache errors to wire status
+func errorStatus(err error) ItemMessage_Statuses {
+	switch err {
+	case nil:
+		return ItemMessage_OK
+	case ErrNotFound:
+		return ItemMessage_NOT_FOUND
+	case ErrExists, ErrVersionMismatch:
+		return ItemMessage_EXISTS
+	case ErrFrameTooLarge:
+		return ItemMessage_TOO_LARGE
+	case ErrTruncated:
+		return ItemMessage_TRUNCATED
+	case ErrUnauthorized, ErrForbidden:
+		return ItemMessage_UNAUTHORIZED
+	case ErrNotInteger, ErrOverflow, ErrNotSupported, ErrCompressFlag, errUnknownCommand, errBadMessage, io.ErrUnexpectedEOF:
+		return ItemMessage_INVALID
 	}
-
-	wg.Add(runtime.NumCPU())
-	for i := 0; i < runtime.NumCPU(); i++ {
-		go handler() //a little faster with multiple UDP packet
+	if _, ok := err.(*flate.CorruptInputError); ok {
+		return ItemMessage_INVALID
 	}
-
-	wg.Wait()
-
-	return nil
+	return ItemMessage_SERVER_ERROR
 }
 
-func handleRequest(t *ItemMessage, cache Cacher) (message []byte, err error) {
+//applyMessage apply request to cache, nil responce mean responce without payload.
+//Responces are taken from pool, caller which marshal them could release them
+func applyMessage(t *ItemMessage, cache Cacher) (resp *ItemMessage, err error) {
 	var (
 		name   = t.GetName()
 		object = t.GetObject()
 	)
 	switch t.Command {
 	case ItemMessage_SET:
+		if t.GetCompressed() {
+			if object, err = DecompressValue(object); err != nil {
+				return nil, err
+			}
+		}
 		cache.SetOrUpdate(name, object, time.Duration(t.GetExpiration()))
 	case ItemMessage_GET:
-		data := cache.Get(name)
-		tr := &ItemMessage{
-			Name:    name,
-			Object:  data,
-			Command: ItemMessage_SET,
+		var data []byte
+		resp = acquireMessage()
+		resp.Name, resp.Command = name, ItemMessage_SET
+		itm := cache.GetItem(name)
+		if itm == nil {
+			return resp, ErrNotFound
+		}
+		data = itm.Object
+		resp.Object = data
+		resp.Expiration = itm.Expiration //absolute time in responce
+		resp.Version = itm.Version
+		if t.GetCompressed() { //client can decode compressed values
+			resp.Object = CompressValue(data, DefaultCompressThreshold)
+			resp.Compressed = true
+		}
+		return resp, nil
+	case ItemMessage_INCR, ItemMessage_DECR:
+		var value int64
+		if t.Command == ItemMessage_INCR {
+			value, err = cache.Incr(name, t.GetDelta(), time.Duration(t.GetExpiration()))
+		} else {
+			value, err = cache.Decr(name, t.GetDelta(), time.Duration(t.GetExpiration()))
+		}
+		resp = acquireMessage()
+		resp.Name, resp.Command = name, ItemMessage_SET
+		if err == nil {
+			resp.Object = strconv.AppendInt(nil, value, 10)
+		}
+		return resp, err
+	case ItemMessage_ADD, ItemMessage_REPLACE, ItemMessage_CAS:
+		if t.GetCompressed() {
+			if object, err = DecompressValue(object); err != nil {
+				return nil, err
+			}
+		}
+		var version uint64
+		exp := time.Duration(t.GetExpiration())
+		switch t.Command {
+		case ItemMessage_ADD:
+			version, err = cache.Add(name, object, exp)
+		case ItemMessage_REPLACE:
+			version, err = cache.Replace(name, object, exp)
+		default:
+			version, err = cache.CAS(name, object, t.GetVersion(), exp)
+		}
+		resp = acquireMessage()
+		resp.Name, resp.Command, resp.Version = name, ItemMessage_SET, version
+		return resp, err
+	case ItemMessage_DELETE:
+		if !cache.Delete(name) {
+			return nil, ErrNotFound
 		}
-		message, err = proto.Marshal(tr)
-		return message, err
 	case ItemMessage_PURGE:
 		cache.Purge()
-	case ItemMessage_DEAD:
-		cache.Dead()
+	case ItemMessage_STATS:
+		return &ItemMessage{
+			Command: ItemMessage_STATS,
+			Stats:   statsMessage(cache.Statistic()),
+		}, nil
+	case ItemMessage_KEYS:
+		return &ItemMessage{
+			Command: ItemMessage_KEYS,
+			Keys:    cache.Keys(),
+		}, nil
+	case ItemMessage_PING:
+	case ItemMessage_DEAD: //server call cache.Dead after graceful shutdown
 		return nil, errDead
+	default:
+		return nil, errUnknownCommand
 	}
 	return nil, nil
 }
+
+func statsMessage(s Stats) *StatsMessage {
+	return &StatsMessage{
+		ItemsCount:        s.ItemsCount,
+		GetSuccessNumber:  s.GetSuccessNumber,
+		GetErrorNumber:    s.GetErrorNumber,
+		SetOrReplaceCount: s.SetOrReplaceCount,
+		DeleteCount:       s.DeleteCount,
+		DeleteExpired:     s.DeleteExpired,
+		SizeLimit:         s.SizeLimit,
+		RawBytes:          s.RawBytes,
+		CompressedBytes:   s.CompressedBytes,
+	}
+}
+
+func statsFromMessage(m *StatsMessage) Stats {
+	return Stats{
+		ItemsCount:        m.GetItemsCount(),
+		GetSuccessNumber:  m.GetGetSuccessNumber(),
+		GetErrorNumber:    m.GetGetErrorNumber(),
+		SetOrReplaceCount: m.GetSetOrReplaceCount(),
+		DeleteCount:       m.GetDeleteCount(),
+		DeleteExpired:     m.GetDeleteExpired(),
+		SizeLimit:         m.GetSizeLimit(),
+		RawBytes:          m.GetRawBytes(),
+		CompressedBytes:   m.GetCompressedBytes(),
+	}
+}
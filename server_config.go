@@ -0,0 +1,223 @@
+package gcache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+)
+
+//DefaultConfigCheckInterval is a period of checking modification time of config file by ConfigWatcher
+const DefaultConfigCheckInterval = 2 * time.Second
+
+//Duration is a time.Duration written as "1m30s" in json
+type Duration time.Duration
+
+//UnmarshalJSON parse duration string
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+//MarshalJSON write duration string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+//ServerConfig is a json configuration file of server, see LoadServerConfig
+type ServerConfig struct {
+	Endpoints       []Endpoint      `json:"endpoints"`
+	Cache           CacheFileConfig `json:"cache"`
+	TLS             TLSFileConfig   `json:"tls"`
+	ACL             *ACL            `json:"acl,omitempty"`      //inline access control list
+	ACLFile         string          `json:"acl_file,omitempty"` //access control list file, see LoadACL
+	Snapshot        string          `json:"snapshot,omitempty"`
+	ShutdownTimeout Duration        `json:"shutdown_timeout,omitempty"`
+	SocketPerm      string          `json:"socket_perm,omitempty"` //octal permissions of unix socket files like "0660"
+}
+
+//CacheFileConfig describe cache, only expiration and size_limit could be changed without restart
+type CacheFileConfig struct {
+	Type        string   `json:"type,omitempty"` //rwl by default, singlegorutine or offheap
+	Shards      int64    `json:"shards,omitempty"`
+	Hash        string   `json:"hash,omitempty"` //hash of shards: fnv by default, crc, djb33 or sum
+	Expiration  Duration `json:"expiration,omitempty"`
+	SizeLimit   int64    `json:"size_limit,omitempty"`
+	MemoryLimit int64    `json:"memory_limit,omitempty"`
+	KeepUsefull bool     `json:"keep_usefull,omitempty"`
+}
+
+//TLSFileConfig is a set of files of TLSOptions
+type TLSFileConfig struct {
+	Cert       string `json:"cert,omitempty"`
+	Key        string `json:"key,omitempty"`
+	ClientCA   string `json:"client_ca,omitempty"`
+	Identities string `json:"identities,omitempty"` //see LoadIdentities
+}
+
+//LoadServerConfig read json file, unknown fields are rejected
+func LoadServerConfig(path string) (*ServerConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &ServerConfig{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err = decoder.Decode(c); err != nil {
+		return nil, errors.New("Could not parse " + path + ": " + err.Error())
+	}
+	return c, nil
+}
+
+//CacheConfig return config of cache for NewConfigServer
+func (c *ServerConfig) CacheConfig() (*ConfigMessage, error) {
+	cacheType := ConfigMessage_RWL
+	if c.Cache.Type != "" {
+		v, ok := ConfigMessage_CacheTypes_value[strings.ToUpper(c.Cache.Type)]
+		if !ok {
+			return nil, errors.New("Unknown cache type: " + c.Cache.Type)
+		}
+		cacheType = ConfigMessage_CacheTypes(v)
+	}
+	if _, ok := hashFuncs[c.Cache.Hash]; !ok {
+		return nil, errors.New("Unknown hash function: " + c.Cache.Hash)
+	}
+	return &ConfigMessage{
+		CacheType:         cacheType,
+		ShardCount:        c.Cache.Shards,
+		HashFunc:          c.Cache.Hash,
+		DefaultExpiration: int64(c.Cache.Expiration),
+		SizeLimit:         c.Cache.SizeLimit,
+		MemoryLimit:       c.Cache.MemoryLimit,
+		IsKeepUsefull:     c.Cache.KeepUsefull,
+	}, nil
+}
+
+//Options load identities and acl files and return options of server
+func (c *ServerConfig) Options() (ServerOptions, error) {
+	opts := ServerOptions{
+		Endpoints:       c.Endpoints,
+		TLS:             TLSOptions{CertFile: c.TLS.Cert, KeyFile: c.TLS.Key, ClientCAFile: c.TLS.ClientCA},
+		ACL:             c.ACL,
+		SnapshotPath:    c.Snapshot,
+		ShutdownTimeout: time.Duration(c.ShutdownTimeout),
+	}
+	if c.SocketPerm != "" {
+		perm, err := ParseSocketPerm(c.SocketPerm)
+		if err != nil {
+			return opts, err
+		}
+		opts.SocketPerm = perm
+	}
+	if c.TLS.Identities != "" {
+		identities, err := LoadIdentities(c.TLS.Identities)
+		if err != nil {
+			return opts, err
+		}
+		opts.TLS.Identities = identities
+	}
+	if c.ACL != nil && c.ACLFile != "" {
+		return opts, errors.New("Both acl and acl_file are set")
+	}
+	if c.ACL != nil {
+		if err := c.ACL.Validate(); err != nil {
+			return opts, err
+		}
+	}
+	if c.ACLFile != "" {
+		acl, err := LoadACL(c.ACLFile)
+		if err != nil {
+			return opts, err
+		}
+		opts.ACL = acl
+	}
+	return opts, opts.Validate()
+}
+
+//NewServer create server described by config
+func (c *ServerConfig) NewServer() (*Server, error) {
+	config, err := c.CacheConfig()
+	if err != nil {
+		return nil, err
+	}
+	opts, err := c.Options()
+	if err != nil {
+		return nil, err
+	}
+	return NewConfigServer(config, opts)
+}
+
+//ReloadFile read config file and apply it by Reload, see LoadServerConfig
+func (s *Server) ReloadFile(path string) error {
+	c, err := LoadServerConfig(path)
+	if err != nil {
+		return err
+	}
+	config, err := c.CacheConfig()
+	if err != nil {
+		return err
+	}
+	opts, err := c.Options()
+	if err != nil {
+		return err
+	}
+	return s.Reload(config, opts)
+}
+
+//ConfigWatcher reload server from config file when the file is modified or signal is received
+type ConfigWatcher struct {
+	Server   *Server
+	Path     string
+	Interval time.Duration    //DefaultConfigCheckInterval if zero
+	Signals  <-chan os.Signal //for example SIGHUP, file is reloaded only by modification time if nil
+	OnReload func(error)      //optional, it is called with result of every reload
+}
+
+//Run watch config file until ctx is done
+func (w *ConfigWatcher) Run(ctx context.Context) {
+	interval := w.Interval
+	if interval <= 0 {
+		interval = DefaultConfigCheckInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	modified := w.modified()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.Signals:
+		case <-ticker.C:
+			if w.modified().Equal(modified) {
+				continue
+			}
+		}
+		modified = w.modified()
+		err := w.Server.ReloadFile(w.Path)
+		if w.OnReload != nil {
+			w.OnReload(err)
+		}
+	}
+}
+
+//modified return modification time of file or zero time if it is missing
+func (w *ConfigWatcher) modified() time.Time {
+	info, err := os.Stat(w.Path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,167 @@
+package gcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testServerConfig = `{
+	"endpoints": [{"mode": "tcp_long", "address": "127.0.0.1:0"}],
+	"cache": {"type": "rwl", "shards": 4, "hash": "crc", "expiration": "%s", "size_limit": 100},
+	"acl": {"principals": [{"name": "%[2]s", "tokens": ["%[2]s"]}]},
+	"shutdown_timeout": "1s"
+}`
+
+func writeServerConfig(t *testing.T, path, expiration, token string) {
+	data := []byte(fmt.Sprintf(testServerConfig, expiration, token))
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServerConfig_Load(t *testing.T) {
+	as := assert.New(t)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "gcache.json")
+	writeServerConfig(t, path, "1m", "secret")
+
+	c, err := LoadServerConfig(path)
+	if !as.NoError(err) {
+		return
+	}
+	config, err := c.CacheConfig()
+	if as.NoError(err) {
+		as.Equal(ConfigMessage_RWL, config.CacheType)
+		as.Equal(int64(4), config.ShardCount)
+		as.Equal("crc", config.HashFunc)
+		as.Equal(int64(time.Minute), config.DefaultExpiration)
+	}
+	opts, err := c.Options()
+	if as.NoError(err) {
+		as.Equal([]Endpoint{{Mode: modeTCPLong, Address: "127.0.0.1:0"}}, opts.Endpoints)
+		as.Equal(time.Second, opts.ShutdownTimeout)
+		as.Len(opts.ACL.Principals, 1)
+	}
+
+	ioutil.WriteFile(path, []byte(`{"endpoints": [], "unknown": 1}`), 0600)
+	_, err = LoadServerConfig(path)
+	as.Error(err, "unknown field")
+	for _, wrong := range []*ServerConfig{
+		{Cache: CacheFileConfig{Type: "fly"}},
+		{Cache: CacheFileConfig{Hash: "md5"}},
+	} {
+		_, err = wrong.CacheConfig()
+		as.Error(err)
+	}
+	_, err = (&ServerConfig{Endpoints: opts.Endpoints, ACL: &ACL{}, ACLFile: "acl.json"}).Options()
+	as.Error(err)
+}
+
+func TestServer_Reload(t *testing.T) {
+	as := assert.New(t)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "gcache.json")
+	writeServerConfig(t, path, "1m", "secret")
+	c, err := LoadServerConfig(path)
+	if !as.NoError(err) {
+		return
+	}
+	s, err := c.NewServer()
+	if !as.NoError(err) {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(ctx) }()
+	for s.Addr(modeTCPLong) == nil {
+		time.Sleep(time.Millisecond)
+	}
+
+	remote, err := NewRemoteCache(s.Addr(modeTCPLong).String(), RemoteOptions{Token: "secret"})
+	if !as.NoError(err) {
+		return
+	}
+	_, err = remote.Add("first", []byte(`zaza`), DefaultExpirationMarker)
+	as.NoError(err)
+	itm := s.Cache().GetItem("first")
+	as.InDelta(time.Now().Add(time.Minute).UnixNano(), itm.Expiration, float64(time.Second))
+	as.Equal(int64(400), s.Cache().Statistic().SizeLimit)
+
+	writeServerConfig(t, path, "1h", "other")
+	as.NoError(s.ReloadFile(path))
+	_, err = remote.Add("second", []byte(`zaza`), DefaultExpirationMarker)
+	as.Equal(ErrUnauthorized, err, "principal is removed for open connection")
+	s.Cache().SetOrUpdate("second", []byte(`zaza`), DefaultExpirationMarker)
+	itm = s.Cache().GetItem("second")
+	as.InDelta(time.Now().Add(time.Hour).UnixNano(), itm.Expiration, float64(time.Second), "expiration is applied")
+	remote.Dead()
+
+	c.Endpoints = []Endpoint{{Mode: modeHTTP, Address: "127.0.0.1:0"}}
+	c.Cache.Type = "singlegorutine"
+	c.Cache.Expiration = Duration(time.Second)
+	config, _ := c.CacheConfig()
+	opts, _ := c.Options()
+	err = s.Reload(config, opts)
+	as.True(errors.Is(err, ErrRestartRequired))
+	as.EqualError(err, "Restart is required to change endpoints, cache.type")
+	s.Cache().SetOrUpdate("third", []byte(`zaza`), DefaultExpirationMarker)
+	itm = s.Cache().GetItem("third")
+	as.InDelta(time.Now().Add(time.Hour).UnixNano(), itm.Expiration, float64(time.Second), "nothing is applied")
+
+	cancel()
+	as.Equal(ErrServerClosed, <-served)
+}
+
+func TestConfigWatcher_Run(t *testing.T) {
+	as := assert.New(t)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "gcache.json")
+	writeServerConfig(t, path, "1m", "secret")
+	c, _ := LoadServerConfig(path)
+	s, err := c.NewServer()
+	if !as.NoError(err) {
+		return
+	}
+	signals := make(chan os.Signal)
+	reloaded := make(chan error)
+	w := &ConfigWatcher{
+		Server:   s,
+		Path:     path,
+		Interval: 5 * time.Millisecond,
+		Signals:  signals,
+		OnReload: func(err error) { reloaded <- err },
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Run(ctx)
+
+	signals <- os.Interrupt
+	as.NoError(<-reloaded, "reload by signal")
+
+	//file is replaced at once, so one modification is seen
+	replace := func(write func(path string), modified time.Time) {
+		tmp := filepath.Join(dir, "tmp.json")
+		write(tmp)
+		os.Chtimes(tmp, modified, modified)
+		os.Rename(tmp, path)
+	}
+	replace(func(path string) { writeServerConfig(t, path, "1h", "secret") }, time.Now().Add(time.Minute))
+	as.NoError(<-reloaded, "reload by modification")
+	s.Cache().SetOrUpdate("first", []byte(`zaza`), DefaultExpirationMarker)
+	as.InDelta(time.Now().Add(time.Hour).UnixNano(), s.Cache().GetItem("first").Expiration, float64(time.Second))
+
+	replace(func(path string) { ioutil.WriteFile(path, []byte(`{`), 0600) }, time.Now().Add(2*time.Minute))
+	as.Error(<-reloaded, "broken file is reported")
+
+	cancel()
+	s.Shutdown(context.Background())
+}
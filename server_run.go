@@ -0,0 +1,574 @@
+package gcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+//DefaultShutdownTimeout limit draining of requests when server is stopped by context of Serve or by DEAD command
+const DefaultShutdownTimeout = 10 * time.Second
+
+var (
+	//ErrServerClosed is returned by Serve after Shutdown
+	ErrServerClosed = errors.New("Server closed")
+	//ErrRestartRequired is returned by Reload when settings which could not be applied live are changed
+	ErrRestartRequired = errors.New("Restart is required")
+)
+
+//Endpoint is an address where server listen in mode
+type Endpoint struct {
+	Mode    string `json:"mode"`    //http, tcp_long, tcp_short, udp, unix, memcache, resp or grpc
+	Address string `json:"address"` //<ip or hostname>:<port>, socket path of unix mode or @name in abstract namespace
+}
+
+//ServerOptions describe endpoints and security of Server
+type ServerOptions struct {
+	Endpoints       []Endpoint
+	TLS             TLSOptions    //tls is enabled for all modes except udp if certificate is set
+	ACL             *ACL          //access is not controlled if nil
+	SnapshotPath    string        //items are loaded by Serve and saved by Shutdown before Cacher.Dead if set
+	ShutdownTimeout time.Duration //DefaultShutdownTimeout if zero, it is used when ctx of Serve is done or DEAD is received
+	SocketPerm      os.FileMode   //permissions of unix socket files, umask is applied if zero
+}
+
+//Validate check modes and tls options
+func (o *ServerOptions) Validate() error {
+	if len(o.Endpoints) == 0 {
+		return errors.New("No endpoints")
+	}
+	if (o.TLS.CertFile == "") != (o.TLS.KeyFile == "") {
+		return errors.New("Both tls-cert and tls-key are required")
+	}
+	if !o.TLS.Enabled() && (o.TLS.ClientCAFile != "" || len(o.TLS.Identities) > 0) {
+		return errors.New("Client certificates require tls-cert")
+	}
+	if len(o.TLS.Identities) > 0 && o.TLS.ClientCAFile == "" {
+		return errors.New("Identities require tls-client-ca")
+	}
+	for _, e := range o.Endpoints {
+		switch e.Mode {
+		case modeHTTP, modeTCPLong, modeTCPShort, modeMemcache, modeRESP, modeGRPC:
+		case modeUnix:
+			if e.Address == "" {
+				return errors.New("Socket path is required in " + modeUnix + " mode")
+			}
+		case modeUDP:
+			if o.TLS.Enabled() {
+				return errors.New("TLS is not supported in " + modeUDP + " mode")
+			}
+		default:
+			return fmt.Errorf("Wrong mode: %s", e.Mode)
+		}
+	}
+	return nil
+}
+
+//Server serve one cache on many endpoints until Shutdown
+type Server struct {
+	cache    Cacher         //WatchCache of NewServer which is served by all endpoints
+	inner    Cacher         //cache which is passed to NewServer, it is reconfigured by Reload
+	config   *ConfigMessage //config of cache created by NewConfigServer
+	opts     ServerOptions
+	security *Security
+
+	l         sync.Mutex
+	closing   bool
+	endpoints []*endpoint
+	conns     map[net.Conn]bool
+	handlers  sync.WaitGroup //connection handlers and udp workers
+	once      sync.Once
+	stopped   chan struct{} //closed when shutdown is finished
+	err       error         //result of shutdown
+	flush     delayedPurge  //flush_all with delay of memcache endpoints
+}
+
+//endpoint is a listener of one mode
+type endpoint struct {
+	Endpoint
+	ln     net.Listener
+	packet *net.UDPConn
+	http   *http.Server
+	grpc   *grpc.Server
+}
+
+//NewServer create server of any cache, cache is dead after Shutdown.
+//Cache is wrapped with one WatchCache so grpc watchers see changes of all endpoints
+func NewServer(cache Cacher, opts ServerOptions) (*Server, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	if opts.ShutdownTimeout <= 0 {
+		opts.ShutdownTimeout = DefaultShutdownTimeout
+	}
+	watch, ok := cache.(*WatchCache)
+	if !ok {
+		watch = NewWatchCache(cache)
+	}
+	s := newServer(watch, &Security{ACL: opts.ACL, TLS: &opts.TLS})
+	s.inner = cache
+	s.opts = opts
+	return s, nil
+}
+
+//NewConfigServer create server of cache described by config, see NewCache
+func NewConfigServer(config *ConfigMessage, opts ServerOptions) (*Server, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	cache, err := NewCache(config)
+	if err != nil {
+		return nil, err
+	}
+	s, err := NewServer(cache, opts)
+	if err != nil {
+		return nil, err
+	}
+	s.config = proto.Clone(config).(*ConfigMessage)
+	return s, nil
+}
+
+func newServer(cache Cacher, sec *Security) *Server {
+	return &Server{
+		cache:    cache,
+		inner:    cache,
+		security: sec,
+		opts:     ServerOptions{ShutdownTimeout: DefaultShutdownTimeout},
+		conns:    make(map[net.Conn]bool),
+		stopped:  make(chan struct{}),
+	}
+}
+
+//Cache return served cache, its changes are published to grpc watchers
+func (s *Server) Cache() Cacher {
+	return s.cache
+}
+
+func (s *Server) tls() *TLSOptions {
+	if s.security == nil || !s.security.TLS.Enabled() {
+		return nil
+	}
+	return s.security.TLS
+}
+
+//Addr return listening address of first endpoint of mode, it is nil until Serve open listeners
+func (s *Server) Addr(mode string) net.Addr {
+	s.l.Lock()
+	defer s.l.Unlock()
+	for _, e := range s.endpoints {
+		if e.Mode != mode {
+			continue
+		}
+		if e.packet != nil {
+			return e.packet.LocalAddr()
+		}
+		return e.ln.Addr()
+	}
+	return nil
+}
+
+//Serve load snapshot, listen all endpoints and handle requests until Shutdown.
+//Shutdown with ShutdownTimeout is started when ctx is done or one of endpoints is failed,
+// ErrServerClosed is returned after graceful shutdown is finished
+func (s *Server) Serve(ctx context.Context) error {
+	if s.opts.SnapshotPath != "" {
+		if _, err := LoadSnapshot(s.opts.SnapshotPath, s.inner); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	var endpoints []*endpoint
+	for _, e := range s.opts.Endpoints {
+		ep, err := s.listen(e)
+		if err == nil {
+			err = s.add(ep)
+		}
+		if err != nil {
+			s.remove(endpoints)
+			return err
+		}
+		endpoints = append(endpoints, ep)
+	}
+
+	served := make(chan error, len(endpoints))
+	for _, ep := range endpoints {
+		go func(ep *endpoint) { served <- s.accept(ep) }(ep)
+	}
+	var err error
+	select {
+	case err = <-served:
+		if s.isClosing() { //stopped by Shutdown or DEAD
+			<-s.stopped
+			return ErrServerClosed
+		}
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout())
+	defer cancel()
+	if shutdownErr := s.Shutdown(shutdownCtx); err == nil {
+		err = shutdownErr
+	}
+	if err == nil {
+		err = ErrServerClosed
+	}
+	return err
+}
+
+//listen open listener of endpoint
+func (s *Server) listen(e Endpoint) (*endpoint, error) {
+	ep := &endpoint{Endpoint: e}
+	if e.Mode == modeUDP {
+		addr, err := net.ResolveUDPAddr("udp", e.Address)
+		if err != nil {
+			return nil, err
+		}
+		if ep.packet, err = net.ListenUDP("udp", addr); err != nil {
+			return nil, err
+		}
+		return ep, nil
+	}
+	var (
+		ln  net.Listener
+		err error
+	)
+	if e.Mode == modeUnix {
+		ln, err = listenUnix(e.Address, s.opts.SocketPerm)
+	} else {
+		ln, err = net.Listen("tcp", e.Address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if opts := s.tls(); opts != nil && e.Mode != modeGRPC { //grpc use own tls credentials
+		tlsLn, err := opts.Listener(ln)
+		if err != nil {
+			ln.Close()
+			return nil, err
+		}
+		ln = tlsLn
+	}
+	ep.ln = ln
+	return ep, nil
+}
+
+//add create server of endpoint mode and register endpoint, listener is closed on error
+func (s *Server) add(ep *endpoint) error {
+	s.l.Lock()
+	defer s.l.Unlock()
+	err := ErrServerClosed
+	if !s.closing {
+		err = s.create(ep)
+	}
+	if err != nil {
+		ep.close()
+		return err
+	}
+	s.endpoints = append(s.endpoints, ep)
+	return nil
+}
+
+//remove unregister endpoints and close their listeners
+func (s *Server) remove(endpoints []*endpoint) {
+	s.l.Lock()
+	defer s.l.Unlock()
+	var kept []*endpoint //shutdown may iterate old slice
+	for _, registered := range s.endpoints {
+		removed := false
+		for _, ep := range endpoints {
+			removed = removed || ep == registered
+		}
+		if !removed {
+			kept = append(kept, registered)
+		}
+	}
+	s.endpoints = kept
+	for _, ep := range endpoints {
+		ep.close()
+	}
+}
+
+//create server of http or grpc mode
+func (s *Server) create(ep *endpoint) error {
+	switch ep.Mode {
+	case modeHTTP:
+		handler := NewHTTPHandler(s.cache)
+		handler.Security = s.security
+		ep.http = &http.Server{Handler: handler}
+	case modeGRPC:
+		var opts []grpc.ServerOption
+		if tlsOpts := s.tls(); tlsOpts != nil {
+			cfg, err := tlsOpts.Config()
+			if err != nil {
+				return err
+			}
+			opts = append(opts, grpc.Creds(credentials.NewTLS(cfg)))
+		}
+		ep.grpc = newGRPCServer(s.cache, s.security, opts...)
+	case modeTCPLong, modeTCPShort, modeUnix, modeMemcache, modeRESP, modeUDP:
+	default:
+		return fmt.Errorf("Wrong mode: %s", ep.Mode)
+	}
+	return nil
+}
+
+func (ep *endpoint) close() {
+	if ep.ln != nil {
+		ep.ln.Close()
+	}
+	if ep.packet != nil {
+		ep.packet.Close()
+	}
+}
+
+//accept serve connections of endpoint, error is returned when listener is closed
+func (s *Server) accept(ep *endpoint) error {
+	switch ep.Mode {
+	case modeHTTP:
+		if err := ep.http.Serve(ep.ln); err != http.ErrServerClosed {
+			return err
+		}
+		return net.ErrClosed
+	case modeGRPC:
+		if err := ep.grpc.Serve(ep.ln); err != nil {
+			return err
+		}
+		return net.ErrClosed
+	case modeUDP:
+		return s.serveUDP(ep.packet)
+	case modeTCPLong, modeUnix:
+		return s.acceptConns(ep.ln, s.serveLongTCP)
+	case modeTCPShort:
+		return s.acceptConns(ep.ln, s.serveShortTCP)
+	case modeMemcache:
+		return s.acceptConns(ep.ln, func(conn net.Conn) {
+			serveMemcache(conn, s.cache, s.security.session(conn), &s.flush)
+		})
+	default:
+		return s.acceptConns(ep.ln, func(conn net.Conn) {
+			serveRESP(conn, s.cache, s.security.session(conn))
+		})
+	}
+}
+
+//acceptConns accept connections until listener is closed, every connection is served in own gorutine
+func (s *Server) acceptConns(ln net.Listener, serve func(net.Conn)) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return err
+			}
+			continue // some error appear do not try to handle income request
+		}
+		if !s.track(conn, 1) {
+			conn.Close()
+			continue
+		}
+		go func() {
+			defer s.untrack(conn)
+			serve(conn)
+			conn.Close()
+		}()
+	}
+}
+
+//track register n handlers of connection, conn is nil for udp workers, false is returned during shutdown
+func (s *Server) track(conn net.Conn, n int) bool {
+	s.l.Lock()
+	defer s.l.Unlock()
+	if s.closing {
+		return false
+	}
+	if conn != nil {
+		s.conns[conn] = true
+	}
+	s.handlers.Add(n)
+	return true
+}
+
+func (s *Server) untrack(conn net.Conn) {
+	if conn != nil {
+		s.l.Lock()
+		delete(s.conns, conn)
+		s.l.Unlock()
+	}
+	s.handlers.Done()
+}
+
+func (s *Server) isClosing() bool {
+	s.l.Lock()
+	defer s.l.Unlock()
+	return s.closing
+}
+
+func (s *Server) shutdownTimeout() time.Duration {
+	s.l.Lock()
+	defer s.l.Unlock()
+	return s.opts.ShutdownTimeout
+}
+
+//Reload apply ACL and ShutdownTimeout of opts and DefaultExpiration and SizeLimit of cache config without restart.
+//Nothing is applied and ErrRestartRequired is returned if other settings are changed,
+// config should be nil if server was not created by NewConfigServer
+func (s *Server) Reload(config *ConfigMessage, opts ServerOptions) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	if opts.ShutdownTimeout <= 0 {
+		opts.ShutdownTimeout = DefaultShutdownTimeout
+	}
+	s.l.Lock()
+	defer s.l.Unlock()
+	var changed []string
+	if !reflect.DeepEqual(opts.Endpoints, s.opts.Endpoints) {
+		changed = append(changed, "endpoints")
+	}
+	if !reflect.DeepEqual(opts.TLS, s.opts.TLS) {
+		changed = append(changed, "tls")
+	}
+	if opts.SnapshotPath != s.opts.SnapshotPath {
+		changed = append(changed, "snapshot")
+	}
+	if opts.SocketPerm != s.opts.SocketPerm {
+		changed = append(changed, "socket_perm")
+	}
+	cache, reconfigurable := s.inner.(Reconfigurable)
+	switch {
+	case config == nil:
+	case s.config == nil || !reconfigurable:
+		changed = append(changed, "cache")
+	default:
+		changed = append(changed, restartCacheChanges(s.config, config)...)
+	}
+	if len(changed) > 0 {
+		return fmt.Errorf("%w to change %s", ErrRestartRequired, strings.Join(changed, ", "))
+	}
+
+	if config != nil {
+		cache.Reconfigure(config)
+		s.config = proto.Clone(config).(*ConfigMessage)
+	}
+	s.security.SetACL(opts.ACL)
+	s.opts.ACL = opts.ACL
+	s.opts.ShutdownTimeout = opts.ShutdownTimeout
+	return nil
+}
+
+//restartCacheChanges return names of changed cache settings which could not be applied live
+func restartCacheChanges(current, next *ConfigMessage) []string {
+	var changed []string
+	if current.GetCacheType() != next.GetCacheType() {
+		changed = append(changed, "cache.type")
+	}
+	if current.GetShardCount() != next.GetShardCount() {
+		changed = append(changed, "cache.shards")
+	}
+	if current.GetHashFunc() != next.GetHashFunc() {
+		changed = append(changed, "cache.hash")
+	}
+	if current.GetMemoryLimit() != next.GetMemoryLimit() {
+		changed = append(changed, "cache.memory_limit")
+	}
+	if current.GetIsKeepUsefull() != next.GetIsKeepUsefull() {
+		changed = append(changed, "cache.keep_usefull")
+	}
+	return changed
+}
+
+//stop start shutdown after DEAD command without waiting of it
+func (s *Server) stop() {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout())
+		defer cancel()
+		s.Shutdown(ctx)
+	}()
+}
+
+//Shutdown stop accepting of connections and wait in-flight requests until ctx is done,
+// then save snapshot if SnapshotPath is set and call Cacher.Dead.
+//Connections which are not finished in time are closed and ctx error is returned, cache is dead anyway.
+//Next calls wait the first one and return its result
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.once.Do(func() {
+		s.err = s.shutdown(ctx)
+		close(s.stopped)
+	})
+	return s.err
+}
+
+func (s *Server) shutdown(ctx context.Context) error {
+	s.l.Lock()
+	s.closing = true
+	now := time.Now()
+	for conn := range s.conns {
+		conn.SetReadDeadline(now) //handler finish current request and stop on next read
+	}
+	endpoints := s.endpoints
+	for _, ep := range endpoints {
+		switch {
+		case ep.packet != nil:
+			ep.packet.SetReadDeadline(now)
+		case ep.http == nil && ep.grpc == nil:
+			ep.ln.Close()
+		}
+	}
+	s.l.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		for _, ep := range endpoints {
+			if ep.http != nil {
+				ep.http.Shutdown(ctx)
+			}
+			if ep.grpc != nil {
+				ep.grpc.GracefulStop()
+			}
+		}
+		s.handlers.Wait()
+		close(drained)
+	}()
+	var err error
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		err = ctx.Err()
+		for _, ep := range endpoints {
+			if ep.http != nil {
+				ep.http.Close()
+			}
+			if ep.grpc != nil {
+				ep.grpc.Stop()
+			}
+		}
+		s.l.Lock()
+		for conn := range s.conns {
+			conn.Close()
+		}
+		s.l.Unlock()
+		<-drained
+	}
+	for _, ep := range endpoints {
+		if ep.packet != nil {
+			ep.packet.Close()
+		}
+	}
+
+	s.flush.stop()
+	if s.opts.SnapshotPath != "" {
+		if snapErr := SaveSnapshot(s.opts.SnapshotPath, s.inner); snapErr != nil && err == nil {
+			err = snapErr
+		}
+	}
+	s.cache.Dead()
+	return err
+}
@@ -0,0 +1,313 @@
+package gcache
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+//blockingCache block GetItem until release is closed
+type blockingCache struct {
+	Cacher
+	entered chan struct{}
+	release chan struct{}
+}
+
+func newBlockingCache(cache Cacher) *blockingCache {
+	return &blockingCache{Cacher: cache, entered: make(chan struct{}, 1), release: make(chan struct{})}
+}
+
+func (c *blockingCache) GetItem(name string) *Item {
+	c.entered <- struct{}{}
+	<-c.release
+	return c.Cacher.GetItem(name)
+}
+
+//startServer run server of modes on random local ports and wait its listeners
+func startServer(t *testing.T, cache Cacher, opts ServerOptions, modes ...string) (*Server, context.CancelFunc, <-chan error) {
+	for _, mode := range modes {
+		opts.Endpoints = append(opts.Endpoints, Endpoint{Mode: mode, Address: "127.0.0.1:0"})
+	}
+	s, err := NewServer(cache, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(ctx) }()
+	for _, mode := range modes {
+		for i := 0; s.Addr(mode) == nil; i++ {
+			if i > 500 {
+				t.Fatal("Server is not started")
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	return s, cancel, served
+}
+
+//sendGet write GET request via tcp_long connection
+func sendGet(t *testing.T, addr net.Addr, name string) net.Conn {
+	conn, err := net.Dial("tcp", addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := proto.Marshal(&ItemMessage{Command: ItemMessage_GET, Name: name})
+	WriteFrame(conn, data)
+	return conn
+}
+
+func TestServer_ShutdownDrain(t *testing.T) {
+	as := assert.New(t)
+	inner := NewRwCache(&ConfigMessage{})
+	inner.SetOrUpdate("first", []byte(`zaza`), NoExpiration)
+	cache := newBlockingCache(inner)
+	s, cancel, served := startServer(t, cache, ServerOptions{}, modeTCPLong)
+	defer cancel()
+
+	conn := sendGet(t, s.Addr(modeTCPLong), "first")
+	defer conn.Close()
+	<-cache.entered
+
+	stopped := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		stopped <- s.Shutdown(ctx)
+	}()
+	select {
+	case <-stopped:
+		t.Fatal("Shutdown does not wait in-flight request")
+	case <-time.After(20 * time.Millisecond):
+	}
+	_, err := net.Dial("tcp", s.Addr(modeTCPLong).String())
+	as.Error(err, "new connections are not accepted")
+
+	close(cache.release)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	data, err := NewFrameReader(conn, 0).ReadFrame()
+	if as.NoError(err) {
+		resp := &ItemMessage{}
+		as.NoError(proto.Unmarshal(data, resp))
+		as.Equal([]byte(`zaza`), resp.GetObject())
+	}
+	as.NoError(<-stopped)
+	as.Equal(ErrServerClosed, <-served)
+	as.Nil(inner.Get("first"), "cache is dead")
+	as.NoError(s.Shutdown(context.Background()), "second call return result of first one")
+}
+
+func TestServer_ShutdownTimeout(t *testing.T) {
+	as := assert.New(t)
+	inner := NewRwCache(&ConfigMessage{})
+	cache := newBlockingCache(inner)
+	s, cancel, served := startServer(t, cache, ServerOptions{}, modeTCPLong)
+	defer cancel()
+
+	conn := sendGet(t, s.Addr(modeTCPLong), "first")
+	defer conn.Close()
+	<-cache.entered
+	time.AfterFunc(100*time.Millisecond, func() { close(cache.release) })
+
+	ctx, cancelShutdown := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancelShutdown()
+	as.Equal(context.DeadlineExceeded, s.Shutdown(ctx))
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err := NewFrameReader(conn, 0).ReadFrame()
+	as.Error(err, "connection is closed without responce")
+	as.Equal(ErrServerClosed, <-served)
+}
+
+func TestServer_ServeContext(t *testing.T) {
+	as := assert.New(t)
+	path := filepath.Join(t.TempDir(), "snapshot")
+	c := NewRwCache(&ConfigMessage{})
+	c.SetOrUpdate("first", []byte(`zaza`), NoExpiration)
+	modes := []string{modeHTTP, modeTCPLong, modeTCPShort, modeUDP, modeMemcache, modeRESP, modeGRPC}
+	s, cancel, served := startServer(t, c, ServerOptions{SnapshotPath: path}, modes...)
+
+	remote, err := NewRemoteCache(s.Addr(modeTCPLong).String(), RemoteOptions{})
+	if as.NoError(err) {
+		remote.SetOrUpdate("second", []byte(`azaz`), NoExpiration)
+		remote.Dead()
+	}
+	resp, err := http.Get("http://" + s.Addr(modeHTTP).String() + keysPath + "second")
+	if as.NoError(err) {
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		as.Equal([]byte(`azaz`), body, "modes share one cache")
+	}
+
+	cancel()
+	as.Equal(ErrServerClosed, <-served)
+	as.Nil(c.Get("first"), "cache is dead")
+	for _, mode := range modes {
+		_, err := net.Dial("tcp", s.Addr(mode).String())
+		as.Error(err, mode)
+	}
+
+	restored := NewRwCache(&ConfigMessage{})
+	s, cancel, served = startServer(t, restored, ServerOptions{SnapshotPath: path}, modeTCPLong)
+	as.Equal([]byte(`zaza`), restored.Get("first"), "snapshot is loaded by Serve")
+	as.Equal([]byte(`azaz`), restored.Get("second"))
+	cancel()
+	as.Equal(ErrServerClosed, <-served)
+}
+
+func TestServer_ServeListenError(t *testing.T) {
+	as := assert.New(t)
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if !as.NoError(err) {
+		return
+	}
+	defer busy.Close()
+	free, err := net.Listen("tcp", "127.0.0.1:0")
+	if !as.NoError(err) {
+		return
+	}
+	address := free.Addr().String()
+	free.Close()
+
+	c := NewRwCache(&ConfigMessage{})
+	s, err := NewServer(c, ServerOptions{Endpoints: []Endpoint{
+		{Mode: modeHTTP, Address: address},
+		{Mode: modeTCPLong, Address: busy.Addr().String()},
+	}})
+	if !as.NoError(err) {
+		return
+	}
+	as.Error(s.Serve(context.Background()))
+	as.Nil(s.Addr(modeHTTP), "endpoint is removed when other one is failed")
+	ln, err := net.Listen("tcp", address)
+	if as.NoError(err, "listener of removed endpoint is closed") {
+		ln.Close()
+	}
+	as.NoError(s.Shutdown(context.Background()))
+}
+
+func TestServer_WatchAllModes(t *testing.T) {
+	as := assert.New(t)
+	s, cancel, served := startServer(t, NewRwCache(&ConfigMessage{}), ServerOptions{}, modeTCPLong, modeGRPC, modeMemcache)
+	defer func() {
+		cancel()
+		<-served
+	}()
+	conn, err := grpc.Dial(s.Addr(modeGRPC).String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if !as.NoError(err) {
+		return
+	}
+	defer conn.Close()
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	stream, err := NewGRPCCache(conn, time.Second).Watch(ctx, "user:")
+	if !as.NoError(err) {
+		return
+	}
+	received := make(chan *ItemMessage, 10)
+	go func() {
+		for {
+			ev, err := stream.Recv()
+			if err != nil {
+				close(received)
+				return
+			}
+			received <- ev
+		}
+	}()
+
+	remote, err := NewRemoteCache(s.Addr(modeTCPLong).String(), RemoteOptions{})
+	if !as.NoError(err) {
+		return
+	}
+	defer remote.Dead()
+	//watcher is registered asynchronously, repeat write until it is seen
+	var ev *ItemMessage
+	for i := 0; ev == nil && i < 100; i++ {
+		remote.SetOrUpdate("user:1", []byte(`zaza`), NoExpiration)
+		select {
+		case ev = <-received:
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	if !as.NotNil(ev, "write via tcp_long is published") {
+		return
+	}
+	as.Equal(ItemMessage_SET, ev.Command)
+	as.Equal("user:1", ev.Name)
+
+	mc, err := net.Dial("tcp", s.Addr(modeMemcache).String())
+	if !as.NoError(err) {
+		return
+	}
+	defer mc.Close()
+	fmt.Fprint(mc, "set user:2 5 0 4\r\nzaza\r\nget user:2\r\n")
+	reply := make([]byte, len("STORED\r\nVALUE user:2 5 4\r\nzaza\r\nEND\r\n"))
+	_, err = io.ReadFull(mc, reply)
+	as.NoError(err)
+	as.Equal("STORED\r\nVALUE user:2 5 4\r\nzaza\r\nEND\r\n", string(reply), "flags are kept by watched cache")
+	for ev = range received {
+		if ev.Name == "user:2" {
+			break
+		}
+	}
+	as.Equal("user:2", ev.GetName(), "write via memcache is published")
+}
+
+func TestNewConfigServer(t *testing.T) {
+	as := assert.New(t)
+	opts := ServerOptions{Endpoints: []Endpoint{{Mode: modeTCPShort, Address: "127.0.0.1:0"}}}
+	for _, config := range []*ConfigMessage{
+		{},
+		{CacheType: ConfigMessage_SINGLEGORUTINE, ShardCount: 4, SizeLimit: 100},
+		{CacheType: ConfigMessage_OFFHEAP, MemoryLimit: 1 << 20},
+		{ShardCount: 2, HashFunc: "crc"},
+	} {
+		s, err := NewConfigServer(config, opts)
+		if as.NoError(err, config.String()) {
+			_, err = s.Cache().Add("first", []byte(`zaza`), NoExpiration)
+			as.NoError(err)
+			as.Equal([]byte(`zaza`), s.Cache().Get("first"))
+			as.NoError(s.Shutdown(context.Background()))
+		}
+	}
+	_, err := NewConfigServer(&ConfigMessage{CacheType: ConfigMessage_REMOTE}, opts)
+	as.Error(err)
+	_, err = NewConfigServer(&ConfigMessage{HashFunc: "md5"}, opts)
+	as.Error(err)
+
+	for _, wrong := range []ServerOptions{
+		{},
+		{Endpoints: []Endpoint{{Mode: "fly"}}},
+		{Endpoints: []Endpoint{{Mode: modeUDP}}, TLS: TLSOptions{CertFile: "cert", KeyFile: "key"}},
+	} {
+		_, err = NewServer(NewRwCache(&ConfigMessage{}), wrong)
+		as.Error(err)
+	}
+}
+
+func TestServer_DeadCommand(t *testing.T) {
+	as := assert.New(t)
+	s, _, served := startServer(t, NewRwCache(&ConfigMessage{}), ServerOptions{}, modeTCPLong)
+
+	conn, err := net.Dial("tcp", s.Addr(modeTCPLong).String())
+	if !as.NoError(err) {
+		return
+	}
+	defer conn.Close()
+	data, _ := proto.Marshal(&ItemMessage{Command: ItemMessage_DEAD})
+	WriteFrame(conn, data)
+	as.Equal(ErrServerClosed, <-served)
+	_, err = NewFrameReader(conn, 0).ReadFrame()
+	as.NoError(err, "responce is sent before shutdown")
+}
@@ -0,0 +1,172 @@
+package gcache
+
+import (
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+func requestMessage(t *testing.T, req *ItemMessage, c Cacher) (*ItemMessage, error) {
+	data, err := handleRequest(req, c)
+	resp := &ItemMessage{}
+	if len(data) > 0 {
+		assert.NoError(t, proto.Unmarshal(data, resp))
+	}
+	return resp, err
+}
+
+func TestHandleRequest_Incr(t *testing.T) {
+	as := assert.New(t)
+	c := NewRwCache(defaultConfig())
+
+	resp, err := requestMessage(t, &ItemMessage{Command: ItemMessage_INCR, Name: "counter", Delta: 3}, c)
+	as.NoError(err)
+	as.Equal([]byte(`3`), resp.GetObject())
+	resp, err = requestMessage(t, &ItemMessage{Command: ItemMessage_DECR, Name: "counter", Delta: 5}, c)
+	as.NoError(err)
+	as.Equal([]byte(`-2`), resp.GetObject())
+
+	c.SetOrUpdate("text", []byte(`zaza`), DefaultExpirationMarker)
+	resp, err = requestMessage(t, &ItemMessage{Command: ItemMessage_INCR, Name: "text", Delta: 1}, c)
+	as.Equal(ErrNotInteger, err)
+	as.Nil(resp.GetObject())
+
+	c.Dead() //Cleanup
+}
+
+func TestHandleRequest_CAS(t *testing.T) {
+	as := assert.New(t)
+	c := NewRwCache(defaultConfig())
+
+	resp, err := requestMessage(t, &ItemMessage{Command: ItemMessage_ADD, Name: "lock", Object: []byte(`owner`)}, c)
+	as.NoError(err)
+	version := resp.GetVersion()
+	as.NotZero(version)
+	resp, err = requestMessage(t, &ItemMessage{Command: ItemMessage_ADD, Name: "lock", Object: []byte(`other`)}, c)
+	as.Equal(ErrExists, err)
+	as.Zero(resp.GetVersion())
+
+	resp, err = requestMessage(t, &ItemMessage{Command: ItemMessage_GET, Name: "lock"}, c)
+	as.NoError(err)
+	as.Equal(version, resp.GetVersion())
+	as.Equal([]byte(`owner`), resp.GetObject())
+
+	resp, err = requestMessage(t, &ItemMessage{Command: ItemMessage_CAS, Name: "lock", Object: []byte(`next`), Version: version}, c)
+	as.NoError(err)
+	as.NotEqual(version, resp.GetVersion())
+	_, err = requestMessage(t, &ItemMessage{Command: ItemMessage_CAS, Name: "lock", Object: []byte(`late`), Version: version}, c)
+	as.Equal(ErrVersionMismatch, err)
+
+	_, err = requestMessage(t, &ItemMessage{Command: ItemMessage_REPLACE, Name: "absent", Object: []byte(`zaza`)}, c)
+	as.Equal(ErrNotFound, err)
+	resp, err = requestMessage(t, &ItemMessage{Command: ItemMessage_REPLACE, Name: "lock", Object: []byte(`zaza`)}, c)
+	as.NoError(err)
+	as.NotZero(resp.GetVersion())
+	as.Equal([]byte(`zaza`), c.Get("lock"))
+
+	c.Dead() //Cleanup
+}
+
+func TestHandleRequest_Status(t *testing.T) {
+	as := assert.New(t)
+	c := NewRwCache(defaultConfig())
+
+	for _, tc := range []struct {
+		req    *ItemMessage
+		status ItemMessage_Statuses
+	}{
+		{&ItemMessage{Command: ItemMessage_GET, Name: "first"}, ItemMessage_NOT_FOUND},
+		{&ItemMessage{Command: ItemMessage_SET, Name: "first", Object: []byte(`zaza`)}, ItemMessage_OK},
+		{&ItemMessage{Command: ItemMessage_GET, Name: "first"}, ItemMessage_OK},
+		{&ItemMessage{Command: ItemMessage_ADD, Name: "first"}, ItemMessage_EXISTS},
+		{&ItemMessage{Command: ItemMessage_CAS, Name: "first", Version: 100}, ItemMessage_EXISTS},
+		{&ItemMessage{Command: ItemMessage_INCR, Name: "first", Delta: 1}, ItemMessage_INVALID},
+		{&ItemMessage{Command: ItemMessage_SET, Name: "broken", Object: []byte{42}, Compressed: true}, ItemMessage_INVALID},
+		{&ItemMessage{Command: ItemMessage_Commands(100)}, ItemMessage_INVALID},
+		{&ItemMessage{Command: ItemMessage_DELETE, Name: "first"}, ItemMessage_OK},
+		{&ItemMessage{Command: ItemMessage_DELETE, Name: "first"}, ItemMessage_NOT_FOUND},
+		{&ItemMessage{Command: ItemMessage_PURGE}, ItemMessage_OK},
+	} {
+		data, _ := handleRequest(tc.req, c)
+		resp := &ItemMessage{}
+		as.NoError(proto.Unmarshal(data, resp))
+		as.Equal(tc.status, resp.GetStatus(), tc.req.String())
+		as.Equal(tc.status == ItemMessage_OK, resp.GetError() == "", tc.req.String())
+	}
+
+	c.Dead() //Cleanup
+}
+
+func TestHandleShortTCP_Reply(t *testing.T) {
+	as := assert.New(t)
+	c := NewRwCache(defaultConfig())
+	ln, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if !as.NoError(err) {
+		return
+	}
+	go handleShortTCP(ln, c, nil)
+
+	request := func(data []byte) *ItemMessage {
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		if !as.NoError(err) {
+			return nil
+		}
+		defer conn.Close()
+		conn.Write(data)
+		conn.(*net.TCPConn).CloseWrite()
+		data, err = ioutil.ReadAll(conn)
+		as.NoError(err)
+		resp := &ItemMessage{}
+		as.NoError(proto.Unmarshal(data, resp))
+		return resp
+	}
+	set, _ := proto.Marshal(&ItemMessage{Command: ItemMessage_SET, Name: "first", Object: []byte(`zaza`)})
+	as.Equal(ItemMessage_OK, request(set).GetStatus())
+	get, _ := proto.Marshal(&ItemMessage{Command: ItemMessage_GET, Name: "first"})
+	as.Equal([]byte(`zaza`), request(get).GetObject())
+	as.Equal(ItemMessage_INVALID, request([]byte{0xff, 0xff}).GetStatus())
+	dead, _ := proto.Marshal(&ItemMessage{Command: ItemMessage_DEAD})
+	as.Equal(ItemMessage_OK, request(dead).GetStatus())
+}
+
+func TestHandleUDP_Reply(t *testing.T) {
+	as := assert.New(t)
+	c := NewRwCache(defaultConfig())
+	ln, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if !as.NoError(err) {
+		return
+	}
+	go HandleUDP(ln, c)
+
+	conn, err := net.Dial("udp", ln.LocalAddr().String())
+	if !as.NoError(err) {
+		return
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, maxPacketSize)
+	for _, req := range []*ItemMessage{
+		{Command: ItemMessage_SET, Name: "first", Object: []byte(`zaza`)},
+		{Command: ItemMessage_PURGE},
+		{Command: ItemMessage_GET, Name: "first"},
+		{Command: ItemMessage_DEAD},
+	} {
+		data, _ := proto.Marshal(req)
+		conn.Write(data)
+		n, err := conn.Read(buf)
+		if !as.NoError(err, req.String()) {
+			return
+		}
+		resp := &ItemMessage{}
+		as.NoError(proto.Unmarshal(buf[:n], resp))
+		if req.Command == ItemMessage_GET {
+			as.Equal(ItemMessage_NOT_FOUND, resp.GetStatus())
+		} else {
+			as.Equal(ItemMessage_OK, resp.GetStatus(), req.String())
+		}
+	}
+}
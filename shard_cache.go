@@ -34,6 +34,15 @@ func NewShardCache(config ConfigShardCacheInterface, generator ShardGenerator, h
 	return c
 }
 
+//newShardCacheOf create shard cache of ready shards
+func newShardCacheOf(shards []Cacher, hashCalc HashCalculator) *ShardCache {
+	return &ShardCache{
+		shardCount: uint64(len(shards)),
+		shards:     shards,
+		hashCalc:   hashCalc,
+	}
+}
+
 func (c *ShardCache) getShard(str string) Cacher {
 	key := c.hashCalc(str)
 	return c.shards[key%c.shardCount]
@@ -49,6 +58,55 @@ func (c *ShardCache) SetOrUpdate(name string, value []byte, expriation time.Dura
 	c.getShard(name).SetOrUpdate(name, value, expriation)
 }
 
+//Incr add delta to decimal item or create it
+func (c *ShardCache) Incr(name string, delta int64, expriation time.Duration) (int64, error) {
+	return c.getShard(name).Incr(name, delta, expriation)
+}
+
+//Decr subtract delta from decimal item or create it
+func (c *ShardCache) Decr(name string, delta int64, expriation time.Duration) (int64, error) {
+	return c.getShard(name).Decr(name, delta, expriation)
+}
+
+//GetItem return copy of item by name or nil
+func (c *ShardCache) GetItem(name string) *Item {
+	return c.getShard(name).GetItem(name)
+}
+
+//Add set item only if it is absent
+func (c *ShardCache) Add(name string, value []byte, expriation time.Duration) (uint64, error) {
+	return c.getShard(name).Add(name, value, expriation)
+}
+
+//Replace set item only if it is present
+func (c *ShardCache) Replace(name string, value []byte, expriation time.Duration) (uint64, error) {
+	return c.getShard(name).Replace(name, value, expriation)
+}
+
+//CAS set item only if its version is not changed
+func (c *ShardCache) CAS(name string, value []byte, version uint64, expriation time.Duration) (uint64, error) {
+	return c.getShard(name).CAS(name, value, version, expriation)
+}
+
+//StoreFlags write item with client flags of memcached protocol, flags are dropped if shard is not FlagsCacher
+func (c *ShardCache) StoreFlags(cmd ItemMessage_Commands, name string, value []byte, flags uint32, version uint64, exp time.Duration) (uint64, error) {
+	return storeFlags(c.getShard(name), cmd, name, value, flags, version, exp)
+}
+
+//Delete item from the cache
+func (c *ShardCache) Delete(name string) bool {
+	return c.getShard(name).Delete(name)
+}
+
+//Keys return names of all live items from all shards
+func (c *ShardCache) Keys() []string {
+	var keys []string
+	for i := range c.shards {
+		keys = append(keys, c.shards[i].Keys()...)
+	}
+	return keys
+}
+
 //Purge delete all items from the cache
 func (c *ShardCache) Purge() {
 	for i := range c.shards {
@@ -64,6 +122,15 @@ func (c *ShardCache) Dead() {
 	c.shards = c.shards[:0]
 }
 
+//Reconfigure apply config to every shard which is Reconfigurable, SizeLimit is a limit of one shard
+func (c *ShardCache) Reconfigure(config ConfigCacheInterface) {
+	for _, shard := range c.shards {
+		if r, ok := shard.(Reconfigurable); ok {
+			r.Reconfigure(config)
+		}
+	}
+}
+
 //Statistic return all cache statistic
 func (c *ShardCache) Statistic() Stats {
 	s := Stats{}
@@ -76,6 +143,8 @@ func (c *ShardCache) Statistic() Stats {
 		atomic.AddInt64(&s.ItemsCount, shardStat.ItemsCount)
 		atomic.AddInt64(&s.SetOrReplaceCount, shardStat.SetOrReplaceCount)
 		atomic.AddInt64(&s.SizeLimit, shardStat.SizeLimit)
+		atomic.AddInt64(&s.RawBytes, shardStat.RawBytes)
+		atomic.AddInt64(&s.CompressedBytes, shardStat.CompressedBytes)
 	}
 	return s
 }
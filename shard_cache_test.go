@@ -81,3 +81,22 @@ func TestShardCache_SetOrUpdate(t *testing.T) {
 
 	c.Dead() //Cleanup
 }
+
+func TestShardCache_Incr(t *testing.T) {
+	as := assert.New(t)
+	c := getCacheGorBase()
+	for i := 0; i < 3; i++ {
+		c.Incr("first", 2, DefaultExpirationMarker)
+		c.Decr("second", 1, DefaultExpirationMarker)
+	}
+	as.Equal([]byte(`6`), c.Get("first"))
+	as.Equal([]byte(`-3`), c.Get("second"))
+	as.Equal(int64(2), c.Statistic().ItemsCount)
+	c.Dead() //Cleanup
+}
+
+func TestShardCache_CAS(t *testing.T) {
+	c := getCacheGorBase()
+	checkVersionedCacher(t, c)
+	c.Dead() //Cleanup
+}
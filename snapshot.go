@@ -0,0 +1,120 @@
+package gcache
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+)
+
+//SealedCacher is a cache which transform values before storing them in inner cache, see EncryptCache.
+//Snapshots are written and read via inner cache so values are saved in sealed form
+type SealedCacher interface {
+	Sealed() Cacher
+}
+
+//snapshotCache return the innermost sealed cache
+func snapshotCache(cache Cacher) Cacher {
+	for {
+		sealed, ok := cache.(SealedCacher)
+		if !ok {
+			return cache
+		}
+		cache = sealed.Sealed()
+	}
+}
+
+//WriteSnapshot write all items of cache as frames of SET ItemMessage, expiration is absolute time in nanoseconds.
+//Values of SealedCacher are written as they are stored in its inner cache
+func WriteSnapshot(w io.Writer, cache Cacher) error {
+	cache = snapshotCache(cache)
+	writer := bufio.NewWriter(w)
+	for _, name := range cache.Keys() {
+		itm := cache.GetItem(name)
+		if itm == nil {
+			continue //expired or deleted meanwhile
+		}
+		data, err := proto.Marshal(&ItemMessage{
+			Command:    ItemMessage_SET,
+			Name:       name,
+			Object:     itm.Object,
+			Expiration: itm.Expiration,
+		})
+		if err != nil {
+			return err
+		}
+		if err = WriteFrame(writer, data); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
+
+//ReadSnapshot restore items written by WriteSnapshot and return number of restored items, expired items are skipped.
+//Items bigger than DefaultMaxFrameSize are skipped too, so one large item does not prevent loading of others.
+//Values of SealedCacher are restored into its inner cache as they are
+func ReadSnapshot(r io.Reader, cache Cacher) (int, error) {
+	cache = snapshotCache(cache)
+	var (
+		reader = NewFrameReader(r, DefaultMaxFrameSize)
+		now    = time.Now().UnixNano()
+		count  int
+	)
+	reader.maxSkip = math.MaxInt64 //snapshot is a local file, item of any size is skipped
+	for {
+		data, err := reader.ReadFrame()
+		if err == io.EOF {
+			return count, nil
+		}
+		if err == ErrFrameTooLarge {
+			continue
+		}
+		if err != nil {
+			return count, err
+		}
+		t := &ItemMessage{}
+		if err = proto.Unmarshal(data, t); err != nil {
+			return count, err
+		}
+		itm := &Item{Object: t.GetObject(), Expiration: t.GetExpiration()}
+		if itm.expired(now) {
+			continue
+		}
+		cache.SetOrUpdate(t.GetName(), itm.Object, remainingExpiration(itm))
+		count++
+	}
+}
+
+//SaveSnapshot write snapshot to temporary file and rename it to path, so previous snapshot is kept on failure
+func SaveSnapshot(path string, cache Cacher) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if err = WriteSnapshot(f, cache); err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+//LoadSnapshot restore items from snapshot file
+func LoadSnapshot(path string, cache Cacher) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return ReadSnapshot(f, cache)
+}
@@ -0,0 +1,115 @@
+package gcache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshot_RoundTrip(t *testing.T) {
+	as := assert.New(t)
+	c := NewRwCache(&ConfigMessage{})
+	c.SetOrUpdate("forever", []byte(`zaza`), NoExpiration)
+	c.SetOrUpdate("hour", []byte(`azaz`), time.Hour)
+
+	buf := &bytes.Buffer{}
+	as.NoError(WriteSnapshot(buf, c))
+	expired, _ := proto.Marshal(&ItemMessage{
+		Command:    ItemMessage_SET,
+		Name:       "expired",
+		Object:     []byte(`zaza`),
+		Expiration: time.Now().Add(-time.Second).UnixNano(),
+	})
+	WriteFrame(buf, expired)
+
+	restored := NewRwCache(&ConfigMessage{})
+	n, err := ReadSnapshot(buf, restored)
+	as.NoError(err)
+	as.Equal(2, n)
+	as.Equal([]byte(`zaza`), restored.Get("forever"))
+	as.Equal(int64(0), restored.GetItem("forever").Expiration)
+	as.InDelta(c.GetItem("hour").Expiration, restored.GetItem("hour").Expiration, float64(time.Second), "absolute expiration is kept")
+	as.Nil(restored.Get("expired"))
+
+	_, err = ReadSnapshot(bytes.NewReader([]byte{5, 1}), restored)
+	as.Error(err, "truncated snapshot")
+
+	c.Dead()        //Cleanup
+	restored.Dead() //Cleanup
+}
+
+//zeroReader is an endless stream of zero bytes
+type zeroReader struct{}
+
+var zeros [32 << 10]byte
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for n := 0; n < len(p); {
+		n += copy(p[n:], zeros[:])
+	}
+	return len(p), nil
+}
+
+func TestSnapshot_LargeItem(t *testing.T) {
+	as := assert.New(t)
+	c := NewRwCache(&ConfigMessage{})
+	c.SetOrUpdate("first", []byte(`zaza`), NoExpiration)
+	first := &bytes.Buffer{}
+	as.NoError(WriteSnapshot(first, c))
+	c.Purge()
+	c.SetOrUpdate("second", []byte(`azaz`), NoExpiration)
+	second := &bytes.Buffer{}
+	as.NoError(WriteSnapshot(second, c))
+
+	const (
+		size = DefaultMaxFrameSize + 100 //value of maximal size with protobuf overhead
+		huge = frameSkipFactor*DefaultMaxFrameSize + 1
+	)
+	snapshot := io.MultiReader(
+		first,
+		bytes.NewReader(binary.AppendUvarint(nil, size)),
+		io.LimitReader(zeroReader{}, size),
+		bytes.NewReader(binary.AppendUvarint(nil, huge)),
+		io.LimitReader(zeroReader{}, huge),
+		second,
+	)
+	restored := NewRwCache(&ConfigMessage{})
+	n, err := ReadSnapshot(snapshot, restored)
+	as.NoError(err, "large item is skipped")
+	as.Equal(2, n)
+	as.Equal([]byte(`zaza`), restored.Get("first"))
+	as.Equal([]byte(`azaz`), restored.Get("second"), "items after large one are restored")
+
+	c.Dead()        //Cleanup
+	restored.Dead() //Cleanup
+}
+
+func TestSnapshot_SaveLoad(t *testing.T) {
+	as := assert.New(t)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "snapshot")
+	c := NewRwCache(&ConfigMessage{})
+	c.SetOrUpdate("first", []byte(`zaza`), NoExpiration)
+	as.NoError(SaveSnapshot(path, c))
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	as.Len(files, 1, "temporary file is renamed")
+
+	restored := NewRwCache(&ConfigMessage{})
+	n, err := LoadSnapshot(path, restored)
+	as.NoError(err)
+	as.Equal(1, n)
+	as.Equal([]byte(`zaza`), restored.Get("first"))
+
+	_, err = LoadSnapshot(filepath.Join(dir, "missing"), restored)
+	as.True(os.IsNotExist(err))
+
+	c.Dead()        //Cleanup
+	restored.Dead() //Cleanup
+}
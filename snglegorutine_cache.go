@@ -18,10 +18,12 @@ type GorCache struct {
 	purgeChan chan bool
 	deadChan  chan bool
 	statsChan chan *statItem
+	opChan    chan func()
+	version   uint64
 }
 
 //GetterGorCache is a func for different get functionality depend on IsKeepUsefull option.
-type GetterGorCache func(*GorCache, string) []byte
+type GetterGorCache func(*GorCache, string) *Item
 
 type namedItem struct {
 	name string
@@ -30,7 +32,7 @@ type namedItem struct {
 
 type getterItem struct {
 	name     string
-	responce chan []byte
+	responce chan *Item
 }
 
 type statItem struct {
@@ -39,9 +41,17 @@ type statItem struct {
 
 //Get func is implementation of getting value of cache
 func (c *GorCache) Get(name string) []byte {
+	if item := c.GetItem(name); item != nil {
+		return item.Object
+	}
+	return nil
+}
+
+//GetItem return copy of item by name or nil
+func (c *GorCache) GetItem(name string) *Item {
 	getter := &getterItem{ //TODO make pool for this
 		name:     name,
-		responce: make(chan []byte, 1),
+		responce: make(chan *Item, 1),
 	}
 	c.getChan <- getter
 	return <-getter.responce
@@ -68,28 +78,161 @@ func (c *GorCache) Statistic() Stats {
 
 //SetOrUpdate set or update cache item
 func (c *GorCache) SetOrUpdate(name string, value []byte, expiration time.Duration) {
-	myExpiration := expiration
-	var expValue = int64(myExpiration)
-	if expiration == DefaultExpirationMarker {
-		expValue = time.Now().Add(myExpiration).UnixNano()
-	}
 	c.setChan <- namedItem{
 		name: name,
 		item: &Item{
 			Object:     value,
-			Expiration: expValue,
+			Expiration: expireAt(expiration, atomic.LoadInt64(&c.defaultExpiration)),
 		},
 	}
 }
 
+//Incr add delta to decimal item or create it
+func (c *GorCache) Incr(name string, delta int64, expiration time.Duration) (result int64, err error) {
+	exp := expireAt(expiration, atomic.LoadInt64(&c.defaultExpiration))
+	c.do(func() {
+		item := c.lookup(name)
+		if item == nil {
+			if c.full() {
+				err = ErrSizeLimit
+				return
+			}
+			item = &Item{Expiration: exp}
+		}
+		var value []byte
+		if value, result, err = incrValue(item.Object, delta); err == nil {
+			c.store(name, value, item.Expiration, item.Flags)
+		}
+	})
+	return result, err
+}
+
+//Decr subtract delta from decimal item or create it
+func (c *GorCache) Decr(name string, delta int64, expiration time.Duration) (int64, error) {
+	delta, err := negate(delta)
+	if err != nil {
+		return 0, err
+	}
+	return c.Incr(name, delta, expiration)
+}
+
+//Add set item only if it is absent
+func (c *GorCache) Add(name string, value []byte, expiration time.Duration) (uint64, error) {
+	return c.StoreFlags(ItemMessage_ADD, name, value, 0, 0, expiration)
+}
+
+//Replace set item only if it is present
+func (c *GorCache) Replace(name string, value []byte, expiration time.Duration) (uint64, error) {
+	return c.StoreFlags(ItemMessage_REPLACE, name, value, 0, 0, expiration)
+}
+
+//CAS set item only if its version is not changed
+func (c *GorCache) CAS(name string, value []byte, version uint64, expiration time.Duration) (uint64, error) {
+	return c.StoreFlags(ItemMessage_CAS, name, value, 0, version, expiration)
+}
+
+//StoreFlags is SetOrUpdate, Add, Replace or CAS by cmd which also store client flags of memcached protocol,
+// new item is ErrSizeLimit if cache is full
+func (c *GorCache) StoreFlags(cmd ItemMessage_Commands, name string, value []byte, flags uint32, version uint64, expiration time.Duration) (newVersion uint64, err error) {
+	exp := expireAt(expiration, atomic.LoadInt64(&c.defaultExpiration))
+	c.do(func() {
+		item := c.lookup(name)
+		if err = checkStore(cmd, item, version); err != nil {
+			return
+		}
+		if item == nil && c.full() {
+			err = ErrSizeLimit
+			return
+		}
+		newVersion = c.store(name, value, exp, flags)
+	})
+	return newVersion, err
+}
+
+//Delete item from the cache
+func (c *GorCache) Delete(name string) (deleted bool) {
+	c.do(func() {
+		deleted = c.lookup(name) != nil
+		delete(c.m, name)
+		if deleted {
+			atomic.AddInt64(&c.stats.DeleteCount, 1)
+		}
+		c.stats.ItemsCount = int64(len(c.m))
+	})
+	return deleted
+}
+
+//Keys return names of all live items
+func (c *GorCache) Keys() (keys []string) {
+	c.do(func() {
+		now := time.Now().UnixNano()
+		keys = make([]string, 0, len(c.m))
+		for k, itm := range c.m {
+			if !itm.expired(now) {
+				keys = append(keys, k)
+			}
+		}
+	})
+	return keys
+}
+
+//do run fn inside worker gorutine and wait for it
+func (c *GorCache) do(fn func()) {
+	done := make(chan bool)
+	c.opChan <- func() {
+		fn()
+		close(done)
+	}
+	<-done
+}
+
+//lookup return live item, is called only from worker gorutine
+func (c *GorCache) lookup(name string) *Item {
+	if item, ok := c.m[name]; ok && !item.expired(time.Now().UnixNano()) {
+		return item
+	}
+	return nil
+}
+
+//full report that new item could not be created, SizeLimit <= 0 is unlimited, is called only from worker gorutine
+func (c *GorCache) full() bool {
+	return c.stats.SizeLimit > 0 && c.stats.ItemsCount >= c.stats.SizeLimit
+}
+
+//store replace item, is called only from worker gorutine
+func (c *GorCache) store(name string, value []byte, expiration int64, flags uint32) uint64 {
+	c.version++
+	c.m[name] = &Item{
+		Object:     value,
+		Expiration: expiration,
+		Version:    c.version,
+		Flags:      flags,
+	}
+	atomic.AddInt64(&c.stats.SetOrReplaceCount, 1)
+	c.stats.ItemsCount = int64(len(c.m))
+	return c.version
+}
+
 func (c *GorCache) purge() {
 	for k := range c.m {
 		delete(c.m, k)
 	}
 }
 
+//Reconfigure apply DefaultExpiration and SizeLimit of config, existing items keep own expiration
+func (c *GorCache) Reconfigure(config ConfigCacheInterface) {
+	defaultExpiration := config.GetDefaultExpiration()
+	if defaultExpiration <= 0 {
+		defaultExpiration = int64(DefaultExpiration)
+	}
+	atomic.StoreInt64(&c.defaultExpiration, defaultExpiration)
+	c.do(func() {
+		c.stats.SizeLimit = config.GetSizeLimit()
+	})
+}
+
 //NewGorCache create new Gorutine cache (no lock but all in one line)
-// sizeLimit -- set maximum number of items inside cache
+// sizeLimit -- set maximum number of items inside cache, 0 is unlimited
 // defaultExpiration -- set expiration for item
 // isKeepUsefull -- reset expiration or not for item
 func NewGorCache(config ConfigCacheInterface) *GorCache {
@@ -108,21 +251,21 @@ func NewGorCache(config ConfigCacheInterface) *GorCache {
 		purgeChan: make(chan bool),
 		deadChan:  make(chan bool),
 		statsChan: make(chan *statItem),
+		opChan:    make(chan func(), 100),
 	}
 	if config.GetIsKeepUsefull() {
-		cache.geterFunc = func(c *GorCache, name string) []byte {
-			if item, ok := c.m[name]; ok {
-				item.Expiration = time.Now().Unix() //reset timer it looks usefull item
-				return item.Object
+		cache.geterFunc = func(c *GorCache, name string) *Item {
+			if item := c.lookup(name); item != nil {
+				if item.Expiration != 0 {
+					item.Expiration = time.Now().UnixNano() + atomic.LoadInt64(&c.defaultExpiration) //reset timer it looks usefull item
+				}
+				return item
 			}
 			return nil
 		}
 	} else {
-		cache.geterFunc = func(c *GorCache, name string) []byte {
-			if item, ok := c.m[name]; ok {
-				return item.Object
-			}
-			return nil
+		cache.geterFunc = func(c *GorCache, name string) *Item {
+			return c.lookup(name)
 		}
 	}
 
@@ -134,24 +277,26 @@ func NewGorCache(config ConfigCacheInterface) *GorCache {
 		for {
 			select {
 			case itm := <-cache.setChan:
-				if stats.ItemsCount < stats.SizeLimit {
-					cache.m[itm.name] = itm.item
-					atomic.AddInt64(&stats.SetOrReplaceCount, 1)
-					stats.ItemsCount = int64(len(cache.m))
+				if !cache.full() {
+					cache.store(itm.name, itm.item.Object, itm.item.Expiration, 0)
 				}
 			case get := <-cache.getChan:
-				result := cache.geterFunc(cache, get.name)
-				if len(result) != 0 {
+				var result *Item
+				if item := cache.geterFunc(cache, get.name); item != nil {
+					copied := *item
+					result = &copied
 					atomic.AddInt64(&stats.GetSuccessNumber, 1)
 				} else {
 					atomic.AddInt64(&stats.GetErrorNumber, 1)
 				}
 
 				get.responce <- result
+			case op := <-cache.opChan:
+				op()
 			case <-tiker.C:
-				now := time.Now().Unix()
+				now := time.Now().UnixNano()
 				for k, v := range cache.m {
-					if v.Expiration < now && v.Expiration > 0 {
+					if v.expired(now) {
 						delete(cache.m, k)
 						atomic.AddInt64(&stats.DeleteExpired, 1)
 						atomic.AddInt64(&stats.ItemsCount, -1)
@@ -1,7 +1,9 @@
 package gcache
 
 import (
+	"math"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -37,6 +39,22 @@ func TestGorCache_Get(t *testing.T) {
 	c.Dead() //Cleanup
 }
 
+func TestGorCache_GetExpired(t *testing.T) {
+	as := assert.New(t)
+	for _, keepUsefull := range []bool{true, false} {
+		c := NewGorCache(&ConfigMessage{DefaultExpiration: -1, IsKeepUsefull: keepUsefull})
+		_, err := c.Add("first", []byte(`zaza`), time.Millisecond)
+		as.NoError(err)
+		time.Sleep(5 * time.Millisecond)
+		as.Nil(c.Get("first"), "expired item is a miss like for Add")
+		as.Nil(c.GetItem("first"))
+		_, err = c.Add("first", []byte(`azaz`), NoExpiration)
+		as.NoError(err)
+		as.Equal([]byte(`azaz`), c.Get("first"))
+		c.Dead() //Cleanup
+	}
+}
+
 func TestGorCache_SetOrUpdate(t *testing.T) {
 	as := assert.New(t)
 	c := NewGorCache(defaultConfig())
@@ -62,3 +80,54 @@ func TestGorCache_SetOrUpdate(t *testing.T) {
 
 //TODO check expiration time
 //TODO check timeouts and isKeep...
+
+func TestGorCache_Incr(t *testing.T) {
+	as := assert.New(t)
+	c := NewGorCache(defaultConfig())
+	v, err := c.Incr("counter", 5, DefaultExpirationMarker)
+	as.NoError(err)
+	as.Equal(int64(5), v)
+	v, err = c.Decr("counter", 7, DefaultExpirationMarker)
+	as.NoError(err)
+	as.Equal(int64(-2), v)
+	as.Equal([]byte(`-2`), c.Get("counter"))
+	as.Equal(int64(1), c.Statistic().ItemsCount)
+
+	c.SetOrUpdate("text", []byte(`zaza`), DefaultExpirationMarker)
+	_, err = c.Incr("text", 1, DefaultExpirationMarker)
+	as.Equal(ErrNotInteger, err)
+
+	_, err = c.Incr("max", math.MaxInt64, DefaultExpirationMarker)
+	as.NoError(err)
+	_, err = c.Incr("max", 1, DefaultExpirationMarker)
+	as.Equal(ErrOverflow, err)
+	_, err = c.Decr("min", math.MinInt64, DefaultExpirationMarker)
+	as.Equal(ErrOverflow, err)
+
+	full := NewGorCache(&ConfigMessage{SizeLimit: 1})
+	_, err = full.Incr("counter", 1, DefaultExpirationMarker)
+	as.NoError(err)
+	_, err = full.Incr("other", 1, DefaultExpirationMarker)
+	as.Equal(ErrSizeLimit, err)
+	_, err = full.Add("other", []byte(`1`), DefaultExpirationMarker)
+	as.Equal(ErrSizeLimit, err)
+	_, err = full.Incr("counter", 1, DefaultExpirationMarker)
+	as.NoError(err, "existing item is updated")
+
+	unlimited := NewGorCache(&ConfigMessage{SizeLimit: 0})
+	_, err = unlimited.Incr("counter", 1, DefaultExpirationMarker)
+	as.NoError(err, "0 is unlimited")
+	unlimited.SetOrUpdate("zaza", []byte(`zaza`), DefaultExpirationMarker)
+	as.Equal([]byte(`zaza`), unlimited.Get("zaza"))
+
+	unlimited.Dead()
+
+	full.Dead()
+	c.Dead() //Cleanup
+}
+
+func TestGorCache_CAS(t *testing.T) {
+	c := NewGorCache(defaultConfig())
+	checkVersionedCacher(t, c)
+	c.Dead() //Cleanup
+}
@@ -0,0 +1,150 @@
+package gcache
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+)
+
+var (
+	//ErrUnknownIdentity is returned for client certificate which subject is not in identities
+	ErrUnknownIdentity = errors.New("Unknown client certificate subject")
+	errNoCertificate   = errors.New("Could not parse PEM certificates")
+)
+
+//TLSOptions is a tls settings of server
+//Without ClientCAFile any client can connect, with it client certificate is required and verified (mutual tls).
+//Identity of client is certificate CommonName or value from Identities by full subject
+type TLSOptions struct {
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string
+	Identities   map[string]string //subject like "CN=app,O=org" to identity, other subjects are rejected if not empty
+}
+
+//Enabled return true if server certificate is set
+func (o *TLSOptions) Enabled() bool {
+	return o != nil && o.CertFile != ""
+}
+
+//Config load certificates and return server tls config
+func (o *TLSOptions) Config() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if o.ClientCAFile == "" {
+		return cfg, nil
+	}
+	if cfg.ClientCAs, err = loadCertPool(o.ClientCAFile); err != nil {
+		return nil, err
+	}
+	cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	cfg.VerifyPeerCertificate = func(_ [][]byte, chains [][]*x509.Certificate) error {
+		if _, ok := o.identity(chains[0][0]); !ok {
+			return ErrUnknownIdentity
+		}
+		return nil
+	}
+	return cfg, nil
+}
+
+//Listener wrap listener with tls, handshake is made on first read or write
+func (o *TLSOptions) Listener(ln net.Listener) (net.Listener, error) {
+	cfg, err := o.Config()
+	if err != nil {
+		return nil, err
+	}
+	return tls.NewListener(ln, cfg), nil
+}
+
+func (o *TLSOptions) identity(cert *x509.Certificate) (string, bool) {
+	if len(o.Identities) == 0 {
+		return cert.Subject.CommonName, true
+	}
+	id, ok := o.Identities[cert.Subject.String()]
+	return id, ok
+}
+
+//Identity return identity of verified client certificate, empty if client has no certificate
+func (o *TLSOptions) Identity(state *tls.ConnectionState) string {
+	if state == nil || len(state.VerifiedChains) == 0 {
+		return ""
+	}
+	id, _ := o.identity(state.VerifiedChains[0][0])
+	return id
+}
+
+//ConnIdentity return identity of client of tls connection, empty for plain connection or client without certificate
+func (o *TLSOptions) ConnIdentity(conn net.Conn) string {
+	tc, ok := conn.(*tls.Conn)
+	if !ok || tc.Handshake() != nil {
+		return ""
+	}
+	state := tc.ConnectionState()
+	return o.Identity(&state)
+}
+
+//LoadIdentities read file with lines "<identity> <subject>", empty lines and lines started with # are skipped
+func LoadIdentities(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	identities := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		fields := strings.SplitN(line, " ", 2)
+		if len(fields) != 2 {
+			return nil, errors.New("Wrong identity line: " + line)
+		}
+		identities[strings.TrimSpace(fields[1])] = fields[0]
+	}
+	return identities, scanner.Err()
+}
+
+//ClientTLSConfig return config for RemoteCache, caFile verify server instead of system roots if set,
+// certFile and keyFile are client certificate for mutual tls if set
+func ClientTLSConfig(caFile, certFile, keyFile string) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	var err error
+	if caFile != "" {
+		if cfg.RootCAs, err = loadCertPool(caFile); err != nil {
+			return nil, err
+		}
+	}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+func loadCertPool(path string) (*x509.CertPool, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, errNoCertificate
+	}
+	return pool, nil
+}
@@ -0,0 +1,276 @@
+package gcache
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//testCA issue certificates for tests
+type testCA struct {
+	dir    string
+	name   string
+	cert   *x509.Certificate
+	key    *ecdsa.PrivateKey
+	serial int64
+}
+
+func newTestCA(t *testing.T, dir, name string) *testCA {
+	ca := &testCA{dir: dir, name: name}
+	ca.cert, ca.key = ca.create(t, name, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: name},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	})
+	return ca
+}
+
+//create sign template by ca, self signed if ca has no certificate yet, and write name.pem and name.key
+func (ca *testCA) create(t *testing.T, name string, tmpl *x509.Certificate) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca.serial++
+	tmpl.SerialNumber = big.NewInt(ca.serial)
+	tmpl.NotBefore = time.Now().Add(-time.Hour)
+	tmpl.NotAfter = time.Now().Add(time.Hour)
+	parent, signer := tmpl, key
+	if ca.cert != nil {
+		parent, signer = ca.cert, ca.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, filepath.Join(ca.dir, name+".pem"), "CERTIFICATE", der)
+	writePEM(t, filepath.Join(ca.dir, name+".key"), "EC PRIVATE KEY", keyDer)
+	return cert, key
+}
+
+//issue return certificate and key files
+func (ca *testCA) issue(t *testing.T, name string, subject pkix.Name, server bool) (string, string) {
+	tmpl := &x509.Certificate{
+		Subject:     subject,
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if server {
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+		tmpl.IPAddresses = []net.IP{net.IPv4(127, 0, 0, 1)}
+	}
+	ca.create(t, name, tmpl)
+	return filepath.Join(ca.dir, name+".pem"), filepath.Join(ca.dir, name+".key")
+}
+
+func (ca *testCA) file() string {
+	return filepath.Join(ca.dir, ca.name+".pem")
+}
+
+func writePEM(t *testing.T, path, kind string, der []byte) {
+	if err := ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: kind, Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTLS_RemoteCache(t *testing.T) {
+	as := assert.New(t)
+	ca := newTestCA(t, t.TempDir(), "ca")
+	certFile, keyFile := ca.issue(t, "server", pkix.Name{CommonName: "gcache"}, true)
+	opts := &TLSOptions{CertFile: certFile, KeyFile: keyFile}
+
+	inner := NewRwCache(defaultConfig())
+	plain, err := net.Listen("tcp", "127.0.0.1:0")
+	if !as.NoError(err) {
+		return
+	}
+	ln, err := opts.Listener(plain)
+	if !as.NoError(err) {
+		return
+	}
+	defer ln.Close()
+	go handleLongTCP(ln, inner, nil)
+
+	cfg, err := ClientTLSConfig(ca.file(), "", "")
+	if !as.NoError(err) {
+		return
+	}
+	c, err := NewRemoteCache(ln.Addr().String(), RemoteOptions{TLS: cfg})
+	if as.NoError(err) {
+		c.SetOrUpdate("first", []byte(`zaza`), DefaultExpirationMarker)
+		as.Equal([]byte(`zaza`), c.Get("first"))
+		as.Equal([]byte(`zaza`), inner.Get("first"))
+		c.Dead()
+	}
+
+	_, err = NewRemoteCache(ln.Addr().String(), RemoteOptions{TLS: &tls.Config{}})
+	as.Error(err, "server certificate is not trusted")
+
+	inner.Dead() //Cleanup
+}
+
+func TestTLS_ClientCertificate(t *testing.T) {
+	as := assert.New(t)
+	dir := t.TempDir()
+	ca := newTestCA(t, dir, "ca")
+	otherCA := newTestCA(t, dir, "other-ca")
+	certFile, keyFile := ca.issue(t, "server", pkix.Name{CommonName: "gcache"}, true)
+	appCert, appKey := ca.issue(t, "app", pkix.Name{CommonName: "app", Organization: []string{"gcache"}}, false)
+	unknownCert, unknownKey := ca.issue(t, "unknown", pkix.Name{CommonName: "unknown"}, false)
+	strangerCert, strangerKey := otherCA.issue(t, "stranger", pkix.Name{CommonName: "app", Organization: []string{"gcache"}}, false)
+
+	identitiesFile := filepath.Join(dir, "identities")
+	ioutil.WriteFile(identitiesFile, []byte("# identity subject\n\napplication CN=app,O=gcache\n"), 0600)
+	identities, err := LoadIdentities(identitiesFile)
+	if !as.NoError(err) {
+		return
+	}
+	as.Equal(map[string]string{"CN=app,O=gcache": "application"}, identities)
+
+	opts := &TLSOptions{CertFile: certFile, KeyFile: keyFile, ClientCAFile: ca.file(), Identities: identities}
+	plain, err := net.Listen("tcp", "127.0.0.1:0")
+	if !as.NoError(err) {
+		return
+	}
+	ln, err := opts.Listener(plain)
+	if !as.NoError(err) {
+		return
+	}
+	defer ln.Close()
+	ids := make(chan string)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			id := opts.ConnIdentity(conn)
+			if id != "" {
+				conn.Write([]byte(id))
+			}
+			conn.Close()
+			ids <- id
+		}
+	}()
+
+	for _, test := range []struct {
+		cert, key string
+		identity  string
+	}{
+		{appCert, appKey, "application"},
+		{"", "", ""},
+		{unknownCert, unknownKey, ""},
+		{strangerCert, strangerKey, ""},
+	} {
+		cfg, err := ClientTLSConfig(ca.file(), test.cert, test.key)
+		if !as.NoError(err) {
+			return
+		}
+		conn, err := tls.Dial("tcp", ln.Addr().String(), cfg)
+		if err == nil {
+			data, _ := ioutil.ReadAll(conn)
+			as.Equal(test.identity, string(data), test.cert)
+			conn.Close()
+		}
+		as.Equal(test.identity, <-ids, test.cert)
+	}
+
+	opts.Identities = nil
+	conn, err := tls.Dial("tcp", ln.Addr().String(), mustClientTLS(t, ca.file(), unknownCert, unknownKey))
+	if as.NoError(err) {
+		data, _ := ioutil.ReadAll(conn)
+		as.Equal("unknown", string(data), "CommonName without identities")
+		conn.Close()
+	}
+	<-ids
+}
+
+func mustClientTLS(t *testing.T, caFile, certFile, keyFile string) *tls.Config {
+	cfg, err := ClientTLSConfig(caFile, certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func TestTLS_HTTPS(t *testing.T) {
+	as := assert.New(t)
+	ca := newTestCA(t, t.TempDir(), "ca")
+	certFile, keyFile := ca.issue(t, "server", pkix.Name{CommonName: "gcache"}, true)
+	appCert, appKey := ca.issue(t, "app", pkix.Name{CommonName: "app"}, false)
+	opts := &TLSOptions{CertFile: certFile, KeyFile: keyFile, ClientCAFile: ca.file()}
+
+	cache := NewRwCache(&ConfigMessage{})
+	plain, err := net.Listen("tcp", "127.0.0.1:0")
+	if !as.NoError(err) {
+		return
+	}
+	ln, err := opts.Listener(plain)
+	if !as.NoError(err) {
+		return
+	}
+	defer ln.Close()
+	go http.Serve(ln, NewHTTPHandler(cache))
+	url := "https://" + ln.Addr().String() + keysPath + "first"
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: mustClientTLS(t, ca.file(), appCert, appKey)}}
+	req, _ := http.NewRequest(http.MethodPut, url, bytes.NewReader([]byte(`zaza`)))
+	resp, err := client.Do(req)
+	if as.NoError(err) {
+		resp.Body.Close()
+		as.Equal([]byte(`zaza`), cache.Get("first"))
+	}
+
+	anonymous := &http.Client{Transport: &http.Transport{TLSClientConfig: mustClientTLS(t, ca.file(), "", "")}}
+	_, err = anonymous.Get(url)
+	as.Error(err, "client certificate is required")
+
+	cache.Dead() //Cleanup
+}
+
+func TestTLS_Errors(t *testing.T) {
+	as := assert.New(t)
+	dir := t.TempDir()
+	_, err := (&TLSOptions{CertFile: filepath.Join(dir, "missing.pem")}).Config()
+	as.True(os.IsNotExist(err))
+
+	ca := newTestCA(t, dir, "ca")
+	certFile, keyFile := ca.issue(t, "server", pkix.Name{CommonName: "gcache"}, true)
+	_, err = (&TLSOptions{CertFile: certFile, KeyFile: keyFile, ClientCAFile: keyFile}).Config()
+	as.Equal(errNoCertificate, err)
+
+	ioutil.WriteFile(filepath.Join(dir, "identities"), []byte("application\n"), 0600)
+	_, err = LoadIdentities(filepath.Join(dir, "identities"))
+	as.Error(err)
+
+	c := ServerOptions{Endpoints: []Endpoint{{Mode: modeUDP}}, TLS: TLSOptions{CertFile: certFile, KeyFile: keyFile}}
+	as.Error(c.Validate())
+	c.Endpoints[0].Mode = modeTCPLong
+	as.NoError(c.Validate())
+	c.TLS.KeyFile = ""
+	as.Error(c.Validate())
+}
@@ -0,0 +1,152 @@
+package gcache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+)
+
+//ErrCodecType is returned when codec can not handle type of value
+var ErrCodecType = errors.New("Unsupported type for codec")
+
+//Codec convert values to bytes and back for storing inside Cacher
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	//Unmarshal expect pointer to value
+	Unmarshal(data []byte, v interface{}) error
+}
+
+//JSONCodec encode values with encoding/json
+type JSONCodec struct{}
+
+//Marshal value to json
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+//Unmarshal json to value
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+//GobCodec encode values with encoding/gob
+type GobCodec struct{}
+
+//Marshal value to gob
+func (GobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+//Unmarshal gob to value
+func (GobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+//ProtoCodec encode protobuf messages, value should be a pointer to message
+type ProtoCodec struct{}
+
+//Marshal protobuf message
+func (ProtoCodec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, ErrCodecType
+	}
+	return proto.Marshal(m)
+}
+
+//Unmarshal protobuf message, pointer to nil message pointer is allocated
+func (ProtoCodec) Unmarshal(data []byte, v interface{}) error {
+	if m, ok := v.(proto.Message); ok {
+		return proto.Unmarshal(data, m)
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Ptr {
+		return ErrCodecType
+	}
+	if rv.Elem().IsNil() {
+		rv.Elem().Set(reflect.New(rv.Elem().Type().Elem()))
+	}
+	m, ok := rv.Elem().Interface().(proto.Message)
+	if !ok {
+		return ErrCodecType
+	}
+	return proto.Unmarshal(data, m)
+}
+
+//RawCodec store []byte and string values as is
+type RawCodec struct{}
+
+//Marshal []byte or string
+func (RawCodec) Marshal(v interface{}) ([]byte, error) {
+	switch t := v.(type) {
+	case []byte:
+		return t, nil
+	case string:
+		return []byte(t), nil
+	}
+	return nil, ErrCodecType
+}
+
+//Unmarshal to *[]byte or *string
+func (RawCodec) Unmarshal(data []byte, v interface{}) error {
+	switch t := v.(type) {
+	case *[]byte:
+		*t = data
+	case *string:
+		*t = string(data)
+	default:
+		return ErrCodecType
+	}
+	return nil
+}
+
+//Typed is a wrapper over any Cacher which stores values of type V with Codec
+type Typed[V any] struct {
+	cache Cacher
+	codec Codec
+}
+
+//NewTyped create typed wrapper over cache
+func NewTyped[V any](cache Cacher, codec Codec) *Typed[V] {
+	return &Typed[V]{
+		cache: cache,
+		codec: codec,
+	}
+}
+
+//Get return decoded value by name, false if item not found
+func (t *Typed[V]) Get(name string) (V, bool, error) {
+	var v V
+	data := t.cache.Get(name)
+	if data == nil {
+		return v, false, nil
+	}
+	if err := t.codec.Unmarshal(data, &v); err != nil {
+		return v, false, err
+	}
+	return v, true, nil
+}
+
+//Set encode value and set or update it in cache
+func (t *Typed[V]) Set(name string, value V, exp time.Duration) error {
+	data, err := t.codec.Marshal(value)
+	if err != nil {
+		return err
+	}
+	t.cache.SetOrUpdate(name, data, exp)
+	return nil
+}
+
+//Cache return underlying cache
+func (t *Typed[V]) Cache() Cacher {
+	return t.cache
+}
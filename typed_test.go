@@ -0,0 +1,91 @@
+package gcache
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type typedValue struct {
+	Name  string
+	Count int
+}
+
+func TestTyped_JSON(t *testing.T) {
+	as := assert.New(t)
+	c := NewRwCache(defaultConfig())
+	typed := NewTyped[typedValue](c, JSONCodec{})
+
+	v, ok, err := typed.Get("first")
+	as.NoError(err)
+	as.False(ok)
+	as.Equal(typedValue{}, v)
+
+	as.NoError(typed.Set("first", typedValue{"zaza", 2}, DefaultExpirationMarker))
+	v, ok, err = typed.Get("first")
+	as.NoError(err)
+	as.True(ok)
+	as.Equal(typedValue{"zaza", 2}, v)
+	as.Equal([]byte(`{"Name":"zaza","Count":2}`), c.Get("first"))
+
+	c.SetOrUpdate("broken", []byte(`zaza`), DefaultExpirationMarker)
+	_, ok, err = typed.Get("broken")
+	as.Error(err)
+	as.False(ok)
+
+	c.Dead() //Cleanup
+}
+
+func TestTyped_Gob(t *testing.T) {
+	as := assert.New(t)
+	c := NewRwCache(defaultConfig())
+	typed := NewTyped[map[string]int](c, GobCodec{})
+
+	as.NoError(typed.Set("first", map[string]int{"zaza": 1}, DefaultExpirationMarker))
+	v, ok, err := typed.Get("first")
+	as.NoError(err)
+	as.True(ok)
+	as.Equal(map[string]int{"zaza": 1}, v)
+
+	c.Dead() //Cleanup
+}
+
+func TestTyped_Proto(t *testing.T) {
+	as := assert.New(t)
+	c := NewRwCache(defaultConfig())
+	typed := NewTyped[*ItemMessage](c, ProtoCodec{})
+
+	as.NoError(typed.Set("first", &ItemMessage{Name: "zaza", Object: []byte(`azaz`)}, DefaultExpirationMarker))
+	v, ok, err := typed.Get("first")
+	as.NoError(err)
+	as.True(ok)
+	as.Equal("zaza", v.GetName())
+	as.Equal([]byte(`azaz`), v.GetObject())
+
+	as.Equal(ErrCodecType, NewTyped[string](c, ProtoCodec{}).Set("second", "zaza", DefaultExpirationMarker))
+
+	c.Dead() //Cleanup
+}
+
+func TestTyped_Raw(t *testing.T) {
+	as := assert.New(t)
+	c := NewRwCache(defaultConfig())
+	str := NewTyped[string](c, RawCodec{})
+	raw := NewTyped[[]byte](c, RawCodec{})
+
+	as.NoError(str.Set("first", "zaza", DefaultExpirationMarker))
+	v, ok, err := raw.Get("first")
+	as.NoError(err)
+	as.True(ok)
+	as.Equal([]byte(`zaza`), v)
+
+	as.NoError(raw.Set("second", []byte(`azaz`), DefaultExpirationMarker))
+	s, ok, err := str.Get("second")
+	as.NoError(err)
+	as.True(ok)
+	as.Equal("azaz", s)
+
+	as.Equal(ErrCodecType, NewTyped[int](c, RawCodec{}).Set("third", 1, DefaultExpirationMarker))
+
+	c.Dead() //Cleanup
+}
@@ -1,6 +1,9 @@
 package gcache
 
-import "time"
+import (
+	"errors"
+	"time"
+)
 
 const (
 	//NoExpiration mean It willl not be deleted by timeout
@@ -11,6 +14,23 @@ const (
 	DefaultExpiration time.Duration = time.Duration(5 * time.Minute)
 )
 
+var (
+	//ErrNotInteger is returned by Incr and Decr for item which is not a decimal integer
+	ErrNotInteger = errors.New("Value is not an integer")
+	//ErrOverflow is returned by Incr and Decr when result is out of int64 range, item is not changed
+	ErrOverflow = errors.New("Increment or decrement would overflow")
+	//ErrNotSupported is returned when cache could not do operation
+	ErrNotSupported = errors.New("Operation is not supported")
+	//ErrSizeLimit is returned when new item could not be created because of SizeLimit
+	ErrSizeLimit = errors.New("Size limit is reached")
+	//ErrExists is returned by Add when item is already in cache
+	ErrExists = errors.New("Item exists")
+	//ErrNotFound is returned by Replace and CAS when item is not in cache
+	ErrNotFound = errors.New("Item not found")
+	//ErrVersionMismatch is returned by CAS when item was changed
+	ErrVersionMismatch = errors.New("Version mismatch")
+)
+
 //Stats is a statistic holder for cache
 type Stats struct {
 	ItemsCount,
@@ -19,7 +39,9 @@ type Stats struct {
 	SetOrReplaceCount,
 	DeleteCount,
 	DeleteExpired,
-	SizeLimit int64
+	SizeLimit,
+	RawBytes,
+	CompressedBytes int64
 }
 
 //Cacher interface for a storage
@@ -28,6 +50,22 @@ type Cacher interface {
 	Get(name string) []byte
 	//Set or update item
 	SetOrUpdate(name string, value []byte, exp time.Duration)
+	//Incr atomically add delta to decimal item, missing item is created with exp
+	Incr(name string, delta int64, exp time.Duration) (int64, error)
+	//Decr atomically subtract delta from decimal item, missing item is created with exp
+	Decr(name string, delta int64, exp time.Duration) (int64, error)
+	//GetItem return copy of item with expiration and version or nil
+	GetItem(name string) *Item
+	//Add set item only if it is absent, return new version
+	Add(name string, value []byte, exp time.Duration) (uint64, error)
+	//Replace set item only if it is present, return new version
+	Replace(name string, value []byte, exp time.Duration) (uint64, error)
+	//CAS set item only if its version is not changed, return new version
+	CAS(name string, value []byte, version uint64, exp time.Duration) (uint64, error)
+	//Delete item, return false if item was not found
+	Delete(name string) bool
+	//Keys return names of all live items in any order
+	Keys() []string
 	//Purge cache cleanup but it still alive
 	Purge()
 	//Dead should stop cashing and clean
@@ -39,5 +77,14 @@ type Cacher interface {
 //Item is a wrapper for storage
 type Item struct {
 	Object     []byte
-	Expiration int64
+	Expiration int64  //UnixNano, 0 mean no expiration
+	Version    uint64 //changed on every write of item
+	Flags      uint32 //client flags of memcached protocol, see FlagsCacher
+}
+
+//FlagsCacher is a cache which keep client flags of memcached protocol with items.
+//Writes of Cacher reset flags to zero, Incr and Decr keep them
+type FlagsCacher interface {
+	//StoreFlags is SetOrUpdate, Add, Replace or CAS by cmd which also store flags, return new version
+	StoreFlags(cmd ItemMessage_Commands, name string, value []byte, flags uint32, version uint64, exp time.Duration) (uint64, error)
 }
@@ -0,0 +1,85 @@
+package gcache
+
+import (
+	"net/netip"
+	"sync"
+	"time"
+)
+
+const (
+	udpRepliesSize = 1 << 14          //responces in one generation of udpReplies
+	udpRepliesTTL  = 10 * time.Second //generation is replaced after this time even if it is not full
+)
+
+//udpRequest identify request by client address and RequestID
+type udpRequest struct {
+	addr netip.AddrPort
+	id   uint64
+}
+
+//udpReplies remember responces of non-idempotent udp requests, so request retried after lost responce
+// is not applied twice. Responces are kept in two generations, so memory is limited and every responce
+// lives at least udpRepliesTTL unless there are more than udpRepliesSize such requests meanwhile
+type udpReplies struct {
+	l        sync.Mutex
+	current  map[udpRequest][]byte //nil responce mean request is in progress
+	previous map[udpRequest][]byte
+	rotated  time.Time
+}
+
+func newUDPReplies() *udpReplies {
+	return &udpReplies{
+		current:  make(map[udpRequest][]byte),
+		previous: make(map[udpRequest][]byte),
+		rotated:  time.Now(),
+	}
+}
+
+//remembered return true for requests which could not be applied twice, client should set RequestID for them
+func remembered(key udpRequest, cmd ItemMessage_Commands) bool {
+	if key.id == 0 {
+		return false
+	}
+	switch cmd {
+	case ItemMessage_INCR, ItemMessage_DECR, ItemMessage_ADD, ItemMessage_REPLACE, ItemMessage_CAS, ItemMessage_DELETE:
+		return true
+	}
+	return false
+}
+
+//begin register request and return responce of previous request with the same key,
+// responce is nil if the previous request is still in progress
+func (r *udpReplies) begin(key udpRequest, cmd ItemMessage_Commands) ([]byte, bool) {
+	if !remembered(key, cmd) {
+		return nil, false
+	}
+	r.l.Lock()
+	defer r.l.Unlock()
+	if responce, ok := r.current[key]; ok {
+		return responce, true
+	}
+	if responce, ok := r.previous[key]; ok {
+		return responce, true
+	}
+	if now := time.Now(); len(r.current) >= udpRepliesSize || now.Sub(r.rotated) > udpRepliesTTL {
+		r.previous, r.current = r.current, make(map[udpRequest][]byte)
+		r.rotated = now
+	}
+	r.current[key] = nil
+	return nil, false
+}
+
+//finish remember copy of responce of request registered by begin
+func (r *udpReplies) finish(key udpRequest, cmd ItemMessage_Commands, responce []byte) {
+	if !remembered(key, cmd) {
+		return
+	}
+	r.l.Lock()
+	defer r.l.Unlock()
+	responce = append([]byte(nil), responce...)
+	if _, ok := r.previous[key]; ok {
+		r.previous[key] = responce
+		return
+	}
+	r.current[key] = responce
+}
@@ -0,0 +1,125 @@
+package gcache
+
+import (
+	"bytes"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+//udpExchange send request via conn and return responce
+func udpExchange(t *testing.T, conn net.Conn, req *ItemMessage) *ItemMessage {
+	data, _ := proto.Marshal(req)
+	if _, err := conn.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, maxPacketSize)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := &ItemMessage{}
+	if err = proto.Unmarshal(buf[:n], resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestServer_UDPTruncated(t *testing.T) {
+	as := assert.New(t)
+	s, cancel, served := startServer(t, NewRwCache(&ConfigMessage{}), ServerOptions{}, modeUDP)
+	defer func() {
+		cancel()
+		<-served
+	}()
+	big := bytes.Repeat([]byte(`z`), 2*maxDatagramSize)
+	s.Cache().SetOrUpdate("big", big, NoExpiration)
+	s.Cache().SetOrUpdate("small", []byte(`zaza`), NoExpiration)
+
+	conn, err := net.Dial("udp", s.Addr(modeUDP).String())
+	if !as.NoError(err) {
+		return
+	}
+	defer conn.Close()
+	resp := udpExchange(t, conn, &ItemMessage{Command: ItemMessage_GET, Name: "big", RequestID: 5})
+	as.Equal(ItemMessage_TRUNCATED, resp.GetStatus())
+	as.Equal(ErrTruncated.Error(), resp.GetError())
+	as.Equal(uint64(5), resp.GetRequestID())
+	as.Nil(resp.GetObject())
+
+	resp = udpExchange(t, conn, &ItemMessage{Command: ItemMessage_GET, Name: "small", RequestID: 6})
+	as.Equal(ItemMessage_OK, resp.GetStatus())
+	as.Equal([]byte(`zaza`), resp.GetObject())
+}
+
+func TestServer_UDPDuplicates(t *testing.T) {
+	as := assert.New(t)
+	s, cancel, served := startServer(t, NewRwCache(&ConfigMessage{}), ServerOptions{}, modeUDP)
+	defer func() {
+		cancel()
+		<-served
+	}()
+	conn, err := net.Dial("udp", s.Addr(modeUDP).String())
+	if !as.NoError(err) {
+		return
+	}
+	defer conn.Close()
+	incr := &ItemMessage{Command: ItemMessage_INCR, Name: "counter", Delta: 1, RequestID: 7}
+	as.Equal([]byte(`1`), udpExchange(t, conn, incr).GetObject())
+	as.Equal([]byte(`1`), udpExchange(t, conn, incr).GetObject(), "retry is not applied again")
+	incr.RequestID = 8
+	as.Equal([]byte(`2`), udpExchange(t, conn, incr).GetObject())
+	incr.RequestID = 0
+	as.Equal([]byte(`3`), udpExchange(t, conn, incr).GetObject(), "request without id is applied")
+	as.Equal([]byte(`4`), udpExchange(t, conn, incr).GetObject())
+
+	other, err := net.Dial("udp", s.Addr(modeUDP).String())
+	if !as.NoError(err) {
+		return
+	}
+	defer other.Close()
+	incr.RequestID = 7
+	as.Equal([]byte(`5`), udpExchange(t, other, incr).GetObject(), "id of other client")
+
+	del := &ItemMessage{Command: ItemMessage_DELETE, Name: "counter", RequestID: 9}
+	as.Equal(ItemMessage_OK, udpExchange(t, conn, del).GetStatus())
+	as.Equal(ItemMessage_OK, udpExchange(t, conn, del).GetStatus(), "retry of delete is not reported as missing item")
+}
+
+func TestUDPReplies(t *testing.T) {
+	as := assert.New(t)
+	r := newUDPReplies()
+	addr := netip.MustParseAddrPort("127.0.0.1:7000")
+	first := udpRequest{addr: addr, id: 1}
+
+	_, duplicate := r.begin(first, ItemMessage_INCR)
+	as.False(duplicate)
+	responce, duplicate := r.begin(first, ItemMessage_INCR)
+	as.True(duplicate)
+	as.Nil(responce, "first request is in progress")
+	r.finish(first, ItemMessage_INCR, []byte(`1`))
+	responce, duplicate = r.begin(first, ItemMessage_INCR)
+	as.True(duplicate)
+	as.Equal([]byte(`1`), responce)
+
+	_, duplicate = r.begin(first, ItemMessage_GET)
+	as.False(duplicate, "idempotent request is not remembered")
+	_, duplicate = r.begin(udpRequest{addr: addr}, ItemMessage_INCR)
+	as.False(duplicate, "request without id is not remembered")
+	_, duplicate = r.begin(udpRequest{addr: addr}, ItemMessage_INCR)
+	as.False(duplicate)
+
+	r.rotated = time.Now().Add(-2 * udpRepliesTTL)
+	r.begin(udpRequest{addr: addr, id: 2}, ItemMessage_INCR)
+	_, duplicate = r.begin(first, ItemMessage_INCR)
+	as.True(duplicate, "previous generation is kept")
+	r.rotated = time.Now().Add(-2 * udpRepliesTTL)
+	r.begin(udpRequest{addr: addr, id: 3}, ItemMessage_INCR)
+	_, duplicate = r.begin(first, ItemMessage_INCR)
+	as.False(duplicate, "old generation is dropped")
+}
@@ -0,0 +1,65 @@
+package gcache
+
+import (
+	"errors"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//unixScheme is a prefix of unix socket address of RemoteCache
+const unixScheme = "unix://"
+
+//ParseSocketPerm parse octal permissions of unix socket like "0660"
+func ParseSocketPerm(value string) (os.FileMode, error) {
+	perm, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || perm > 0777 {
+		return 0, errors.New("Wrong socket permissions: " + value)
+	}
+	return os.FileMode(perm), nil
+}
+
+//listenUnix listen unix socket, address started with @ is a name in abstract namespace of linux without file.
+//Stale socket file is removed before listening, perm is applied to socket file if it is not zero
+func listenUnix(address string, perm os.FileMode) (net.Listener, error) {
+	abstract := strings.HasPrefix(address, "@")
+	if !abstract {
+		if err := removeStaleSocket(address); err != nil {
+			return nil, err
+		}
+	}
+	ln, err := net.Listen("unix", address)
+	if err != nil {
+		return nil, err
+	}
+	if perm != 0 && !abstract {
+		if err = os.Chmod(address, perm); err != nil {
+			ln.Close()
+			return nil, err
+		}
+	}
+	return ln, nil
+}
+
+//removeStaleSocket remove socket file left by killed server, socket which accept connections is kept
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if err != nil || info.Mode()&os.ModeSocket == 0 {
+		return nil //listen report error for other files
+	}
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		conn.Close()
+		return errors.New("Socket is in use: " + path)
+	}
+	return os.Remove(path)
+}
+
+//splitNetwork return network and address of RemoteCache address, unix:///path or unix://@name is unix socket
+func splitNetwork(address string) (string, string) {
+	if strings.HasPrefix(address, unixScheme) {
+		return "unix", strings.TrimPrefix(address, unixScheme)
+	}
+	return "tcp", address
+}
@@ -0,0 +1,83 @@
+package gcache
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_Unix(t *testing.T) {
+	as := assert.New(t)
+	path := filepath.Join(t.TempDir(), "gcache.sock")
+	stale, err := net.Listen("unix", path)
+	if !as.NoError(err) {
+		return
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close() //socket file of killed server
+
+	endpoints := []Endpoint{{Mode: modeUnix, Address: path}}
+	if runtime.GOOS == "linux" {
+		endpoints = append(endpoints, Endpoint{Mode: modeUnix, Address: "@gcache-test-" + strconv.Itoa(os.Getpid())})
+	}
+	s, err := NewServer(NewRwCache(&ConfigMessage{}), ServerOptions{Endpoints: endpoints, SocketPerm: 0600})
+	if !as.NoError(err) {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(ctx) }()
+	for listening := 0; listening < len(endpoints); time.Sleep(time.Millisecond) {
+		s.l.Lock()
+		listening = len(s.endpoints)
+		s.l.Unlock()
+	}
+
+	info, err := os.Stat(path)
+	if as.NoError(err) {
+		as.Equal(os.ModeSocket|0600, info.Mode())
+	}
+	for _, e := range endpoints {
+		remote, err := NewRemoteCache(unixScheme+e.Address, RemoteOptions{})
+		if !as.NoError(err, e.Address) {
+			continue
+		}
+		_, err = remote.Add(e.Address, []byte(`zaza`), NoExpiration)
+		as.NoError(err)
+		as.Equal([]byte(`zaza`), remote.Get(e.Address))
+		remote.Dead()
+	}
+
+	_, err = listenUnix(path, 0)
+	as.Error(err, "socket of running server is kept")
+	cancel()
+	as.Equal(ErrServerClosed, <-served)
+	_, err = os.Stat(path)
+	as.True(os.IsNotExist(err), "socket file is removed by shutdown")
+
+	as.Error((&ServerOptions{Endpoints: []Endpoint{{Mode: modeUnix}}}).Validate())
+}
+
+func TestParseSocketPerm(t *testing.T) {
+	as := assert.New(t)
+	perm, err := ParseSocketPerm("0660")
+	as.NoError(err)
+	as.Equal(os.FileMode(0660), perm)
+	for _, wrong := range []string{"", "rw", "0999", "1777"} {
+		_, err = ParseSocketPerm(wrong)
+		as.Error(err, wrong)
+	}
+	network, address := splitNetwork("unix:///run/gcache.sock")
+	as.Equal("unix", network)
+	as.Equal("/run/gcache.sock", address)
+	network, address = splitNetwork("localhost:7000")
+	as.Equal("tcp", network)
+	as.Equal("localhost:7000", address)
+}
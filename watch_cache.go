@@ -0,0 +1,178 @@
+package gcache
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//DefaultWatchBuffer is a number of events which watcher can keep before it is dropped
+const DefaultWatchBuffer = 128
+
+//WatchCache is a decorator which publish changes of items to watchers
+//Event is ItemMessage with Command SET, DELETE or PURGE, Version is 0 when it is unknown (SetOrUpdate, Incr).
+//Only changes made through WatchCache are published, expiration of items is not an event
+type WatchCache struct {
+	Cacher
+	l        sync.RWMutex
+	watchers map[*watcher]bool
+}
+
+type watcher struct {
+	prefix string
+	events chan *ItemMessage
+}
+
+//NewWatchCache wrap cache to publish changes
+func NewWatchCache(cache Cacher) *WatchCache {
+	return &WatchCache{
+		Cacher:   cache,
+		watchers: make(map[*watcher]bool),
+	}
+}
+
+//Watch subscribe to changes of items with name prefix, empty prefix mean all items
+//Channel is closed after cancel or when watcher does not read events fast enough
+func (c *WatchCache) Watch(prefix string, buffer int) (<-chan *ItemMessage, func()) {
+	if buffer <= 0 {
+		buffer = DefaultWatchBuffer
+	}
+	w := &watcher{
+		prefix: prefix,
+		events: make(chan *ItemMessage, buffer),
+	}
+	c.l.Lock()
+	c.watchers[w] = true
+	c.l.Unlock()
+	return w.events, func() { c.drop(w) }
+}
+
+func (c *WatchCache) drop(w *watcher) {
+	c.l.Lock()
+	if c.watchers[w] {
+		delete(c.watchers, w)
+		close(w.events)
+	}
+	c.l.Unlock()
+}
+
+//publish send event to all matched watchers without blocking, slow watchers are dropped
+func (c *WatchCache) publish(ev *ItemMessage) {
+	var slow []*watcher
+	c.l.RLock()
+	for w := range c.watchers {
+		if ev.Command != ItemMessage_PURGE && !strings.HasPrefix(ev.Name, w.prefix) {
+			continue
+		}
+		select {
+		case w.events <- ev:
+		default:
+			slow = append(slow, w)
+		}
+	}
+	c.l.RUnlock()
+	for _, w := range slow {
+		c.drop(w)
+	}
+}
+
+func (c *WatchCache) watched() bool {
+	c.l.RLock()
+	defer c.l.RUnlock()
+	return len(c.watchers) > 0
+}
+
+func (c *WatchCache) publishSet(name string, value []byte, version uint64) {
+	if c.watched() {
+		c.publish(&ItemMessage{Command: ItemMessage_SET, Name: name, Object: value, Version: version})
+	}
+}
+
+//SetOrUpdate store item and publish SET
+func (c *WatchCache) SetOrUpdate(name string, value []byte, exp time.Duration) {
+	c.Cacher.SetOrUpdate(name, value, exp)
+	c.publishSet(name, value, 0)
+}
+
+//Incr add delta and publish SET with new value
+func (c *WatchCache) Incr(name string, delta int64, exp time.Duration) (int64, error) {
+	value, err := c.Cacher.Incr(name, delta, exp)
+	if err == nil {
+		c.publishSet(name, strconv.AppendInt(nil, value, 10), 0)
+	}
+	return value, err
+}
+
+//Decr subtract delta and publish SET with new value
+func (c *WatchCache) Decr(name string, delta int64, exp time.Duration) (int64, error) {
+	value, err := c.Cacher.Decr(name, delta, exp)
+	if err == nil {
+		c.publishSet(name, strconv.AppendInt(nil, value, 10), 0)
+	}
+	return value, err
+}
+
+//Add store absent item and publish SET
+func (c *WatchCache) Add(name string, value []byte, exp time.Duration) (uint64, error) {
+	version, err := c.Cacher.Add(name, value, exp)
+	if err == nil {
+		c.publishSet(name, value, version)
+	}
+	return version, err
+}
+
+//Replace store present item and publish SET
+func (c *WatchCache) Replace(name string, value []byte, exp time.Duration) (uint64, error) {
+	version, err := c.Cacher.Replace(name, value, exp)
+	if err == nil {
+		c.publishSet(name, value, version)
+	}
+	return version, err
+}
+
+//CAS store item with expected version and publish SET
+func (c *WatchCache) CAS(name string, value []byte, version uint64, exp time.Duration) (uint64, error) {
+	newVersion, err := c.Cacher.CAS(name, value, version, exp)
+	if err == nil {
+		c.publishSet(name, value, newVersion)
+	}
+	return newVersion, err
+}
+
+//StoreFlags store item with client flags of memcached protocol and publish SET, see FlagsCacher
+func (c *WatchCache) StoreFlags(cmd ItemMessage_Commands, name string, value []byte, flags uint32, version uint64, exp time.Duration) (uint64, error) {
+	newVersion, err := storeFlags(c.Cacher, cmd, name, value, flags, version, exp)
+	if err == nil {
+		c.publishSet(name, value, newVersion)
+	}
+	return newVersion, err
+}
+
+//Delete item and publish DELETE if it was found
+func (c *WatchCache) Delete(name string) bool {
+	deleted := c.Cacher.Delete(name)
+	if deleted && c.watched() {
+		c.publish(&ItemMessage{Command: ItemMessage_DELETE, Name: name})
+	}
+	return deleted
+}
+
+//Purge delete all items and publish PURGE to all watchers
+func (c *WatchCache) Purge() {
+	c.Cacher.Purge()
+	if c.watched() {
+		c.publish(&ItemMessage{Command: ItemMessage_PURGE})
+	}
+}
+
+//Dead close all watchers and stop cache
+func (c *WatchCache) Dead() {
+	c.l.Lock()
+	for w := range c.watchers {
+		delete(c.watchers, w)
+		close(w.events)
+	}
+	c.l.Unlock()
+	c.Cacher.Dead()
+}
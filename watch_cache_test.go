@@ -0,0 +1,64 @@
+package gcache
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchCache_Events(t *testing.T) {
+	as := assert.New(t)
+	c := NewWatchCache(NewRwCache(&ConfigMessage{}))
+	events, cancel := c.Watch("user:", 10)
+
+	c.SetOrUpdate("user:1", []byte(`zaza`), DefaultExpirationMarker)
+	c.SetOrUpdate("order:1", []byte(`zaza`), DefaultExpirationMarker)
+	v, err := c.Add("user:2", []byte(`azaz`), DefaultExpirationMarker)
+	as.NoError(err)
+	_, err = c.Add("user:2", []byte(`azaz`), DefaultExpirationMarker)
+	as.Equal(ErrExists, err)
+	c.Incr("user:3", 5, DefaultExpirationMarker)
+	as.True(c.Delete("user:1"))
+	as.False(c.Delete("user:1"))
+	c.Purge()
+
+	expected := []*ItemMessage{
+		{Command: ItemMessage_SET, Name: "user:1", Object: []byte(`zaza`)},
+		{Command: ItemMessage_SET, Name: "user:2", Object: []byte(`azaz`), Version: v},
+		{Command: ItemMessage_SET, Name: "user:3", Object: []byte(`5`)},
+		{Command: ItemMessage_DELETE, Name: "user:1"},
+		{Command: ItemMessage_PURGE},
+	}
+	for _, ev := range expected {
+		as.Equal(ev, <-events)
+	}
+	as.Len(events, 0, "failed writes and other prefixes are not published")
+
+	cancel()
+	_, ok := <-events
+	as.False(ok)
+	cancel() //second cancel is noop
+	c.Dead() //Cleanup
+}
+
+func TestWatchCache_Slow(t *testing.T) {
+	as := assert.New(t)
+	c := NewWatchCache(NewRwCache(&ConfigMessage{}))
+	slow, _ := c.Watch("", 1)
+	fast, cancel := c.Watch("", 10)
+	defer cancel()
+
+	c.SetOrUpdate("first", []byte(`zaza`), DefaultExpirationMarker)
+	c.SetOrUpdate("second", []byte(`zaza`), DefaultExpirationMarker)
+
+	as.Equal("first", (<-slow).Name)
+	_, ok := <-slow
+	as.False(ok, "slow watcher is dropped")
+	as.Len(fast, 2)
+
+	c.Dead() //Cleanup
+	<-fast
+	<-fast
+	_, ok = <-fast
+	as.False(ok, "Dead close all watchers")
+}